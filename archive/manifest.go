@@ -0,0 +1,128 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/manifest"
+)
+
+// TarManifest returns the manifest of the regular files in the tar archive read from r, without extracting it.
+// Member names are cleaned like paths in an fs.FS, dropping leading slashes and ./ elements, and a member given
+// more than once is listed as it is last, like extracting leaves it. Hard links, which tar stores without
+// content, are listed with the size and checksum of the member they link to. The manifest can be verified
+// against the tree the archive was made of with manifest.Verify.
+func TarManifest(r io.Reader) (*manifest.Manifest, error) {
+	tr := NewTarReader(r)
+	entries := make(map[string]manifest.Entry)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA && hdr.Typeflag != tar.TypeLink {
+			continue
+		}
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			target, err := cleanName(hdr.Linkname)
+			if err != nil {
+				return nil, err
+			}
+			e, ok := entries[target]
+			if !ok {
+				return nil, fmt.Errorf("archive: %q is a hard link to %q, which is not a file before it", hdr.Name, hdr.Linkname)
+			}
+			e.Path = name
+			entries[name] = e
+			continue
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return nil, err
+		}
+		sum := tr.Sums()[len(tr.Sums())-1]
+		entries[name] = manifest.Entry{Path: name, Size: sum.Size, ModTime: hdr.ModTime, Checksum: sum.Checksum}
+	}
+	m := make([]manifest.Entry, 0, len(entries))
+	for _, e := range entries {
+		m = append(m, e)
+	}
+	return manifest.New(m), nil
+}
+
+// ZipManifest returns the manifest of the regular files in the zip archive of size bytes read from r, without
+// extracting it. The options are used hashing the members.
+func ZipManifest(r io.ReaderAt, size int64, opts ...fletcher4.Option) (*manifest.Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Generate(zr, opts...)
+}
+
+// Manifest returns the manifest of the archive at path, a zip archive or a tar archive, optionally gzip
+// compressed, as told by its content.
+func Manifest(path string) (*manifest.Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK")):
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return ZipManifest(f, fi.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %v: %w", path, err)
+		}
+		return TarManifest(zr)
+	}
+	return TarManifest(br)
+}
+
+// cleanName returns the path of a tar member in an fs.FS, or an error if it is empty or leads out of the
+// archive.
+func cleanName(name string) (string, error) {
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || slices.Contains(strings.Split(name, "/"), "..") {
+		return "", fmt.Errorf("archive: invalid member name %q", name)
+	}
+	return clean, nil
+}
@@ -0,0 +1,140 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"go.solidsystem.no/fletcher4/manifest"
+)
+
+// tree returns the files as the tree the test archives are made of.
+func tree() fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, f := range files {
+		fsys[f.name] = &fstest.MapFile{Data: []byte(f.data)}
+	}
+	return fsys
+}
+
+// verifier returns a function verifying the tree against the manifest of an archive.
+func verifier(t *testing.T) func(m *manifest.Manifest, err error) {
+	return func(m *manifest.Manifest, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := manifest.Verify(tree(), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.OK() || res.Verified != len(files) {
+			t.Errorf("Verifying manifest %+v returned %+v", m.Entries, res)
+		}
+	}
+}
+
+// Test that the manifests of tar archives, plain and compressed, and zip archives match the tree they were made of
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	plain := tarArchive(t)
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write(plain)
+	gw.Close()
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := verifier(t)
+	verify(TarManifest(bytes.NewReader(plain)))
+	verify(ZipManifest(bytes.NewReader(zipped.Bytes()), int64(zipped.Len())))
+	for name, data := range map[string][]byte{"a.tar": plain, "a.tar.gz": compressed.Bytes(), "a.zip": zipped.Bytes()} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		verify(Manifest(path))
+	}
+}
+
+// Test that member names are cleaned, the last of duplicates counts, and names leading out are refused
+func TestTarManifestNames(t *testing.T) {
+	archive := func(names ...string) io.Reader {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for i, name := range names {
+			tw.WriteHeader(&tar.Header{Name: name, Size: int64(i), Mode: 0o644})
+			tw.Write(make([]byte, i))
+		}
+		tw.Close()
+		return &buf
+	}
+	m, err := TarManifest(archive("./a", "/b", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 2 || m.Entries[0].Path != "a" || m.Entries[0].Size != 2 || m.Entries[1].Path != "b" {
+		t.Errorf("Manifest has entries %+v", m.Entries)
+	}
+	for _, name := range []string{"../a", "a/../../b", "."} {
+		if _, err := TarManifest(archive(name)); err == nil {
+			t.Errorf("Member named %q was accepted", name)
+		}
+	}
+}
+
+// Test that hard links get the entry of the file they link to, as their content is only stored once
+func TestTarManifestHardlink(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "a", Size: 6, Mode: 0o644})
+	io.WriteString(tw, "hello\n")
+	tw.WriteHeader(&tar.Header{Name: "b", Typeflag: tar.TypeLink, Linkname: "./a", Mode: 0o644})
+	tw.Close()
+	m, err := TarManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"a": {Data: []byte("hello\n")}, "b": {Data: []byte("hello\n")}}
+	if res, err := manifest.Verify(fsys, m); err != nil || !res.OK() || res.Verified != 2 {
+		t.Errorf("Verifying manifest %+v returned %+v, %v", m.Entries, res, err)
+	}
+
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "b", Typeflag: tar.TypeLink, Linkname: "a", Mode: 0o644})
+	tw.Close()
+	if _, err := TarManifest(&buf); err == nil {
+		t.Error("Hard link to a missing file was accepted")
+	}
+}
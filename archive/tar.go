@@ -0,0 +1,105 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive checksums the entries of tar archives as they are read, so backup tools can produce integrity
+// manifests while extracting or inspecting archives. The manifest of a tar or zip archive, to verify it against
+// the tree it was made of, is made without extracting it too:
+//
+//	m, err := archive.Manifest("backup.tar.gz")
+//	...
+//	res, err := manifest.Verify(os.DirFS("/srv/data"), m)
+package archive // import go.solidsystem.no/fletcher4/archive
+
+import (
+	"archive/tar"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// EntrySum is the checksum of the content of one entry of an archive.
+type EntrySum struct {
+	Header   *tar.Header
+	Size     int64
+	Checksum fletcher4.Checksum
+}
+
+// TarReader reads a tar archive like tar.Reader, hashing the content of every entry as it is read. Content not
+// read by the caller is hashed when moving on to the next entry, so the checksums always cover whole entries.
+type TarReader struct {
+	tr    *tar.Reader
+	entry *tar.Header
+	h     *fletcher4.HashingReader
+	sums  []EntrySum
+}
+
+// NewTarReader returns a TarReader reading the archive from r.
+func NewTarReader(r io.Reader) *TarReader {
+	return &TarReader{tr: tar.NewReader(r)}
+}
+
+// Next finishes hashing the current entry and advances to the next one, like tar.Reader.Next.
+func (t *TarReader) Next() (*tar.Header, error) {
+	if err := t.finish(); err != nil {
+		return nil, err
+	}
+	hdr, err := t.tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	t.entry = hdr
+	t.h = fletcher4.NewHashingReader(t.tr)
+	return hdr, nil
+}
+
+// Read reads from the content of the current entry.
+func (t *TarReader) Read(p []byte) (int, error) {
+	if t.h == nil {
+		return 0, io.EOF
+	}
+	n, err := t.h.Read(p)
+	if err == io.EOF {
+		t.record()
+	}
+	return n, err
+}
+
+// finish hashes the rest of the current entry and records its checksum.
+func (t *TarReader) finish() error {
+	if t.h == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, t.h); err != nil {
+		return err
+	}
+	t.record()
+	return nil
+}
+
+// record records the checksum of the current entry, read to its end.
+func (t *TarReader) record() {
+	t.sums = append(t.sums, EntrySum{Header: t.entry, Size: t.h.Count(), Checksum: t.h.Checksum()})
+	t.entry, t.h = nil, nil
+}
+
+// Sums returns the checksums of the entries read so far, in their order in the archive. The current entry is
+// included once it has been read to its end, or Next or Close has been called.
+func (t *TarReader) Sums() []EntrySum {
+	return t.sums
+}
+
+// Close hashes the rest of the current entry, so Sums includes it. It does not close the underlying reader.
+func (t *TarReader) Close() error {
+	return t.finish()
+}
@@ -0,0 +1,88 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// files are the contents of the test archives by name, in their order
+var files = []struct {
+	name, data string
+}{
+	{"a.txt", "hello world"},
+	{"dir/b.bin", string(bytes.Repeat([]byte{1, 2, 3}, 100000))},
+	{"empty", ""},
+}
+
+// tarArchive returns a tar archive of files, with a directory entry.
+func tarArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Size: int64(len(f.data)), Mode: 0o644}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, f.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Test that entries read in full, in part or not at all get the checksums of their whole content
+func TestTarReader(t *testing.T) {
+	tr := NewTarReader(bytes.NewReader(tarArchive(t)))
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 1:
+			if data, err := io.ReadAll(tr); err != nil || string(data) != files[0].data {
+				t.Errorf("Content of %v is %q, %v", hdr.Name, data, err)
+			}
+		case 2:
+			io.ReadFull(tr, make([]byte, 1000))
+		}
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sums := tr.Sums()
+	if len(sums) != len(files)+1 || sums[0].Header.Name != "dir/" || sums[0].Size != 0 {
+		t.Fatalf("Sums are %v", sums)
+	}
+	for i, f := range files {
+		sum := sums[i+1]
+		if exp := fletcher4.Sum([]byte(f.data)); sum.Header.Name != f.name || sum.Size != int64(len(f.data)) || sum.Checksum != exp {
+			t.Errorf("Entry %v has %v, %v, %v, expected %v, %v", i+1, sum.Header.Name, sum.Size, sum.Checksum, f.name, exp)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module go.solidsystem.no/fletcher4/asm
+
+go 1.22.0
+
+require github.com/mmcloughlin/avo v0.6.0
+
+require (
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/tools v0.30.0 // indirect
+)
@@ -0,0 +1,216 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command asm generates the amd64 lane kernels of fletcher4 using avo. It lives in its own module so avo is not
+// a dependency of the library. Run it with go generate from the repository root, see dispatch_amd64.go.
+package main
+
+import (
+	"fmt"
+
+	. "github.com/mmcloughlin/avo/build"
+	. "github.com/mmcloughlin/avo/operand"
+	. "github.com/mmcloughlin/avo/reg"
+)
+
+// isa describes how one instruction set does the few vector operations a lane kernel needs.
+type isa struct {
+	// Name of the kernel, the generated function is fletcher<name>
+	name string
+	// Number of 64 bit lanes in one vector register
+	lanes int
+	// Allocates a vector register
+	alloc func() VecVirtual
+	// Sets a register to zero
+	zero func(x VecVirtual)
+	// Loads the next word of every lane from m into x, zero extended to 64 bits. z is a register set to zero
+	// before the loop, for instruction sets lacking a zero extending load.
+	load func(m Mem, x, z VecVirtual)
+	// Adds x to y
+	add func(x, y VecVirtual)
+	// Stores x to m
+	store func(x VecVirtual, m Mem)
+	// Called before returning
+	done func()
+}
+
+var isas = []isa{
+	{
+		name:  "SSE2",
+		lanes: 2,
+		alloc: XMM,
+		zero:  func(x VecVirtual) { PXOR(x, x) },
+		load: func(m Mem, x, z VecVirtual) {
+			// Interleave the two words with zero words
+			MOVQ(m, x)
+			PUNPCKLLQ(z, x)
+		},
+		add:   func(x, y VecVirtual) { PADDQ(x, y) },
+		store: func(x VecVirtual, m Mem) { MOVOU(x, m) },
+		done:  func() {},
+	},
+	{
+		name:  "AVX2",
+		lanes: 4,
+		alloc: YMM,
+		zero:  func(x VecVirtual) { VPXOR(x, x, x) },
+		load:  func(m Mem, x, _ VecVirtual) { VPMOVZXDQ(m, x) },
+		add:   func(x, y VecVirtual) { VPADDQ(x, y, y) },
+		store: func(x VecVirtual, m Mem) { VMOVDQU(x, m) },
+		done:  func() { VZEROUPPER() },
+	},
+	{
+		name:  "AVX512F",
+		lanes: 8,
+		alloc: ZMM,
+		zero:  func(x VecVirtual) { VPXORQ(x, x, x) },
+		load:  func(m Mem, x, _ VecVirtual) { VPMOVZXDQ(m, x) },
+		add:   func(x, y VecVirtual) { VPADDQ(x, y, y) },
+		store: func(x VecVirtual, m Mem) { VMOVDQU64(x, m) },
+		done:  func() { VZEROUPPER() },
+	},
+}
+
+func main() {
+	ConstraintExpr("!purego")
+	for _, i := range isas {
+		kernel(i)
+	}
+	for _, i := range isas {
+		if i.lanes >= 4 {
+			multiKernel(i)
+		}
+	}
+	Generate()
+}
+
+// kernel generates the lane kernel for one instruction set. It keeps the a, b, c and d sums of every lane in
+// one vector register each, and widens the next 32 bit word of every lane to 64 bits before adding it.
+func kernel(i isa) {
+	name := "fletcher" + i.name
+	TEXT(name, NOSPLIT, fmt.Sprintf("func(lanes *[4][%d]uint64, p []byte)", i.lanes))
+	Pragma("noescape")
+	Doc(fmt.Sprintf("%v processes all of p, which must be a multiple of %v bytes long, and stores the lane sums starting from zero in lanes.", name, 4*i.lanes))
+
+	lanes := Load(Param("lanes"), GP64())
+	ptr := Load(Param("p").Base(), GP64())
+	n := Load(Param("p").Len(), GP64())
+
+	var sums [4]VecVirtual
+	for s := range sums {
+		sums[s] = i.alloc()
+		i.zero(sums[s])
+	}
+	word := i.alloc()
+	zero := i.alloc()
+	i.zero(zero)
+
+	TESTQ(n, n)
+	JZ(LabelRef(name + "done"))
+
+	Label(name + "loop")
+	i.load(Mem{Base: ptr}, word, zero)
+	i.add(word, sums[0])
+	i.add(sums[0], sums[1])
+	i.add(sums[1], sums[2])
+	i.add(sums[2], sums[3])
+	ADDQ(U32(4*i.lanes), ptr)
+	SUBQ(U32(4*i.lanes), n)
+	JNZ(LabelRef(name + "loop"))
+
+	Label(name + "done")
+	for s := range sums {
+		i.store(sums[s], Mem{Base: lanes, Disp: s * 8 * i.lanes})
+	}
+	i.done()
+	RET()
+}
+
+// multiKernel generates the multi buffer kernel for one instruction set, hashing one buffer per lane instead of
+// spreading one buffer over the lanes, so the lane sums are the checksums of the buffers with nothing left to
+// combine. Each round loads 4 words of every buffer, and transposes them in groups of 4 buffers so the next
+// vector holds the same word of every buffer.
+func multiKernel(i isa) {
+	name := "fletcherMulti" + i.name
+	TEXT(name, NOSPLIT, fmt.Sprintf("func(sums *[4][%d]uint64, bufs *[%d][]byte, n int)", i.lanes, i.lanes))
+	Pragma("noescape")
+	Doc(fmt.Sprintf("%v processes the first n bytes of every buffer in bufs, n being a multiple of 16, and stores their checksums starting from zero in sums.", name))
+
+	out := Load(Param("sums"), GP64())
+	bufs := Load(Param("bufs"), GP64())
+	n := Load(Param("n"), GP64())
+	ptrs := make([]GPVirtual, i.lanes)
+	for l := range ptrs {
+		ptrs[l] = GP64()
+		// The base pointer of slice l, slices being 3 words long
+		MOVQ(Mem{Base: bufs, Disp: 24 * l}, ptrs[l])
+	}
+	off := GP64()
+	XORQ(off, off)
+
+	var sums [4]VecVirtual
+	for s := range sums {
+		sums[s] = i.alloc()
+		i.zero(sums[s])
+	}
+	TESTQ(n, n)
+	JZ(LabelRef(name + "done"))
+
+	Label(name + "loop")
+	groups := make([][4]VecVirtual, i.lanes/4)
+	for g := range groups {
+		var x, t [4]VecVirtual
+		for l := range x {
+			x[l] = XMM()
+			VMOVDQU(Mem{Base: ptrs[4*g+l], Index: off, Scale: 1}, x[l])
+		}
+		for l := range t {
+			t[l] = XMM()
+		}
+		// Pairs of buffers interleaved, then pairs of pairs
+		VPUNPCKLDQ(x[1], x[0], t[0])
+		VPUNPCKHDQ(x[1], x[0], t[1])
+		VPUNPCKLDQ(x[3], x[2], t[2])
+		VPUNPCKHDQ(x[3], x[2], t[3])
+		VPUNPCKLQDQ(t[2], t[0], x[0])
+		VPUNPCKHQDQ(t[2], t[0], x[1])
+		VPUNPCKLQDQ(t[3], t[1], x[2])
+		VPUNPCKHQDQ(t[3], t[1], x[3])
+		groups[g] = x
+	}
+	for w := 0; w < 4; w++ {
+		word := i.alloc()
+		if len(groups) == 1 {
+			VPMOVZXDQ(groups[0][w], word)
+		} else {
+			both := YMM()
+			VINSERTI128(U8(1), groups[1][w], groups[0][w].AsY(), both)
+			VPMOVZXDQ(both, word)
+		}
+		i.add(word, sums[0])
+		i.add(sums[0], sums[1])
+		i.add(sums[1], sums[2])
+		i.add(sums[2], sums[3])
+	}
+	ADDQ(U32(16), off)
+	SUBQ(U32(16), n)
+	JNZ(LabelRef(name + "loop"))
+
+	Label(name + "done")
+	for s := range sums {
+		i.store(sums[s], Mem{Base: out, Disp: s * 8 * i.lanes})
+	}
+	i.done()
+	RET()
+}
@@ -0,0 +1,60 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import "math/bits"
+
+// BloomIndexes returns k bit indexes in [0, m) for the checksum, to set or test in a bloom filter of m bits. See
+// AppendBloomIndexes.
+func (c Checksum) BloomIndexes(k int, m uint64) []uint64 {
+	return c.AppendBloomIndexes(make([]uint64, 0, k), k, m)
+}
+
+// AppendBloomIndexes appends k bit indexes in [0, m) for the checksum to dst, and returns the result. Filters of
+// blocks can so be built from checksums already computed, instead of hashing the data again. m must not be zero.
+//
+// The words of a fletcher4 checksum are sums, not well mixed hashes: blocks differing in a few bytes get words
+// differing in a few low bits. So the words are first mixed into two independent looking 64 bit hashes, from
+// which the k indexes are derived by enhanced double hashing. This gives false positive rates close to those of
+// k independent hashes for any data no one chose to collide. The indexes can only be as independent as the
+// checksums are, though: blocks with equal checksums always get equal indexes, which includes blocks differing
+// only by trailing zero bytes in a final partial word, and blocks made to collide, which is easy for fletcher4.
+// Have a filter only skip work that is verified otherwise, and include the length in what is checked if blocks
+// vary in size, as Fingerprint does.
+func (c Checksum) AppendBloomIndexes(dst []uint64, k int, m uint64) []uint64 {
+	if m == 0 {
+		panic("Bloom filter given to AppendBloomIndexes must have at least one bit.")
+	}
+	h1 := mix64(c[0] ^ mix64(c[1]))
+	h2 := mix64(c[2] ^ mix64(c[3]^0x9e3779b97f4a7c15))
+	for i := 0; i < k; i++ {
+		// Reduce to [0, m) by multiplication, which uses the well mixed high bits
+		idx, _ := bits.Mul64(h1, m)
+		dst = append(dst, idx)
+		h1 += h2
+		h2 += uint64(i + 1)
+	}
+	return dst
+}
+
+// mix64 is the finalizer of MurmurHash3, every bit of x affects every bit of the result.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,63 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Test that a bloom filter of blocks differing in a single word has about the false positive rate of one with
+// independent hashes
+func TestBloomIndexes(t *testing.T) {
+	const n, k, m = 10000, 7, 10 * 10000
+	block := make([]byte, 4096)
+	sum := func(i int) Checksum {
+		binary.LittleEndian.PutUint32(block[100:], uint32(i))
+		return Sum(block)
+	}
+	filter := make([]bool, m)
+	for i := 0; i < n; i++ {
+		idx := sum(i).BloomIndexes(k, m)
+		if len(idx) != k {
+			t.Fatalf("Got %v indexes, expected %v", len(idx), k)
+		}
+		for _, j := range idx {
+			if j >= m {
+				t.Fatalf("Index %v is out of range", j)
+			}
+			filter[j] = true
+		}
+	}
+	positives := 0
+	for i := n; i < 2*n; i++ {
+		all := true
+		for _, j := range sum(i).BloomIndexes(k, m) {
+			all = all && filter[j]
+		}
+		if all {
+			positives++
+		}
+	}
+	// About 0.8% with independent hashes
+	if rate := float64(positives) / n; rate > 0.02 {
+		t.Errorf("False positive rate is %.2f%%", 100*rate)
+	}
+
+	c := sum(1)
+	if a, b := c.BloomIndexes(k, m), c.AppendBloomIndexes([]uint64{42}, k, m); len(b) != k+1 || b[0] != 42 || b[1] != a[0] || b[k] != a[k-1] {
+		t.Errorf("AppendBloomIndexes returned %v, BloomIndexes %v", b, a)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// ChecksumByteswap returns the checksum of p read as big endian words, like fletcher_4_byteswap in OpenZFS. It
+// recomputes the checksums written by a host of the other byte order: data sent by such a host is verified by
+// comparing ChecksumByteswap of it to the stored checksum with the bytes of each word reversed. A trailing partial
+// word is padded with zero bytes. Checksums of consecutive buffers combine with Combine as usual.
+//
+// The words are swapped a chunk at a time into a buffer, so the fastest kernel hashes them.
+func ChecksumByteswap(p []byte) Checksum {
+	var buf [4096]byte
+	impl := active.Load()
+	var dig digest
+	for len(p) >= BlockSize {
+		n := copy(buf[:], p) &^ (BlockSize - 1)
+		swapWords(buf[:n])
+		dig = impl.update(dig, buf[:n])
+		p = p[n:]
+	}
+	if len(p) > 0 {
+		var last [BlockSize]byte
+		copy(last[:], p)
+		swapWords(last[:])
+		dig = updateScalar(dig, last[:])
+	}
+	return Checksum(dig)
+}
+
+// swapWords reverses the bytes of every word of p in place.
+func swapWords(p []byte) {
+	for i := 0; i+BlockSize <= len(p); i += BlockSize {
+		binary.LittleEndian.PutUint32(p[i:], bits.ReverseBytes32(binary.LittleEndian.Uint32(p[i:])))
+	}
+}
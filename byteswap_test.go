@@ -0,0 +1,42 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Test that the byte swapped checksum of data is the checksum of the data with every word swapped, and that the
+// checksums of consecutive buffers combine
+func TestChecksumByteswap(t *testing.T) {
+	for _, n := range []int{0, 3, 4, 100, 4096, 4100, 10001} {
+		data := randomBytes(n)
+		swapped := make([]byte, (n+BlockSize-1)&^(BlockSize-1))
+		copy(swapped, data)
+		for i := 0; i < len(swapped); i += BlockSize {
+			binary.BigEndian.PutUint32(swapped[i:], binary.LittleEndian.Uint32(swapped[i:]))
+		}
+		exp := Checksum(updateScalar(digest{}, swapped))
+		if sum := ChecksumByteswap(data); sum != exp {
+			t.Errorf("Byte swapped checksum of %v bytes:\nexpected\t%x,\ngot\t\t%x", n, exp, sum)
+		}
+
+		split := n / 2 &^ (BlockSize - 1)
+		if sum := Combine(ChecksumByteswap(data[:split]), ChecksumByteswap(data[split:]), int64(len(swapped)-split)); sum != exp {
+			t.Errorf("Combined byte swapped checksum of %v bytes:\nexpected\t%x,\ngot\t\t%x", n, exp, sum)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cas stores blobs in a directory, addressed by their fletcher4 checksum and length.
+//
+// Each blob is a file named after its fingerprint, in two levels of directories named after the first bytes of
+// the checksum, so no directory grows too large:
+//
+//	<dir>/0a/1b/0a1b...<64 hex digits in all>-<length>
+//
+// Blobs are written to a temporary file first and renamed into place, so a blob is either complete or absent.
+// Putting a blob already stored leaves the stored one as it is. Reading always verifies the blob against its
+// fingerprint, files changed or damaged after they were stored give an error rather than silently wrong data.
+//
+// Fletcher4 is not collision resistant, see fletcher4.Fingerprint. A store is a building block for archival of
+// trusted data, where blobs are deduplicated by fingerprint. Confirm equal fingerprints with a strong hash or a
+// byte comparison where the data can be chosen by others.
+package cas // import go.solidsystem.no/fletcher4/cas
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Store is a content addressed store of blobs in a directory. It is safe for concurrent use, also by several
+// processes sharing the directory.
+type Store struct {
+	dir string
+}
+
+// New returns the store in dir, creating the directory if it does not exist.
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cas: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of the file holding the blob with fingerprint fp, whether it is stored or not.
+func (s *Store) Path(fp fletcher4.Fingerprint) string {
+	hex := fp.Checksum.String()
+	return filepath.Join(s.dir, hex[:2], hex[2:4], hex+"-"+strconv.FormatInt(fp.Length, 10))
+}
+
+// Put reads r until EOF, stores the data as a blob unless it is stored already, and returns its fingerprint.
+// The data is synced to disk before the blob is put in place.
+func (s *Store) Put(r io.Reader) (fp fletcher4.Fingerprint, err error) {
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	w := fletcher4.NewHashingWriter(tmp)
+	if _, err := io.Copy(w, r); err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+	fp = fletcher4.Fingerprint{Checksum: w.Checksum(), Length: w.Count()}
+	if err := tmp.Sync(); err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+
+	if ok, err := s.Has(fp); err != nil || ok {
+		os.Remove(tmp.Name())
+		return fp, err
+	}
+	path := s.Path(fp)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fp, fmt.Errorf("cas: %w", err)
+	}
+	return fp, nil
+}
+
+// Has reports whether the blob with fingerprint fp is stored. Only the size of the file is checked, its data is
+// verified when it is read.
+func (s *Store) Has(fp fletcher4.Fingerprint) (bool, error) {
+	fi, err := os.Stat(s.Path(fp))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cas: %w", err)
+	}
+	return fi.Mode().IsRegular() && fi.Size() == fp.Length, nil
+}
+
+// Open opens the blob with fingerprint fp for reading. The data is verified as it is read: the read reaching
+// the end of the blob returns an error matching fletcher4.ErrChecksumMismatch instead of io.EOF if the data does
+// not match fp, so it must be read to the end before it is trusted. A blob that is not stored gives an error
+// matching fs.ErrNotExist.
+func (s *Store) Open(fp fletcher4.Fingerprint) (io.ReadCloser, error) {
+	f, err := os.Open(s.Path(fp))
+	if err != nil {
+		return nil, fmt.Errorf("cas: %w", err)
+	}
+	return &blobReader{f: f, r: fletcher4.NewHashingReader(f), fp: fp}, nil
+}
+
+// blobReader verifies a blob against its fingerprint when reading it reaches EOF.
+type blobReader struct {
+	f  *os.File
+	r  *fletcher4.HashingReader
+	fp fletcher4.Fingerprint
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	switch {
+	case b.r.Count() > b.fp.Length:
+		err = fmt.Errorf("cas: blob %v is longer than %v bytes: %w", b.fp, b.fp.Length, fletcher4.ErrChecksumMismatch)
+	case err != io.EOF:
+	case b.r.Count() < b.fp.Length:
+		err = fmt.Errorf("cas: blob %v ends after %v bytes: %w", b.fp, b.r.Count(), fletcher4.ErrChecksumMismatch)
+	case b.r.Checksum() != b.fp.Checksum:
+		err = fmt.Errorf("cas: blob %v: %w", b.fp, &fletcher4.MismatchError{Expected: b.fp.Checksum, Actual: b.r.Checksum()})
+	}
+	return n, err
+}
+
+func (b *blobReader) Close() error {
+	return b.f.Close()
+}
@@ -0,0 +1,105 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cas
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that blobs put are found under their fingerprint and read back, and that putting them again dedups
+func TestPutOpen(t *testing.T) {
+	s, err := New(t.TempDir() + "/store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("blob data of odd length")
+	fp, err := s.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := fletcher4.NewFingerprint(data); fp != exp {
+		t.Errorf("Put returned %v, expected %v", fp, exp)
+	}
+	if !strings.HasSuffix(s.Path(fp), fp.Checksum.String()[2:4]+"/"+fp.Checksum.String()+"-23") {
+		t.Errorf("Blob is stored at %v", s.Path(fp))
+	}
+	if ok, err := s.Has(fp); !ok || err != nil {
+		t.Errorf("Has of stored blob returned %v, %v", ok, err)
+	}
+	if ok, err := s.Has(fletcher4.Fingerprint{Checksum: fp.Checksum, Length: 24}); ok || err != nil {
+		t.Errorf("Has of a different length returned %v, %v", ok, err)
+	}
+	r, err := s.Open(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Opened blob read %q, %v", got, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if again, err := s.Put(bytes.NewReader(data)); err != nil || again != fp {
+				t.Errorf("Putting the blob again returned %v, %v", again, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if entries, _ := os.ReadDir(s.Dir()); len(entries) != 1 {
+		t.Errorf("Store holds %v, expected only the directory of the blob", entries)
+	}
+	if _, err := s.Open(fletcher4.NewFingerprint([]byte("missing"))); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Opening a missing blob returned %v", err)
+	}
+}
+
+// Test that blobs changed after they were stored fail to read
+func TestOpenCorrupt(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := s.Put(strings.NewReader("original data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"modified data", "original dat", "original data, longer"} {
+		if err := os.WriteFile(s.Path(fp), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := s.Open(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+			t.Errorf("Reading blob changed to %q returned %v", data, err)
+		}
+		r.Close()
+	}
+}
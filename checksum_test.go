@@ -0,0 +1,166 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Test that the multi buffer kernels give the same checksums as the scalar kernel, for any number of buffers and
+// lengths, including words left after the last whole round
+func TestSumMultiImplementations(t *testing.T) {
+	defer SetImplementation(Implementation())
+	data := randomBytes(17 * 4100)
+	for _, name := range Implementations() {
+		if err := SetImplementation(name); err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int{0, 4, 12, 16, 20, 1028, 4100} {
+			for n := 1; n <= 17; n++ {
+				var bufs [][]byte
+				for i := 0; i < n; i++ {
+					bufs = append(bufs, data[i*size:(i+1)*size])
+				}
+				for i, got := range SumMulti(nil, bufs) {
+					if exp := Checksum(updateScalar(digest{}, bufs[i])); got != exp {
+						t.Errorf("%v: buffer %v of %v of %v bytes:\nexpected\t%x,\ngot\t\t%x", name, i, n, size, exp, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Benchmark hashing 8 buffers with the multi buffer kernel of every implementation having one, and one by one
+func BenchmarkSumMulti(b *testing.B) {
+	for _, impl := range implementations {
+		if !impl.available || impl.multi == nil {
+			continue
+		}
+		for _, size := range []int{64, 512, 1 << 10, 2 << 10, 4 << 10, 128 << 10} {
+			bufs := make([][]byte, 8)
+			for i := range bufs {
+				bufs[i] = make([]byte, size)
+			}
+			sums := make([]Checksum, 0, len(bufs))
+			b.Run(fmt.Sprintf("%v/multi/%v", impl.name, size), func(b *testing.B) {
+				b.SetBytes(int64(size * len(bufs)))
+				for i := 0; i < b.N; i++ {
+					sums = sums[:0]
+					for j := 0; j < len(bufs); j += impl.multiLanes {
+						sums = impl.multi(sums, bufs[j:j+impl.multiLanes])
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%v/single/%v", impl.name, size), func(b *testing.B) {
+				b.SetBytes(int64(size * len(bufs)))
+				for i := 0; i < b.N; i++ {
+					sums = sums[:0]
+					for _, p := range bufs {
+						sums = append(sums, Checksum(impl.update(digest{}, p)))
+					}
+				}
+			})
+		}
+	}
+}
+
+// Test that SumMulti gives the same checksums as hashing each buffer on its own
+func TestSumMulti(t *testing.T) {
+	data := randomBytes(8 * 1024)
+	var bufs [][]byte
+	for i := 0; i < 8; i++ {
+		bufs = append(bufs, data[i*1024:(i+1)*1024])
+	}
+
+	sums := SumMulti(nil, bufs)
+	if len(sums) != len(bufs) {
+		t.Fatalf("SumMulti returned %v checksums for %v buffers", len(sums), len(bufs))
+	}
+	for i, p := range bufs {
+		checksummer := New()
+		if _, err := checksummer.Write(p); err != nil {
+			t.Fatal(err)
+		}
+		if exp := Checksum(checksummer.Sum64x4()); sums[i] != exp {
+			t.Errorf("SumMulti buffer %v:\nexpected\t%x,\ngot\t\t%x", i, exp, sums[i])
+		}
+	}
+}
+
+// Test that ChecksumBlocks checksums every block, including a shorter last one, with every implementation
+func TestChecksumBlocks(t *testing.T) {
+	defer SetImplementation(Implementation())
+	data := randomBytes(10*512 + 8)
+	for _, name := range Implementations() {
+		if err := SetImplementation(name); err != nil {
+			t.Fatal(err)
+		}
+		sums := ChecksumBlocks(data, 512)
+		if len(sums) != 11 {
+			t.Fatalf("ChecksumBlocks returned %v checksums, expected 11", len(sums))
+		}
+		for i, got := range sums {
+			end := (i + 1) * 512
+			if end > len(data) {
+				end = len(data)
+			}
+			if exp := Checksum(updateScalar(digest{}, data[i*512:end])); got != exp {
+				t.Errorf("%v: ChecksumBlocks block %v:\nexpected\t%x,\ngot\t\t%x", name, i, exp, got)
+			}
+		}
+	}
+}
+
+// Test that Sum pads a trailing partial word with zero bytes, with every implementation
+func TestSum(t *testing.T) {
+	defer SetImplementation(Implementation())
+	for _, name := range Implementations() {
+		if err := SetImplementation(name); err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 3, 4, 100, 4096, 4100, 10001} {
+			data := randomBytes(n)
+			if exp := paddedChecksum(data); Sum(data) != exp {
+				t.Errorf("%v: checksum of %v bytes:\nexpected\t%x,\ngot\t\t%x", name, n, exp, Sum(data))
+			}
+		}
+	}
+}
+
+// Test that checksums survive formatting and parsing
+func TestChecksumString(t *testing.T) {
+	c := Checksum{0x0807060504030201, 0x100f0e0d0c0b0a09, 0, 1<<64 - 1}
+	s := c.String()
+	if exp := "0102030405060708090a0b0c0d0e0f100000000000000000ffffffffffffffff"; s != exp {
+		t.Errorf("String returned %v, expected %v", s, exp)
+	}
+	for _, in := range []string{s, strings.ToUpper(s)} {
+		got, err := ParseChecksum(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c {
+			t.Errorf("ParseChecksum(%v) returned %x, expected %x", in, got, c)
+		}
+	}
+	for _, bad := range []string{"", "01", s + "00", "x" + s[1:]} {
+		if _, err := ParseChecksum(bad); err == nil {
+			t.Errorf("ParseChecksum(%q) did not fail", bad)
+		}
+	}
+}
@@ -15,9 +15,12 @@
 package fletcher4 // import go.solidsystem.no/fletcher4
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash"
+	"io"
 )
 
 // Extension of common Hash interface to easily get 4 computed checksum words
@@ -52,13 +55,8 @@ func (d *digest) BlockSize() int {
 	return BlockSize
 }
 
-// Add p to the running checksum d.
+// Add p to the running checksum d, using the currently selected implementation.
 func update(dig digest, p []byte) digest {
-	a := dig[0]
-	b := dig[1]
-	c := dig[2]
-	d := dig[3]
-
 	// Incase input is not padded to 4 bytes
 	if len(p)%BlockSize != 0 {
 		panic(fmt.Sprintf("Write to Fletcher64x4 checksummer must be a multiple of %v bytes.", BlockSize))
@@ -75,8 +73,73 @@ func update(dig digest, p []byte) digest {
 	}
 	*/
 
-	for i := 0; i < len(p); i += BlockSize {
-		a += uint64(binary.LittleEndian.Uint32(p[i : i+BlockSize]))
+	return active.Load().update(dig, p)
+}
+
+// Add p to the running checksum d one word at a time. This is the reference implementation all other kernels
+// are verified against.
+func updateScalar(dig digest, p []byte) digest {
+	a := dig[0]
+	b := dig[1]
+	c := dig[2]
+	d := dig[3]
+
+	// Load 8 bytes at a time and split them into the two words, halving the number of loads. The loop is unrolled
+	// to handle 32 bytes per round, and p is resliced rather than indexed so the compiler can prove all accesses
+	// are in bounds instead of checking each of them.
+	for ; len(p) >= 8*BlockSize; p = p[8*BlockSize:] {
+		q := p[:8*BlockSize]
+		w0 := binary.LittleEndian.Uint64(q[0:8])
+		w1 := binary.LittleEndian.Uint64(q[8:16])
+		w2 := binary.LittleEndian.Uint64(q[16:24])
+		w3 := binary.LittleEndian.Uint64(q[24:32])
+		a += w0 & 0xffffffff
+		b += a
+		c += b
+		d += c
+		a += w0 >> 32
+		b += a
+		c += b
+		d += c
+		a += w1 & 0xffffffff
+		b += a
+		c += b
+		d += c
+		a += w1 >> 32
+		b += a
+		c += b
+		d += c
+		a += w2 & 0xffffffff
+		b += a
+		c += b
+		d += c
+		a += w2 >> 32
+		b += a
+		c += b
+		d += c
+		a += w3 & 0xffffffff
+		b += a
+		c += b
+		d += c
+		a += w3 >> 32
+		b += a
+		c += b
+		d += c
+	}
+	for ; len(p) >= 2*BlockSize; p = p[2*BlockSize:] {
+		w := binary.LittleEndian.Uint64(p)
+		a += w & 0xffffffff
+		b += a
+		c += b
+		d += c
+		a += w >> 32
+		b += a
+		c += b
+		d += c
+	}
+	// At most one word left
+	if len(p) >= BlockSize {
+		a += uint64(binary.LittleEndian.Uint32(p))
 		b += a
 		c += b
 		d += c
@@ -90,16 +153,25 @@ func (d *digest) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// ReadFrom hashes everything read from r until EOF through an internal buffer of DefaultChunkSize bytes, so
+// io.Copy to a checksummer reads well sized chunks. Unlike Write, reads of any length are handled. If the data
+// read does not end on a whole word, the last word is padded with zero bytes.
+func (d *digest) ReadFrom(r io.Reader) (n int64, err error) {
+	buf := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(buf)
+
+	s := stream{dig: *d}
+	n, err = s.readFrom(context.Background(), r, *buf)
+	*d = digest(s.checksum())
+	return n, err
+}
+
 func (d *digest) Sum(in []byte) []byte {
-	add := make([]byte, 8)
-	binary.LittleEndian.PutUint64(add, d[0])
-	ret := append(in, add...)
-	binary.LittleEndian.PutUint64(add, d[1])
-	ret = append(ret, add...)
-	binary.LittleEndian.PutUint64(add, d[2])
-	ret = append(ret, add...)
-	binary.LittleEndian.PutUint64(add, d[3])
-	ret = append(ret, add...)
+	// Appending directly avoids allocating, unless in lacks the capacity
+	ret := binary.LittleEndian.AppendUint64(in, d[0])
+	ret = binary.LittleEndian.AppendUint64(ret, d[1])
+	ret = binary.LittleEndian.AppendUint64(ret, d[2])
+	ret = binary.LittleEndian.AppendUint64(ret, d[3])
 
 	return ret
 }
@@ -108,3 +180,37 @@ func (d *digest) Sum(in []byte) []byte {
 func (d *digest) Sum64x4() [4]uint64 {
 	return [4]uint64(*d)
 }
+
+// Prefix of marshaled digest states, with a version byte
+const marshaledMagic = "f4\x01"
+
+// Size of a marshaled digest state
+const marshaledSize = len(marshaledMagic) + Size
+
+// MarshalBinary implements encoding.BinaryMarshaler like the hashes of the standard library do, so hashing can
+// be stopped, the state saved, and resumed later with UnmarshalBinary. The state only holds the 4 words, the
+// caller keeps track of the offset reached.
+func (d *digest) MarshalBinary() ([]byte, error) {
+	return d.AppendBinary(make([]byte, 0, marshaledSize))
+}
+
+// AppendBinary appends the state MarshalBinary returns to b.
+func (d *digest) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, marshaledMagic...)
+	return d.Sum(b), nil
+}
+
+// UnmarshalBinary restores a state returned by MarshalBinary.
+func (d *digest) UnmarshalBinary(b []byte) error {
+	if len(b) < len(marshaledMagic) || string(b[:len(marshaledMagic)]) != marshaledMagic {
+		return errors.New("fletcher4: invalid hash state identifier")
+	}
+	if len(b) != marshaledSize {
+		return errors.New("fletcher4: invalid hash state size")
+	}
+	b = b[len(marshaledMagic):]
+	for i := range d {
+		d[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return nil
+}
@@ -16,8 +16,11 @@ package fletcher4
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
+	"io"
 	"testing"
+	"testing/iotest"
 )
 
 type hexRes [4]string
@@ -79,3 +82,120 @@ func TestChecksummer2(t *testing.T) {
 		t.Errorf("Checksum Sum method call 2 returned wrong result.\nExpected %x,\ngot: %x)", sum, expSum2)
 	}
 }
+
+// Test a known answer for a 1 MiB buffer, large enough that every kernel is exercised and the
+// sums wrap around. Must pass both in the default and the purego build.
+func TestChecksummerLarge(t *testing.T) {
+	inp := make([]byte, 1<<20)
+	for i := range inp {
+		inp[i] = byte(i*7 + i>>8)
+	}
+	exp := hexRes{"1fffffffe0000", "140bc3ebf0000", "dd6e623f3f6a0000", "1ad930e9d12f8000"}
+
+	checksummer := New()
+	if _, err := checksummer.Write(inp); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, "Checksum test large, 1 MiB written using "+Implementation()+" failed", exp, checksummer.Sum64x4())
+}
+
+// Test that neither Write nor Sum allocate, with every implementation
+func TestChecksummerZeroAllocs(t *testing.T) {
+	defer SetImplementation(Implementation())
+
+	inp := make([]byte, 64<<10)
+	sum := make([]byte, 0, Size)
+	for _, name := range Implementations() {
+		if err := SetImplementation(name); err != nil {
+			t.Fatal(err)
+		}
+		checksummer := New()
+		allocs := testing.AllocsPerRun(100, func() {
+			checksummer.Write(inp)
+			sum = checksummer.Sum(sum[:0])
+		})
+		if allocs != 0 {
+			t.Errorf("Write and Sum using %v allocated %v times, expected none", name, allocs)
+		}
+	}
+}
+
+// Benchmark writes of different sizes with every implementation
+func BenchmarkWrite(b *testing.B) {
+	defer SetImplementation(Implementation())
+
+	for _, name := range Implementations() {
+		for _, size := range []int{64, 4 << 10, 128 << 10} {
+			b.Run(fmt.Sprintf("%v/%v", name, size), func(b *testing.B) {
+				if err := SetImplementation(name); err != nil {
+					b.Fatal(err)
+				}
+				inp := make([]byte, size)
+				checksummer := New()
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					checksummer.Write(inp)
+				}
+			})
+		}
+	}
+}
+
+// Benchmark Sum into a buffer with enough capacity, which must not allocate
+func BenchmarkSum(b *testing.B) {
+	checksummer := New()
+	sum := make([]byte, 0, Size)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sum = checksummer.Sum(sum[:0])
+	}
+}
+
+// Test that io.Copy to a checksummer uses ReadFrom, and handles reads not ending on whole words
+func TestChecksummerReadFrom(t *testing.T) {
+	inp := randomBytes(30001)
+	checksummer := New()
+	if _, ok := checksummer.(io.ReaderFrom); !ok {
+		t.Fatal("Checksummer does not implement io.ReaderFrom")
+	}
+	if _, err := checksummer.Write(inp[:8]); err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(checksummer, iotest.OneByteReader(bytes.NewReader(inp[8:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(inp)-8) {
+		t.Errorf("Copied %v bytes, expected %v", n, len(inp)-8)
+	}
+	if exp := paddedChecksum(inp); Checksum(checksummer.Sum64x4()) != exp {
+		t.Errorf("ReadFrom:\nexpected\t%x,\ngot\t\t%x", exp, checksummer.Sum64x4())
+	}
+}
+
+// Test that the state of a checksummer can be marshaled and hashing resumed from it
+func TestChecksummerMarshal(t *testing.T) {
+	inp := randomBytes(4096)
+	checksummer := New()
+	checksummer.Write(inp[:1024])
+	state, err := checksummer.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := New()
+	if err := resumed.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Write(inp[1024:])
+	if exp := paddedChecksum(inp); Checksum(resumed.Sum64x4()) != exp {
+		t.Errorf("Resumed checksum is %x, expected %x", resumed.Sum64x4(), exp)
+	}
+
+	for _, bad := range [][]byte{nil, state[:len(state)-1], append([]byte("xx"), state[2:]...)} {
+		if err := resumed.(encoding.BinaryUnmarshaler).UnmarshalBinary(bad); err == nil {
+			t.Errorf("Unmarshaling state %x succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+	"lukechampine.com/blake3"
+)
+
+// hashes are the algorithms --also computes besides fletcher4, by name.
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake3": func() hash.Hash { return blake3.New(32, nil) },
+}
+
+// algorithms is the comma separated list of hashes given with --also.
+type algorithms []string
+
+func (a *algorithms) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *algorithms) Set(value string) error {
+	*a = nil
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if hashes[name] == nil {
+			return fmt.Errorf("unknown hash %q", name)
+		}
+		if !slices.Contains(*a, name) {
+			*a = append(*a, name)
+		}
+	}
+	return nil
+}
+
+// hashAlso hashes the file at path, or standard input for stdinName, with fletcher4 and the --also hashes in
+// one pass.
+func (c *command) hashAlso(path string) result {
+	res := result{Path: path}
+	start := time.Now()
+	r, err := c.openProgress(path, &res)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer r.Close()
+
+	hs := make([]hash.Hash, len(c.also))
+	for i, name := range c.also {
+		hs[i] = hashes[name]()
+	}
+	m := fletcher4.NewMultiHasher(hs...)
+	res.Size, res.Err = m.ReadFrom(r)
+	if res.Err != nil {
+		res.Err = fmt.Errorf("%v: %w", path, res.Err)
+	}
+	res.Checksum, res.Digests = m.Checksum(), m.Sums()
+	res.Duration = time.Since(start)
+	c.progress.finished(path, res.Size)
+	return res
+}
+
+// openProgress opens the file at path, or standard input for stdinName, to be read through a progressReader,
+// and sets the modification time of res.
+func (c *command) openProgress(path string, res *result) (*progressReader, error) {
+	f, err := c.open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &progressReader{r: f, p: c.progress, path: path, start: time.Now(), total: -1}
+	if f, ok := f.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			res.ModTime = fi.ModTime()
+			if fi.Mode().IsRegular() {
+				r.total = fi.Size()
+			}
+		}
+	}
+	return r, nil
+}
+
+// progressReader reports the progress of reading the file path to the progress bar, like the
+// fletcher4.WithProgress option does for the helpers of the package.
+type progressReader struct {
+	r     io.ReadCloser
+	p     *progressBar
+	path  string
+	start time.Time
+	total int64
+	done  int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.done += int64(n)
+	if r.p != nil && n > 0 {
+		elapsed := time.Since(r.start)
+		r.p.update(r.path, fletcher4.Progress{Done: r.done, Total: r.total, Elapsed: elapsed,
+			Rate: float64(r.done) / elapsed.Seconds()})
+	}
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	return r.r.Close()
+}
+
+// formatDigest returns the BSD style line of the digest of the file name made by the --also hash alg.
+func (c *command) formatDigest(alg string, digest []byte, name string) string {
+	escaped, prefix := escape(name)
+	if c.zero {
+		escaped, prefix = name, ""
+	}
+	sum := hex.EncodeToString(digest)
+	if c.sumFormat == "upper" {
+		sum = strings.ToUpper(sum)
+	}
+	return prefix + strings.ToUpper(alg) + " (" + escaped + ") = " + sum
+}
+
+// otherDigest reports whether line is the BSD style line of a digest made by one of the --also hashes, which
+// check mode skips.
+func otherDigest(line string) bool {
+	alg, _, ok := strings.Cut(strings.TrimPrefix(line, "\\"), " (")
+	return ok && hashes[strings.ToLower(alg)] != nil
+}
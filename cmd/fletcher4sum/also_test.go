@@ -0,0 +1,60 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lukechampine.com/blake3"
+)
+
+// Test that the --also digests are printed after the fletcher4 checksum, and skipped in check mode
+func TestAlso(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "hello world"})
+	sha := sha256.Sum256([]byte("hello world"))
+	b3 := blake3.Sum256([]byte("hello world"))
+	status, stdout, _ := fletcher4sum("", "--also", "sha256,blake3", "a")
+	exp := "FLETCHER4 (a) = " + sumOf("hello world") + "\nSHA256 (a) = " + hex.EncodeToString(sha[:]) +
+		"\nBLAKE3 (a) = " + hex.EncodeToString(b3[:]) + "\n"
+	if status != 0 || stdout != exp {
+		t.Errorf("Hashing with --also gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	if status, out, _ := fletcher4sum("hello world", "--also", "sha256"); status != 0 || !strings.Contains(out, hex.EncodeToString(sha[:])) {
+		t.Errorf("Hashing stdin with --also gave status %v, %q", status, out)
+	}
+
+	if status, out, stderr := fletcher4sum(exp, "-c", "-w"); status != 0 || out != "a: OK\n" || stderr != "" {
+		t.Errorf("Checking --also output gave status %v, %q, %q", status, out, stderr)
+	}
+
+	_, stdout, _ = fletcher4sum("", "--also", "sha256", "--format", "jsonl", "a")
+	var rec record
+	if err := json.Unmarshal([]byte(stdout), &rec); err != nil || rec.Digests["sha256"] != hex.EncodeToString(sha[:]) {
+		t.Errorf("JSON output %q has digests %v, %v", stdout, rec.Digests, err)
+	}
+	if _, stdout, _ = fletcher4sum("", "--also", "sha256", "--format", "csv", "a"); !strings.HasPrefix(stdout, "path,size,mtime,checksum,duration,error,sha256\n") {
+		t.Errorf("CSV output is %q", stdout)
+	}
+
+	for _, args := range [][]string{{"--also", "crc32", "a"}, {"--also", "sha256", "-c", "a"}, {"--also", "sha256", "--resume", "a"}} {
+		if status, _, _ := fletcher4sum("", args...); status != 2 {
+			t.Errorf("%q gave status %v", args, status)
+		}
+	}
+}
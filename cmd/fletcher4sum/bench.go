@@ -0,0 +1,167 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// sizes is a flag.Value holding a comma separated list of buffer sizes, with optional K, M or G suffixes.
+type sizes []int
+
+func (s *sizes) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *sizes) Set(value string) error {
+	*s = nil
+	for _, field := range strings.Split(value, ",") {
+		n, err := parseSize(field)
+		if err != nil {
+			return err
+		}
+		*s = append(*s, n)
+	}
+	return nil
+}
+
+// parseSize parses a positive size in bytes, e.g. 4096, 64K or 16M.
+func parseSize(s string) (int, error) {
+	shift := 0
+	if i := len(s) - 1; i > 0 {
+		switch s[i] {
+		case 'K', 'k':
+			shift = 10
+		case 'M', 'm':
+			shift = 20
+		case 'G', 'g':
+			shift = 30
+		}
+		if shift > 0 {
+			s = s[:i]
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > 1<<30>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
+
+// bench runs the bench subcommand, which measures the throughput of every implementation available on this cpu
+// at several buffer sizes, and returns the exit status.
+func (c *command) bench(args []string) int {
+	flags := flag.NewFlagSet("fletcher4sum bench", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: fletcher4sum bench [-time DURATION] [-sizes SIZE,...]")
+		flags.PrintDefaults()
+	}
+	duration := flags.Duration("time", 200*time.Millisecond, "time to measure each implementation and size for")
+	bufSizes := sizes{4 << 10, 64 << 10, 1 << 20, 16 << 20}
+	flags.Var(&bufSizes, "sizes", "comma separated buffer `sizes`, with optional K, M or G suffixes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	buf := make([]byte, slices.Max(bufSizes))
+	for i := range buf {
+		buf[i] = byte(i * 7)
+	}
+	var reference []fletcher4.Checksum
+	for _, n := range bufSizes {
+		reference = append(reference, fletcher4.Checksum(scalarSum(buf[:n])))
+	}
+
+	// Measuring selects each implementation in turn
+	current := fletcher4.Implementation()
+	defer fletcher4.SetImplementation(current)
+	tw := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "implementation\t")
+	for _, n := range bufSizes {
+		fmt.Fprintf(tw, "%v\t", formatBytes(float64(n)))
+	}
+	fmt.Fprintln(tw)
+	status := 0
+	for _, name := range fletcher4.Implementations() {
+		if err := fletcher4.SetImplementation(name); err != nil {
+			c.errorf("%v", err)
+			return 1
+		}
+		label := name
+		if name == current {
+			label += " *"
+		}
+		fmt.Fprintf(tw, "%v\t", label)
+		for i, n := range bufSizes {
+			rate, sum := measure(buf[:n], *duration)
+			if sum != reference[i] {
+				fmt.Fprint(tw, "MISMATCH\t")
+				status = 1
+				continue
+			}
+			fmt.Fprintf(tw, "%.2f GB/s\t", rate/1e9)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "\n* in use\n")
+	if err := tw.Flush(); err != nil {
+		c.errorf("%v", err)
+		return 1
+	}
+	if status != 0 {
+		c.errorf("implementations disagree with the scalar one, do not rely on them")
+	}
+	return status
+}
+
+// measure hashes p repeatedly for at least duration, and returns the throughput in bytes per second and the
+// checksum of p.
+func measure(p []byte, duration time.Duration) (float64, fletcher4.Checksum) {
+	h := fletcher4.New()
+	h.Write(p)
+	sum := fletcher4.Checksum(h.Sum64x4())
+	n := 0
+	start := time.Now()
+	elapsed := time.Duration(0)
+	for elapsed < duration || n == 0 {
+		h.Write(p)
+		n += len(p)
+		elapsed = time.Since(start)
+	}
+	return float64(n) / elapsed.Seconds(), sum
+}
+
+// scalarSum returns the checksum of p computed by the scalar reference implementation.
+func scalarSum(p []byte) [4]uint64 {
+	current := fletcher4.Implementation()
+	defer fletcher4.SetImplementation(current)
+	fletcher4.SetImplementation("scalar")
+	h := fletcher4.New()
+	h.Write(p)
+	return h.Sum64x4()
+}
@@ -0,0 +1,60 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that bench prints a line for every available implementation, marking the one in use
+func TestBench(t *testing.T) {
+	status, stdout, stderr := fletcher4sum("", "bench", "-time", "1ms", "-sizes", "4K,64k")
+	if status != 0 {
+		t.Fatalf("Bench gave status %v: %v", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != len(fletcher4.Implementations())+3 || !strings.Contains(lines[0], "64.0 KiB") {
+		t.Fatalf("Bench printed %q", stdout)
+	}
+	for i, name := range fletcher4.Implementations() {
+		fields := strings.Fields(lines[i+1])
+		if fields[0] != name || strings.Count(lines[i+1], "GB/s") != 2 {
+			t.Errorf("Bench line %q, expected %v", lines[i+1], name)
+		}
+		if (len(fields) == 6) != (name == fletcher4.Implementation()) {
+			t.Errorf("Bench line %q marks the wrong implementation in use", lines[i+1])
+		}
+	}
+
+	if status, _, _ := fletcher4sum("", "bench", "-sizes", "4X"); status != 2 {
+		t.Errorf("Bench with invalid size gave status %v", status)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, exp := range map[string]int{"4096": 4096, "4K": 4 << 10, "16m": 16 << 20, "1G": 1 << 30} {
+		if n, err := parseSize(s); err != nil || n != exp {
+			t.Errorf("parseSize(%q) = %v, %v, expected %v", s, n, err, exp)
+		}
+	}
+	for _, s := range []string{"", "K", "0", "-1", "2G", "4X"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) succeeded", s)
+		}
+	}
+}
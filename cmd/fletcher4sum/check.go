@@ -0,0 +1,190 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Undoes nameEscaper
+var nameUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r")
+
+// parseLine parses a checksum line in either style, and returns the checksum and the file name.
+func parseLine(line string) (fletcher4.Checksum, string, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+	var hex, name string
+	if rest, ok := strings.CutPrefix(line, tagName+" ("); ok {
+		i := strings.LastIndex(rest, ") = ")
+		if i < 0 {
+			return fletcher4.Checksum{}, "", false
+		}
+		name, hex = rest[:i], rest[i+len(") = "):]
+	} else {
+		// The checksum, a space, and a space for text or an asterisk for binary mode, which are the same here
+		var ok bool
+		if hex, name, ok = strings.Cut(line, " "); !ok || name == "" || (name[0] != ' ' && name[0] != '*') {
+			return fletcher4.Checksum{}, "", false
+		}
+		name = name[1:]
+	}
+	sum, err := parseSum(hex)
+	if err != nil || name == "" {
+		return fletcher4.Checksum{}, "", false
+	}
+	if escaped {
+		name = nameUnescaper.Replace(name)
+	}
+	return sum, name, true
+}
+
+// checkResult counts the outcome of checking checksum files.
+type checkResult struct {
+	lines     int
+	malformed int
+	failed    int
+	unread    int
+	// Files matching their checksums
+	verified int
+}
+
+// checkFile verifies the files listed in the checksum file at path, and returns the exit status.
+func (c *command) checkFile(path string) int {
+	f, err := c.open(path)
+	if err != nil {
+		c.errorf("%v", err)
+		return 1
+	}
+	defer f.Close()
+	var res checkResult
+	if err := c.checkLines(path, f, &res); err != nil {
+		c.errorf("%v: %v", path, err)
+		return 1
+	}
+
+	if res.lines == 0 {
+		c.errorf("%v: no properly formatted fletcher4 checksum lines found", path)
+		return 1
+	}
+	if !c.statusOnly {
+		if res.malformed > 0 {
+			c.errorf("WARNING: %v", plural(res.malformed, "line is", "lines are", "improperly formatted"))
+		}
+		if res.unread > 0 {
+			c.errorf("WARNING: %v", plural(res.unread, "listed file", "listed files", "could not be read"))
+		}
+		if res.failed > 0 {
+			c.errorf("WARNING: %v", plural(res.failed, "computed checksum", "computed checksums", "did NOT match"))
+		}
+		if c.ignoreMissing && res.verified == 0 {
+			c.errorf("%v: no file was verified", path)
+		}
+	}
+	if res.failed > 0 || res.unread > 0 || res.verified == 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkLine is the outcome of a line of a checksum file, a file checked or an improperly formatted line.
+type checkLine struct {
+	result
+	// Number of the line if it is improperly formatted, zero otherwise
+	malformed int
+}
+
+// checkLines verifies every line read from r, of the checksum file path. The outcomes are reported in the order
+// of the lines, warnings about improperly formatted lines included.
+func (c *command) checkLines(path string, r io.Reader, res *checkResult) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Split(c.splitLines)
+	lines := runOrdered(context.Background(), c.jobs, func(submit func(func() checkLine) bool) {
+		for line := 1; scanner.Scan(); line++ {
+			expected, name, ok := parseLine(scanner.Text())
+			if !ok && (otherDigest(scanner.Text()) || pieceLine(scanner.Text())) {
+				continue
+			}
+			if !ok {
+				line := line
+				submit(func() checkLine { return checkLine{malformed: line} })
+				continue
+			}
+			submit(func() checkLine {
+				r := c.hash(name)
+				r.Expected = expected
+				return checkLine{result: r}
+			})
+		}
+	})
+	for l := range lines {
+		c.progress.clear()
+		if l.malformed > 0 {
+			if c.warn {
+				c.errorf("%v: %v: improperly formatted fletcher4 checksum line", path, l.malformed)
+			}
+			res.malformed++
+			continue
+		}
+		res.lines++
+		r := l.result
+		c.remember(r.Path, r.Expected)
+		switch {
+		case c.ignoreMissing && errors.Is(r.Err, fs.ErrNotExist):
+		case r.Err != nil:
+			c.failure(r, r.Err.Error())
+			c.report(r.Path, "FAILED open or read")
+			res.unread++
+		case r.Checksum != r.Expected:
+			if !c.statusOnly {
+				c.failure(r, "")
+			}
+			c.report(r.Path, "FAILED")
+			res.failed++
+		default:
+			c.report(r.Path, "OK")
+			res.verified++
+		}
+	}
+	return scanner.Err()
+}
+
+// report prints the outcome of checking the file name, unless --status is given, or it is OK and --quiet is.
+func (c *command) report(name, outcome string) {
+	if c.statusOnly || c.quiet && outcome == "OK" {
+		return
+	}
+	escaped, prefix := escape(name)
+	fmt.Fprintf(c.stdout, "%v%v: %v\n", prefix, escaped, outcome)
+}
+
+// plural returns "1 <one> <rest>" or "n <many> <rest>", like the coreutils warnings.
+func plural(n int, one, many, rest string) string {
+	if n == 1 {
+		return fmt.Sprintf("%v %v %v", n, one, rest)
+	}
+	return fmt.Sprintf("%v %v %v", n, many, rest)
+}
@@ -0,0 +1,123 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test that lines of both styles and of sha256sum's binary mode parse, with escaped names
+func TestParseLine(t *testing.T) {
+	hex := sumOf("x")
+	for _, test := range []struct {
+		line, name string
+	}{
+		{hex + "  file", "file"},
+		{hex + " *file", "file"},
+		{hex + "  two  spaces", "two  spaces"},
+		{"\\" + hex + "  new\\nline", "new\nline"},
+		{"FLETCHER4 (a) = b) = " + hex, "a) = b"},
+		{"\\FLETCHER4 (back\\\\slash) = " + hex + "\r", "back\\slash"},
+	} {
+		sum, name, ok := parseLine(test.line)
+		if !ok || name != test.name || sum.String() != hex {
+			t.Errorf("Line %q parsed as %v, %q, %v", test.line, sum, name, ok)
+		}
+	}
+	for _, line := range []string{"", hex, hex + "  ", hex + "-file", "abc  file", "SHA256 (file) = " + hex} {
+		if _, _, ok := parseLine(line); ok {
+			t.Errorf("Invalid line %q parsed", line)
+		}
+	}
+}
+
+// Test that check mode reports every file, sums up the failures and sets the exit status
+func TestCheck(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "hello", "b": "world"})
+	sums := sumOf("hello") + "  a\n" + "FLETCHER4 (b) = " + sumOf("world") + "\n"
+	if err := os.WriteFile("SUMS", []byte(sums), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, stdout, stderr := fletcher4sum("", "-c", "SUMS"); status != 0 || stdout != "a: OK\nb: OK\n" || stderr != "" {
+		t.Errorf("Check gave status %v, %q, %q", status, stdout, stderr)
+	}
+
+	bad := sums + sumOf("other") + "  a\nmalformed\n" + sumOf("") + "  missing\n"
+	if err := os.WriteFile("SUMS", []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := fletcher4sum("", "--check", "SUMS")
+	if exp := "a: OK\nb: OK\na: FAILED\nmissing: FAILED open or read\n"; status != 1 || stdout != exp {
+		t.Errorf("Check of bad sums gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	for _, warning := range []string{"1 line is improperly formatted", "1 listed file could not be read",
+		"1 computed checksum did NOT match"} {
+		if !strings.Contains(stderr, "WARNING: "+warning) {
+			t.Errorf("Warnings %q lack %q", stderr, warning)
+		}
+	}
+
+	if err := os.WriteFile("SUMS", []byte("nothing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, stderr := fletcher4sum("", "-c", "SUMS"); status != 1 || !strings.Contains(stderr, "no properly formatted") {
+		t.Errorf("Check of file without checksums gave status %v, %q", status, stderr)
+	}
+}
+
+// Test the --quiet, --status, --warn and --ignore-missing options of sha256sum
+func TestCheckOptions(t *testing.T) {
+	sums := sumOf("abc") + "  a\n" + sumOf("def") + "  b\n" + "garbage\n" + sumOf("x") + "  missing\n"
+	writeFiles(t, map[string]string{"a": "abc", "b": "changed", "SUMS": sums})
+
+	status, stdout, stderr := fletcher4sum("", "-c", "--quiet", "SUMS")
+	if status != 1 || stdout != "b: FAILED\nmissing: FAILED open or read\n" || !strings.Contains(stderr, "did NOT match") {
+		t.Errorf("Check with --quiet gave status %v, %q, %q", status, stdout, stderr)
+	}
+	status, stdout, stderr = fletcher4sum("", "-c", "--status", "SUMS")
+	if status != 1 || stdout != "" || strings.Contains(stderr, "WARNING") || !strings.Contains(stderr, "missing") {
+		t.Errorf("Check with --status gave status %v, %q, %q", status, stdout, stderr)
+	}
+	status, _, stderr = fletcher4sum("", "-c", "-w", "SUMS")
+	if status != 1 || !strings.Contains(stderr, "fletcher4sum: SUMS: 3: improperly formatted fletcher4 checksum line\n") {
+		t.Errorf("Check with -w gave status %v, %q", status, stderr)
+	}
+
+	// Warnings come in the order of the lines, among the outcomes of the files
+	var out bytes.Buffer
+	os.WriteFile("SUMS", []byte(sumOf("abc")+"  a\ngarbage\n"+sumOf("changed")+"  b\n"), 0o644)
+	status = run([]string{"-c", "-w", "-j", "4", "SUMS"}, strings.NewReader(""), &out, &out)
+	if exp := "a: OK\nfletcher4sum: SUMS: 2: improperly formatted fletcher4 checksum line\nb: OK\n"; status != 0 || !strings.HasPrefix(out.String(), exp) {
+		t.Errorf("Check with -w and -j gave status %v, %q, expected it to start with %q", status, out.String(), exp)
+	}
+
+	os.WriteFile("SUMS", []byte(sumOf("abc")+"  a\n"+sumOf("x")+"  missing\n"), 0o644)
+	status, stdout, stderr = fletcher4sum("", "-c", "--ignore-missing", "SUMS")
+	if status != 0 || stdout != "a: OK\n" || stderr != "" {
+		t.Errorf("Check with --ignore-missing gave status %v, %q, %q", status, stdout, stderr)
+	}
+	os.WriteFile("SUMS", []byte(sumOf("x")+"  missing\n"), 0o644)
+	status, _, stderr = fletcher4sum("", "-c", "--ignore-missing", "SUMS")
+	if status != 1 || stderr != "fletcher4sum: SUMS: no file was verified\n" {
+		t.Errorf("Check with --ignore-missing and no file verified gave status %v, %q", status, stderr)
+	}
+
+	if status, _, _ := fletcher4sum("", "--quiet", "a"); status != 2 {
+		t.Errorf("--quiet without -c gave status %v", status)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/manifest"
+)
+
+// diff runs the diff subcommand, which compares two files block by block or two directory trees by their
+// manifests, and returns the exit status: 0 if they are identical, 1 if they differ and 2 on errors.
+func (c *command) diff(args []string) int {
+	flags := flag.NewFlagSet("fletcher4sum diff", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: fletcher4sum diff [-block-size SIZE] A B")
+		flags.PrintDefaults()
+	}
+	blockSize := 128 << 10
+	flags.Func("block-size", "compare files in blocks of `size` bytes, with optional K, M or G suffix (default 128K)", func(s string) error {
+		n, err := parseSize(s)
+		if err == nil && n%fletcher4.BlockSize != 0 {
+			err = fmt.Errorf("block size %v is not a multiple of %v", n, fletcher4.BlockSize)
+		}
+		blockSize = n
+		return err
+	})
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	a, b := flags.Arg(0), flags.Arg(1)
+	fa, err := os.Stat(osPath(a))
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	fb, err := os.Stat(osPath(b))
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	switch {
+	case fa.IsDir() && fb.IsDir():
+		return c.diffTrees(a, b)
+	case fa.IsDir() || fb.IsDir():
+		c.errorf("cannot compare a file with a directory: %v, %v", a, b)
+		return 2
+	}
+	return c.diffFiles(a, b, blockSize)
+}
+
+// diffFiles compares the files a and b block by block, printing the offset of every block whose checksum
+// differs.
+func (c *command) diffFiles(a, b string, blockSize int) int {
+	fa, err := os.Open(osPath(a))
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	defer fa.Close()
+	fb, err := os.Open(osPath(b))
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, blockSize), make([]byte, blockSize)
+	var offset, sizeA, sizeB, first int64 = 0, 0, 0, -1
+	blocks, differing := 0, 0
+	for {
+		na, errA := readBlock(fa, bufA)
+		nb, errB := readBlock(fb, bufB)
+		if err := errors.Join(errA, errB); err != nil {
+			c.errorf("%v", err)
+			return 2
+		}
+		if na == 0 && nb == 0 {
+			break
+		}
+		sizeA += int64(na)
+		sizeB += int64(nb)
+		// Blocks of different lengths differ even if the shorter one only lacks trailing zeros
+		sumA, sumB := fletcher4.Sum(bufA[:na]), fletcher4.Sum(bufB[:nb])
+		if na != nb || sumA != sumB {
+			fmt.Fprintf(c.stdout, "block %v at offset %v differs: %v %v\n", blocks, offset, describeBlock(sumA, na), describeBlock(sumB, nb))
+			differing++
+			if first < 0 {
+				first = offset
+			}
+		}
+		blocks++
+		offset += int64(blockSize)
+	}
+	if differing == 0 {
+		return 0
+	}
+	if sizeA != sizeB {
+		fmt.Fprintf(c.stdout, "%v is %v bytes, %v is %v bytes\n", a, sizeA, b, sizeB)
+	}
+	fmt.Fprintf(c.stdout, "%v and %v differ in %v of %v blocks, first at offset %v\n", a, b, differing, blocks, first)
+	return 1
+}
+
+// readBlock reads a block into buf, returning less than a whole block only at the end of the file.
+func readBlock(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return n, err
+}
+
+// describeBlock returns the checksum of a block of n bytes, or "missing" past the end of a file.
+func describeBlock(sum fletcher4.Checksum, n int) string {
+	if n == 0 {
+		return "missing"
+	}
+	return sum.String()
+}
+
+// diffTrees compares the regular files of the directory trees a and b, printing the files found in only one of
+// them and the files differing.
+func (c *command) diffTrees(a, b string) int {
+	m, err := manifest.Generate(os.DirFS(a))
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	res, err := manifest.Verify(os.DirFS(b), m)
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	for _, mm := range res.Mismatches {
+		switch mm.Problem {
+		case manifest.Missing:
+			fmt.Fprintf(c.stdout, "%v: only in %v\n", mm.Path, a)
+		case manifest.Extra:
+			fmt.Fprintf(c.stdout, "%v: only in %v\n", mm.Path, b)
+		default:
+			fmt.Fprintln(c.stdout, mm)
+		}
+	}
+	if !res.OK() {
+		fmt.Fprintf(c.stdout, "%v and %v differ in %v files, %v identical\n", a, b, len(res.Mismatches), res.Verified)
+		return 1
+	}
+	return 0
+}
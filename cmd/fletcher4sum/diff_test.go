@@ -0,0 +1,72 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that files are compared block by block, reporting every differing block
+func TestDiffFiles(t *testing.T) {
+	data := strings.Repeat("a", 10<<10)
+	changed := []byte(data)
+	changed[5000] = 'b'
+	changed[9000] = 'b'
+	writeFiles(t, map[string]string{"a": data, "b": data, "c": string(changed), "short": data[:4096]})
+
+	if status, stdout, _ := fletcher4sum("", "diff", "a", "b"); status != 0 || stdout != "" {
+		t.Errorf("Diff of identical files gave status %v, %q", status, stdout)
+	}
+	status, stdout, _ := fletcher4sum("", "diff", "-block-size", "4K", "a", "c")
+	lines := strings.Split(stdout, "\n")
+	if status != 1 || len(lines) != 4 || !strings.HasPrefix(lines[0], "block 1 at offset 4096 differs: ") ||
+		!strings.HasPrefix(lines[1], "block 2 at offset 8192 differs: ") || lines[2] != "a and c differ in 2 of 3 blocks, first at offset 4096" {
+		t.Errorf("Diff of changed files gave status %v, %q", status, stdout)
+	}
+	status, stdout, _ = fletcher4sum("", "diff", "-block-size", "4K", "a", "short")
+	if status != 1 || !strings.Contains(stdout, "block 2 at offset 8192 differs: ") || !strings.Contains(stdout, " missing\n") ||
+		!strings.Contains(stdout, "a is 10240 bytes, short is 4096 bytes\n") {
+		t.Errorf("Diff of truncated file gave status %v, %q", status, stdout)
+	}
+
+	for _, args := range [][]string{{"a"}, {"a", "missing"}, {"a", "."}, {"-block-size", "3", "a", "b"}} {
+		if status, _, _ := fletcher4sum("", append([]string{"diff"}, args...)...); status != 2 {
+			t.Errorf("Diff %q gave status %v", args, status)
+		}
+	}
+}
+
+// Test that directory trees are compared file by file
+func TestDiffTrees(t *testing.T) {
+	writeFiles(t, nil)
+	for name, data := range map[string]string{"a/same": "x", "a/sub/changed": "old", "a/gone": "", "b/same": "x", "b/sub/changed": "new", "b/new": ""} {
+		os.MkdirAll(filepath.Dir(name), 0o755)
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status, stdout, _ := fletcher4sum("", "diff", "a", "b")
+	exp := "gone: only in a\nnew: only in b\nsub/changed: checksum changed from " + sumOf("old") + " to " + sumOf("new") +
+		"\na and b differ in 3 files, 1 identical\n"
+	if status != 1 || stdout != exp {
+		t.Errorf("Diff of trees gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	if status, stdout, _ := fletcher4sum("", "diff", "a", "a"); status != 0 || stdout != "" {
+		t.Errorf("Diff of a tree with itself gave status %v, %q", status, stdout)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// readFiles reads the file names given with --files-from from the file at path, or standard input for
+// stdinName, one per line or, with -0, ending with NUL as find -print0 writes them. Names are taken as they are,
+// holding spaces or newlines, and empty ones are skipped.
+func (c *command) readFiles(path string, null bool) ([]string, error) {
+	f, err := c.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sep := byte('\n')
+	if null {
+		sep = 0
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Split(splitOn(sep))
+	var names []string
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			names = append(names, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return names, nil
+}
@@ -0,0 +1,41 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+// Test that the files named in a list are hashed, with names holding spaces and newlines when NUL terminated
+func TestFilesFrom(t *testing.T) {
+	writeFiles(t, map[string]string{"a b": "spaced", "c\nd": "broken", "e": "plain", "list": "e\n\na b\n"})
+	exp := sumOf("spaced") + "  a b\n\\" + sumOf("broken") + "  c\\nd\n"
+	if status, stdout, _ := fletcher4sum("a b\x00c\nd\x00", "--files-from=-", "-0"); status != 0 || stdout != exp {
+		t.Errorf("Hashing NUL terminated names gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	exp = sumOf("plain") + "  e\n" + sumOf("spaced") + "  a b\n"
+	if status, stdout, _ := fletcher4sum("", "--files-from", "list"); status != 0 || stdout != exp {
+		t.Errorf("Hashing names from a file gave status %v, %q, expected %q", status, stdout, exp)
+	}
+
+	if status, _, _ := fletcher4sum("", "--files-from", "missing"); status != 1 {
+		t.Errorf("Missing list gave status %v", status)
+	}
+	for _, args := range [][]string{{"-0", "e"}, {"--files-from", "list", "e"}} {
+		if status, _, _ := fletcher4sum("", args...); status != 2 {
+			t.Errorf("%q gave status %v", args, status)
+		}
+	}
+}
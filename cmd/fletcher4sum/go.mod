@@ -0,0 +1,12 @@
+module go.solidsystem.no/fletcher4/cmd/fletcher4sum
+
+go 1.21.1
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	go.solidsystem.no/fletcher4 v0.1.0
+	golang.org/x/sys v0.25.0
+	lukechampine.com/blake3 v1.3.0
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.9 // indirect
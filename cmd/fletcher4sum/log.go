@@ -0,0 +1,67 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// setLogFormat sets how errors and verification failures are written to stderr, as free form text or as JSON
+// records of log/slog.
+func (c *command) setLogFormat(format string) error {
+	switch format {
+	case "text":
+		c.logger = nil
+	case "json":
+		c.logger = slog.New(slog.NewJSONHandler(c.stderr, nil))
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// failure reports the file of res failing verification, either unreadable or not having its expected checksum.
+// In text mode the message text is written, if any, as the outcome is printed on stdout already. With
+// --log-format json a record is logged with the path, the offset and size of the data the checksum covers, the
+// expected and actual checksums, and the error.
+func (c *command) failure(res result, text string) {
+	if c.logger == nil {
+		if text != "" {
+			c.errorf("%v", text)
+		}
+		return
+	}
+	c.progress.clear()
+	attrs := []any{slog.String("path", res.Path), slog.Int64("offset", 0), slog.Int64("size", res.Size),
+		slog.String("expected", c.formatSum(res.Expected))}
+	if res.Err != nil {
+		c.logger.Warn("file unreadable", append(attrs, slog.String("error", res.Err.Error()))...)
+		return
+	}
+	c.logger.Warn("checksum mismatch", append(attrs, slog.String("got", c.formatSum(res.Checksum)))...)
+}
+
+// logf logs a message formatted like errorf does, at the warning level if it starts with "WARNING: " and the
+// error level otherwise.
+func (c *command) logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if m, ok := strings.CutPrefix(msg, "WARNING: "); ok {
+		c.logger.Warn(m)
+		return
+	}
+	c.logger.Error(msg)
+}
@@ -0,0 +1,92 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logRecords parses the JSON records written to stderr
+func logRecords(t *testing.T, stderr string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Line %q of stderr is not JSON: %v", line, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, r["time"].(string)); err != nil {
+			t.Errorf("Record %q has no time: %v", line, err)
+		}
+		delete(r, "time")
+		records = append(records, r)
+	}
+	return records
+}
+
+// Test that check mode logs failures as JSON records
+func TestLogCheck(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "abcd", "SUMS": sumOf("efgh") + "  a\n" + sumOf("x") + "  missing\n"})
+	status, stdout, stderr := fletcher4sum("", "--log-format", "json", "-c", "SUMS")
+	if status != 1 || stdout != "a: FAILED\nmissing: FAILED open or read\n" {
+		t.Errorf("Check gave status %v, %q", status, stdout)
+	}
+	records := logRecords(t, stderr)
+	exp := []map[string]any{
+		{"level": "WARN", "msg": "checksum mismatch", "path": "a", "offset": 0.0, "size": 4.0, "expected": sumOf("efgh"), "got": sumOf("abcd")},
+		{"level": "WARN", "msg": "file unreadable", "path": "missing", "offset": 0.0, "size": 0.0, "expected": sumOf("x")},
+		{"level": "WARN", "msg": "1 listed file could not be read"},
+		{"level": "WARN", "msg": "1 computed checksum did NOT match"},
+	}
+	if len(records) != len(exp) {
+		t.Fatalf("Logged %v, expected %v", records, exp)
+	}
+	if err, _ := records[1]["error"].(string); !strings.HasPrefix(err, "open missing: ") {
+		t.Errorf("Unreadable file logged with error %q", err)
+	}
+	for i := range exp {
+		for k, v := range exp[i] {
+			if records[i][k] != v {
+				t.Errorf("Record %v has %v %v, expected %v", i, k, records[i][k], v)
+			}
+		}
+	}
+
+	if status, _, _ := fletcher4sum("", "--log-format", "xml", "a"); status != 2 {
+		t.Errorf("Unknown log format gave status %v", status)
+	}
+}
+
+// Test that scrub logs files changed without being modified as JSON records
+func TestLogScrub(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "abcd"})
+	scrub := func() (int, string, string) {
+		return fletcher4sum("", "scrub", "-once", "-interval", "0", "-state", ".state", "-skip-hidden", "-log-format", "json", ".")
+	}
+	scrub()
+	fi, _ := os.Stat("a")
+	os.WriteFile("a", []byte("abce"), 0o644)
+	os.Chtimes("a", fi.ModTime(), fi.ModTime())
+	status, _, stderr := scrub()
+	records := logRecords(t, stderr)
+	if status != 1 || len(records) != 1 || records[0]["msg"] != "checksum mismatch" || records[0]["path"] != "a" ||
+		records[0]["expected"] != sumOf("abcd") || records[0]["got"] != sumOf("abce") {
+		t.Errorf("Scrub gave status %v, logged %v", status, records)
+	}
+}
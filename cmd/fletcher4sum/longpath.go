@@ -0,0 +1,34 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "strings"
+
+// Length from which Windows needs the extended-length form of paths. It is MAX_PATH less the 12 characters of a
+// short file name that CreateDirectory requires room for, below which Go leaves paths alone too.
+const maxPath = 248
+
+// extendedPath returns the extended-length form of the absolute Windows path abs, which is not limited to
+// maxPath characters: \\?\C:\dir for drive paths and \\?\UNC\server\share\dir for UNC shares. Paths already in
+// that form, and device paths, are returned as they are. abs must be clean, with backslash separators.
+func extendedPath(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, `\\?\`), strings.HasPrefix(abs, `\\.\`):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
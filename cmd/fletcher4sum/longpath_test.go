@@ -0,0 +1,34 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+// Test turning absolute Windows paths into their extended-length form
+func TestExtendedPath(t *testing.T) {
+	for _, test := range []struct {
+		abs, exp string
+	}{
+		{`C:\data\archive`, `\\?\C:\data\archive`},
+		{`\\server\share\archive`, `\\?\UNC\server\share\archive`},
+		{`\\?\C:\data`, `\\?\C:\data`},
+		{`\\?\UNC\server\share`, `\\?\UNC\server\share`},
+		{`\\.\PhysicalDrive0`, `\\.\PhysicalDrive0`},
+	} {
+		if path := extendedPath(test.abs); path != test.exp {
+			t.Errorf("Extended-length form of %q is %q, expected %q", test.abs, path, test.exp)
+		}
+	}
+}
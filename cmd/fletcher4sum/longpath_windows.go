@@ -0,0 +1,31 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "path/filepath"
+
+// osPath returns path as passed to the operating system. Paths reaching maxPath characters once made absolute are
+// turned into their extended-length form, as Go itself only does so for absolute paths without . or ..
+// elements, and forward slashes are turned into backslashes, which extended-length paths require.
+func osPath(path string) string {
+	if path == stdinName || len(path) >= 4 && (path[:4] == `\\?\` || path[:4] == `\\.\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxPath {
+		return path
+	}
+	return extendedPath(abs)
+}
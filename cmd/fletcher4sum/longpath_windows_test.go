@@ -0,0 +1,47 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test hashing files below a directory deeper than MAX_PATH, given relative and with forward slashes
+func TestLongPath(t *testing.T) {
+	writeFiles(t, nil)
+	dir := strings.Repeat("d", 100)
+	deep := filepath.Join(dir, dir, dir)
+	for _, sub := range []string{deep, filepath.Join(deep, "skip")} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sub, "file"), []byte("deep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The pattern is matched below the directory given, with a backslash as given on Windows
+	root := filepath.ToSlash(filepath.Join(dir, dir))
+	status, stdout, stderr := fletcher4sum("", "-z", "-r", "--exclude", dir+`\skip`, root)
+	if exp := sumOf("deep") + "  " + filepath.Join(deep, "file") + "\x00"; status != 0 || stdout != exp {
+		t.Errorf("Hashing below long path gave status %v, %q, %q, expected %q", status, stdout, stderr, exp)
+	}
+	if status, stdout, _ := fletcher4sum("", filepath.Join(deep, "file")); status != 0 || !strings.HasPrefix(stdout, sumOf("deep")) {
+		t.Errorf("Hashing long path gave status %v, %q", status, stdout)
+	}
+}
@@ -0,0 +1,494 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command fletcher4sum prints the fletcher4 checksums of files, with the interface of sha256sum so it drops
+// into scripts written for the coreutils tools.
+//
+// For each file a line of the checksum, two spaces and the file name is printed:
+//
+//	fletcher4sum FILE...
+//
+// With --tag the BSD style "FLETCHER4 (FILE) = CHECKSUM" lines are printed instead, --untagged switches back to
+// the GNU style. File names holding a newline or backslash are escaped like sha256sum does, with the line
+// starting with a backslash. With -z or --zero lines end with a NUL byte instead, and names are not escaped, for
+// tools splitting on NUL like xargs -0. Check mode then reads NUL terminated lines too.
+//
+// Checksums are printed as 64 lower case hex digits of their serialized form, or upper case ones with
+// --checksum-format upper. With --checksum-format zfs the 4 words are printed in hex separated by colons, like the
+// cksum= field of zdb, to compare with checksums recorded by ZFS. Check mode accepts all of them.
+//
+// With -c the files listed in checksum files, in either style, are verified, printing "FILE: OK" or
+// "FILE: FAILED" for each and warnings summing up the failures:
+//
+//	fletcher4sum -c SUMS...
+//
+// Like for sha256sum, --quiet leaves out the OK lines, --status prints nothing but errors reading files, leaving
+// the outcome to the exit status, -w or --warn warns about every improperly formatted line, and --ignore-missing
+// skips files that do not exist rather than failing, though at least one file must be verified.
+//
+// With --xattr-verify the files given, or below them with -r, are verified against the checksums stored in their
+// user.fletcher4 extended attributes instead, so simple bit rot checks need no checksum file. A file whose data
+// changed while its modification time did not is reported as FAILED, with the time it was hashed and both
+// checksums. Files modified since they were hashed are reported as MODIFIED, and files without a stored checksum
+// are warned about, but neither fails. --quiet and --status work like in check mode.
+//
+//	fletcher4sum --xattr-verify -r /srv/archive
+//
+// --xattr-store hashes the files and stores their checksums and modification times in the extended attributes,
+// printing the checksums like hashing does. Files still having the modification time stored are skipped, so it is
+// cheap to run again after adding files, unless --force is given.
+//
+//	fletcher4sum --xattr-store -r /srv/archive
+//
+// With --also the digests of other hashes, a comma separated list of md5, sha1, sha256, sha512 and blake3, are
+// computed as well, in the same pass over the data, and printed on BSD style lines after the fletcher4 one, which
+// is tagged too. Check mode skips their lines. The machine readable formats include them by name.
+//
+//	fletcher4sum --also sha256,blake3 -r /srv/archive > SUMS
+//
+// With --piece-size the checksum of every piece of that size of each file is listed too, like the pieces of a
+// torrent, for verifying parts of files later or deciding which parts to sync. Each checksum line is followed by
+// "piece OFFSET LENGTH CHECKSUM" lines, which check mode skips, and JSON records list them as pieces.
+//
+//	fletcher4sum --piece-size 4M disk.img > disk.img.pieces
+//
+// With --files-from the files named in a file are hashed, one per line, or standard input for "-". With -0 the
+// names end with NUL instead, so the output of find -print0 is read safely whatever the names hold:
+//
+//	find /srv/archive -name '*.img' -print0 | fletcher4sum --files-from=- -0
+//
+// Standard input is read where no files are given, or for a file named "-", so the tool runs at the end of
+// pipelines:
+//
+//	zfs send pool/fs@snap | fletcher4sum
+//
+// With --format json, jsonl or csv the path, size, modification time, checksum and time taken to hash every
+// file are written in machine readable form instead, as one JSON array, one JSON object per line, or CSV with a
+// header line. Files that could not be read are included with their error.
+//
+// With -r the files in directories and their subdirectories are hashed, in lexical order. Files are selected with
+// --include and --exclude glob patterns, matched against the name, or the path below the directory given for
+// patterns holding a slash, and --skip-hidden skips names starting with a dot. Symbolic links are skipped unless
+// -L is given, which follows them.
+//
+//	fletcher4sum -r --exclude '*.tmp' --exclude .git /srv/archive > SUMS
+//
+// On Windows paths longer than MAX_PATH are handled, whether relative, absolute, on UNC shares like
+// \\server\share\archive or given in the \\?\ form already, and either slash separates the elements of
+// paths and patterns.
+//
+// While hashing, the files and bytes done, the throughput, and the percentage and ETA of the current file are
+// shown on stderr if it is a terminal, or with --progress. --no-progress hides them.
+//
+// With --resume a checkpoint of every file being hashed is saved to a state file, .fletcher4sum.state or the
+// one given with --state-file, every 30 seconds. Running the same command with --resume again after an
+// interruption skips the files already hashed and continues the others where they were, as long as they have not
+// changed. The state file is removed once all files are hashed, or verified with -c.
+//
+//	fletcher4sum --resume -c /tape/SUMS
+//
+// With --watch fletcher4sum keeps running after hashing or checking the files, and hashes them again whenever
+// they change, until interrupted. Files that no longer have the checksum they were listed with in check mode, or
+// first had otherwise, are flagged with a warning and the exit status is 1, which catches tampering and rot on
+// archive shares supposed to be immutable. Check mode reports them as FAILED, and as OK again if they are
+// restored, otherwise their new checksums are printed.
+//
+//	fletcher4sum --watch -c /srv/archive/SUMS
+//
+// With --log-format json errors and warnings are written to stderr as JSON records of log/slog instead, for
+// shipping to central logging. Files failing verification in check, watch and scrub mode are logged with their
+// path, the offset and size of the data their checksum covers, the expected and actual checksums and the time:
+//
+//	{"time":"...","level":"WARN","msg":"checksum mismatch","path":"a","offset":0,"size":4,"expected":"...","got":"..."}
+//
+// With -j files are hashed several at once, which keeps fast storage busy. Results are still printed in order.
+//
+// fletcher4sum diff compares two files block by block, printing the offset of every block whose checksum
+// differs, or two directory trees by their manifests, printing the files found in only one of them and those
+// differing, to tell whether mirror copies are identical. The exit status is 0 if they are, 1 if not.
+//
+//	fletcher4sum diff [-block-size 128K] /mnt/a/disk.img /mnt/b/disk.img
+//	fletcher4sum diff /srv/archive /mnt/mirror/archive
+//
+// fletcher4sum scrub verifies the files below the paths given in passes started every 30 days, or as often as
+// -interval says, for plain file systems what zpool scrub is for pools. The checksum, size and modification time
+// of every file are saved to a state file, .fletcher4sum-scrub.state or the one given with -state. A file whose
+// checksum changed while its size and modification time did not has rotted or been tampered with, and is reported
+// as FAILED with a warning by this and every later pass until restored. Reads are throttled to -rate bytes per
+// second, and the position of the pass is saved too, so a restarted scrub continues where it was. With -once
+// the next pass is run if due and scrub exits, for running from cron.
+//
+// With -metrics the bytes and files verified, the mismatches found, the throughput and the progress of the pass
+// are served for Prometheus on /metrics at the address given.
+//
+//	fletcher4sum scrub -interval 720h -rate 50M -metrics :9440 -state /var/lib/fletcher4/scrub.state /srv/archive
+//
+// fletcher4sum bench measures the throughput of every implementation available on the cpu at several buffer
+// sizes, marking the one in use, so operators can check that a SIMD kernel is selected before relying on it.
+// The checksums of all implementations are compared with the scalar one as well.
+//
+//	fletcher4sum bench [-time 200ms] [-sizes 4K,64K,1M,16M]
+//
+// fletcher4sum selftest checks every implementation available on the cpu against the test vectors embedded in the
+// binary, native and byte swapped, and against the scalar implementation on odd sizes and misaligned buffers, as a
+// preflight check of the tool itself. The exit status is 1 if any of them is wrong.
+//
+// A file named bench is hashed with ./bench, and likewise for diff, scrub and selftest.
+//
+// The exit status is 0 if all files were hashed, or matched their checksums, 1 if any could not be read or
+// did not match and 2 for usage errors.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// command holds the flags and streams of one invocation.
+type command struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	tag            bool
+	zero           bool
+	check          bool
+	// Verify files against the checksums in their extended attributes, or store them there
+	xattrVerify, xattrStore bool
+	// Check mode options of sha256sum
+	quiet, statusOnly, warn, ignoreMissing bool
+	// Output format of the checksums, text or one of the machine readable ones
+	output string
+	// Format of the checksums themselves, lower, upper or zfs
+	sumFormat string
+	// Hashes computed besides fletcher4, with --also
+	also algorithms
+	// Size of the pieces checksummed with --piece-size, 0 without
+	pieceSize int64
+	walk      walkOptions
+	// Number of files hashed at once
+	jobs int
+	// Progress line on stderr, nil unless shown
+	progress *progressBar
+	// Checkpoints of the files hashed, nil without --resume
+	state *stateFile
+	// Files watched for changes by their cleaned path, nil without --watch
+	watched map[string]watchedFile
+	// Logger of errors and failures with --log-format json, nil for text
+	logger *slog.Logger
+}
+
+// run runs fletcher4sum with the arguments args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (status int) {
+	c := &command{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) > 0 {
+		switch args[0] {
+		case "bench":
+			return c.bench(args[1:])
+		case "diff":
+			return c.diff(args[1:])
+		case "scrub":
+			return c.scrub(args[1:])
+		case "selftest":
+			return c.selftest(args[1:])
+		}
+	}
+	flags := flag.NewFlagSet("fletcher4sum", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fletcher4sum [--resume] [--tag|--untagged] [-z] [--format FORMAT] [-r [-L] [--include PATTERN] [--exclude PATTERN]] [FILE]...\n       fletcher4sum [--resume] -c [-z] [SUMS]...\n       fletcher4sum --xattr-verify|--xattr-store [--force] [-r] FILE...")
+		flags.PrintDefaults()
+	}
+	flags.BoolFunc("tag", "print BSD style checksum lines", func(string) error { c.tag = true; return nil })
+	flags.BoolFunc("untagged", "print GNU style checksum lines, the default", func(string) error { c.tag = false; return nil })
+	flags.BoolVar(&c.zero, "z", false, "end lines with NUL instead of newline, and do not escape file names")
+	flags.BoolVar(&c.zero, "zero", false, "same as -z")
+	flags.StringVar(&c.output, "format", "text", "output format: text, json, jsonl or csv")
+	flags.Func("checksum-format", "print checksums as `lower` or upper case hex, or zfs colon separated words", func(s string) error {
+		if s != "lower" && s != "upper" && s != "zfs" {
+			return fmt.Errorf("unknown checksum format %q", s)
+		}
+		c.sumFormat = s
+		return nil
+	})
+	flags.Var(&c.also, "also", "also compute the `hashes` given, of md5, sha1, sha256, sha512 and blake3, in the same pass")
+	flags.Func("piece-size", "also list the checksum of every piece of `size` bytes of each file, like 4M", c.setPieceSize)
+	filesFrom := flags.String("files-from", "", "hash the files named in `file`, one per line, - for standard input")
+	null := flags.Bool("0", false, "with --files-from, the names end with NUL, as find -print0 writes them")
+	flags.BoolVar(&c.walk.recursive, "r", false, "hash the files in directories and their subdirectories")
+	flags.Var(&c.walk.include, "include", "with -r, only hash files matching the glob `pattern`, may be repeated")
+	flags.Var(&c.walk.exclude, "exclude", "with -r, skip files and directories matching the glob `pattern`, may be repeated")
+	flags.BoolVar(&c.walk.skipHidden, "skip-hidden", false, "with -r, skip files and directories starting with a dot")
+	flags.BoolVar(&c.walk.follow, "L", false, "with -r, follow symbolic links, which are skipped otherwise")
+	flags.IntVar(&c.jobs, "j", 1, "hash `n` files at once, 0 for one per cpu")
+	showProgress := stderrIsTerminal(stderr)
+	flags.BoolFunc("progress", "show progress on stderr, the default if it is a terminal", func(string) error { showProgress = true; return nil })
+	flags.BoolFunc("no-progress", "do not show progress", func(string) error { showProgress = false; return nil })
+	watch := flags.Bool("watch", false, "after hashing or checking, hash files again as they change until interrupted, and flag modified ones")
+	resume := flags.Bool("resume", false, "save checkpoints while hashing, and continue an interrupted run from them")
+	statePath := flags.String("state-file", ".fletcher4sum.state", "with --resume, the `file` checkpoints are saved in")
+	flags.Func("log-format", "write errors and verification failures to stderr as `text` or json records", c.setLogFormat)
+	flags.BoolVar(&c.check, "c", false, "verify the files listed in checksum files")
+	flags.BoolVar(&c.check, "check", false, "same as -c")
+	flags.BoolVar(&c.xattrVerify, "xattr-verify", false, "verify files against the checksums stored in their extended attributes")
+	flags.BoolVar(&c.xattrStore, "xattr-store", false, "store the checksums of files in their extended attributes, skipping files unchanged since")
+	force := flags.Bool("force", false, "with --xattr-store, hash and store files unchanged since their checksum was stored too")
+	flags.BoolVar(&c.quiet, "quiet", false, "with -c or --xattr-verify, do not print OK for each file verified")
+	flags.BoolVar(&c.statusOnly, "status", false, "with -c or --xattr-verify, print nothing, the exit status tells the outcome")
+	flags.BoolVar(&c.warn, "w", false, "with -c, warn about improperly formatted checksum lines")
+	flags.BoolVar(&c.warn, "warn", false, "same as -w")
+	flags.BoolVar(&c.ignoreMissing, "ignore-missing", false, "with -c, do not fail or report status for missing files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	verifying := c.check || c.xattrVerify
+	for _, opt := range []struct {
+		name string
+		set  bool
+		// Whether the option is meaningful in this mode, and when it is
+		valid bool
+		when  string
+	}{{"quiet", c.quiet, verifying, "when verifying checksums"}, {"status", c.statusOnly, verifying, "when verifying checksums"},
+		{"warn", c.warn, c.check, "when verifying checksums"}, {"ignore-missing", c.ignoreMissing, c.check, "when verifying checksums"},
+		{"force", *force, c.xattrStore, "with --xattr-store"}, {"0", *null, *filesFrom != "", "with --files-from"}} {
+		if opt.set && !opt.valid {
+			c.errorf("the --%v option is meaningful only %v", opt.name, opt.when)
+			return 2
+		}
+	}
+	type option struct {
+		name string
+		set  bool
+	}
+	for _, mode := range []struct {
+		option
+		// Options the mode cannot be combined with
+		conflicts []option
+		// Whether the mode cannot read standard input
+		needsFiles bool
+	}{
+		{option{"--xattr-verify", c.xattrVerify}, []option{{"-c", c.check}, {"--format", c.output != "text"},
+			{"--watch", *watch}, {"--resume", *resume}}, true},
+		{option{"--xattr-store", c.xattrStore}, []option{{"-c", c.check}, {"--xattr-verify", c.xattrVerify},
+			{"--watch", *watch}, {"--resume", *resume}}, true},
+		{option{"--also", len(c.also) > 0}, []option{{"-c", c.check}, {"--xattr-verify", c.xattrVerify},
+			{"--xattr-store", c.xattrStore}, {"--resume", *resume}}, false},
+		{option{"--piece-size", c.pieceSize > 0}, []option{{"-c", c.check}, {"--format csv", c.output == "csv"},
+			{"--xattr-verify", c.xattrVerify}, {"--xattr-store", c.xattrStore}, {"--also", len(c.also) > 0},
+			{"--resume", *resume}}, false},
+	} {
+		if !mode.set {
+			continue
+		}
+		for _, opt := range mode.conflicts {
+			if opt.set {
+				c.errorf("the %v option cannot be combined with %v", opt.name, mode.name)
+				return 2
+			}
+		}
+		if mode.needsFiles && flags.NArg() == 0 && *filesFrom == "" {
+			c.errorf("%v needs the files to hash", mode.name)
+			return 2
+		}
+	}
+	if len(c.also) > 0 {
+		// Only tagged lines tell the algorithms apart
+		c.tag = true
+	}
+	if c.jobs <= 0 {
+		c.jobs = runtime.NumCPU()
+	}
+	if showProgress {
+		c.progress = newProgressBar(stderr)
+		defer c.progress.close()
+	}
+	if *watch {
+		c.watched = make(map[string]watchedFile)
+	}
+	if *resume {
+		var err error
+		if c.state, err = loadState(*statePath); err != nil {
+			c.errorf("%v", err)
+			return 2
+		}
+		defer func() { status = c.state.finish(status, c) }()
+	}
+	out, err := c.newOutput()
+	if err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+	paths := flags.Args()
+	if *filesFrom != "" {
+		if len(paths) > 0 {
+			c.errorf("file operands cannot be combined with --files-from")
+			return 2
+		}
+		if paths, err = c.readFiles(*filesFrom, *null); err != nil {
+			c.errorf("reading file names: %v", err)
+			return 1
+		}
+	} else if len(paths) == 0 {
+		paths = []string{stdinName}
+	}
+
+	if c.xattrVerify {
+		return c.checkXattrs(paths)
+	}
+	if c.check {
+		for _, path := range paths {
+			status = max(status, c.checkFile(path))
+		}
+		if *watch {
+			status = c.watch(out, status)
+		}
+		return status
+	}
+	var werr error
+	skipped := 0
+	results := runOrdered(context.Background(), c.jobs, func(submit func(func() result) bool) {
+		for _, path := range paths {
+			c.expand(path, func(path string, err error) {
+				submit(func() result {
+					if err != nil {
+						return result{Path: path, Err: err}
+					}
+					if c.xattrStore {
+						return c.storeXattr(path, *force)
+					}
+					return c.hash(path)
+				})
+			})
+		}
+	})
+	for res := range results {
+		c.progress.clear()
+		if res.Skipped {
+			skipped++
+			continue
+		}
+		if res.Err != nil {
+			c.errorf("%v", res.Err)
+			status = 1
+		} else {
+			c.remember(res.Path, res.Checksum)
+		}
+		if err := out.write(res); err != nil && werr == nil {
+			werr = err
+		}
+	}
+	if werr != nil {
+		c.errorf("%v", werr)
+		return 1
+	}
+	if skipped > 0 {
+		c.errorf("%v", plural(skipped, "file", "files", "skipped as unchanged since stored"))
+	}
+	if *watch {
+		status = c.watch(out, status)
+	}
+	if err := out.close(); err != nil {
+		c.errorf("%v", err)
+		return 1
+	}
+	return status
+}
+
+// result is the outcome of hashing one file.
+type result struct {
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Checksum fletcher4.Checksum
+	// Time it took to hash the file
+	Duration time.Duration
+	Err      error
+	// Checksum the file is expected to have, in check mode
+	Expected fletcher4.Checksum
+	// Outcome of verifying the file against its extended attribute, with --xattr-verify
+	Xattr *fletcher4.XattrResult
+	// Whether the file was not hashed with --xattr-store, as its stored checksum is up to date
+	Skipped bool
+	// Digests made by the --also hashes, in their order
+	Digests [][]byte
+	// Checksums of the pieces of the file, with --piece-size
+	Pieces *fletcher4.Index
+}
+
+// hash hashes the file at path, or standard input for stdinName.
+func (c *command) hash(path string) result {
+	if c.state != nil && path != stdinName {
+		return c.hashResumable(path)
+	}
+	if len(c.also) > 0 {
+		return c.hashAlso(path)
+	}
+	if c.pieceSize > 0 {
+		return c.hashPieces(path)
+	}
+	res := result{Path: path}
+	start := time.Now()
+	opts := c.progress.options(path)
+	if path == stdinName {
+		res.Checksum, res.Size, res.Err = fletcher4.SumReader(c.stdin, opts...)
+		if res.Err != nil {
+			res.Err = fmt.Errorf("%v: %w", stdinName, res.Err)
+		}
+	} else {
+		res.Checksum, res.Size, res.Err = fletcher4.SumFile(osPath(path), opts...)
+		if fi, err := os.Stat(osPath(path)); err == nil {
+			res.ModTime = fi.ModTime()
+		}
+	}
+	res.Duration = time.Since(start)
+	c.progress.finished(path, res.Size)
+	return res
+}
+
+// Name standing for standard input
+const stdinName = "-"
+
+// open opens the file at path for reading, or returns standard input for stdinName.
+func (c *command) open(path string) (io.ReadCloser, error) {
+	if path == stdinName {
+		return io.NopCloser(c.stdin), nil
+	}
+	return os.Open(osPath(path))
+}
+
+// errorf reports an error on stderr.
+func (c *command) errorf(format string, args ...any) {
+	c.progress.clear()
+	if c.logger != nil {
+		c.logf(format, args...)
+		return
+	}
+	fmt.Fprintf(c.stderr, "fletcher4sum: "+format+"\n", args...)
+}
+
+// stderrIsTerminal reports whether w is a terminal, where progress is shown by default.
+func stderrIsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f.Fd())
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
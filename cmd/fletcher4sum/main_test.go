@@ -0,0 +1,123 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// writeFiles writes files of the given contents to a temporary directory, and changes into it for the test
+func writeFiles(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// fletcher4sum runs the command and returns its exit status and output
+func fletcher4sum(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func sumOf(data string) string {
+	return fletcher4.Sum([]byte(data)).String()
+}
+
+// Test that files are hashed in order, failures reported, and usage errors given exit status 2
+func TestRun(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "hello world", "b": ""})
+	status, stdout, stderr := fletcher4sum("", "a", "missing", "b")
+	if exp := sumOf("hello world") + "  a\n" + sumOf("") + "  b\n"; stdout != exp {
+		t.Errorf("Output is %q, expected %q", stdout, exp)
+	}
+	if status != 1 || !strings.Contains(stderr, "missing") {
+		t.Errorf("Missing file gave status %v, %q", status, stderr)
+	}
+
+	if status, stdout, _ := fletcher4sum("", "--tag", "a"); status != 0 || stdout != "FLETCHER4 (a) = "+sumOf("hello world")+"\n" {
+		t.Errorf("Tagged output gave status %v, %q", status, stdout)
+	}
+	if status, _, _ := fletcher4sum("", "--unknown", "a"); status != 2 {
+		t.Errorf("Unknown flag gave status %v", status)
+	}
+}
+
+// Test that standard input is hashed without arguments or for "-", and checksum files are read from it
+func TestStdin(t *testing.T) {
+	line := sumOf("piped data") + "  -\n"
+	if status, stdout, _ := fletcher4sum("piped data"); status != 0 || stdout != line {
+		t.Errorf("Hashing stdin gave status %v, %q", status, stdout)
+	}
+	if status, stdout, _ := fletcher4sum("piped data", "-"); status != 0 || stdout != line {
+		t.Errorf("Hashing - gave status %v, %q", status, stdout)
+	}
+
+	writeFiles(t, map[string]string{"a": "hello"})
+	if status, stdout, _ := fletcher4sum(sumOf("hello")+"  a\n", "-c"); status != 0 || stdout != "a: OK\n" {
+		t.Errorf("Checking sums from stdin gave status %v, %q", status, stdout)
+	}
+}
+
+// Test that parallel hashing and checking print the same results in the same order as serial runs
+func TestParallel(t *testing.T) {
+	files := map[string]string{}
+	var names []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("f%02d", i)
+		files[name] = strings.Repeat(name, i*100)
+		names = append(names, name)
+	}
+	writeFiles(t, files)
+	args := append(names[:10:10], "missing")
+	args = append(args, names[10:]...)
+
+	status, serial, _ := fletcher4sum("", args...)
+	if status != 1 || strings.Count(serial, "\n") != 50 {
+		t.Fatalf("Serial run gave status %v, %q", status, serial)
+	}
+	for _, jobs := range []string{"4", "0"} {
+		status, parallel, stderr := fletcher4sum("", append([]string{"-j", jobs}, args...)...)
+		if status != 1 || parallel != serial || !strings.Contains(stderr, "missing") {
+			t.Errorf("Run with -j %v gave status %v, output differs: %v", jobs, status, parallel != serial)
+		}
+	}
+
+	if err := os.WriteFile("SUMS", []byte(serial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, _ := fletcher4sum("", "-j", "8", "-c", "SUMS")
+	if status != 0 || stdout != strings.Join(names, ": OK\n")+": OK\n" {
+		t.Errorf("Parallel check gave status %v, %q", status, stdout)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+)
+
+// metric is one metric of the scrub subcommand in the Prometheus text format.
+type metric struct {
+	name, typ, help string
+	value           float64
+}
+
+// metrics returns the metrics of the scrubber.
+func (s *scrubber) metrics() []metric {
+	st := &s.stats
+	inProgress, progress := 0.0, 0.0
+	passStart, passEnd := st.passStart.Load(), st.passEnd.Load()
+	done := st.passBytes.Load()
+	if passStart != 0 && passEnd == 0 {
+		inProgress = 1
+		done += st.fileDone.Load()
+		if expected := st.expectedBytes.Load(); expected > 0 {
+			progress = math.Min(float64(done)/float64(expected), 1)
+		}
+	} else if passEnd != 0 {
+		progress = 1
+	}
+	return []metric{
+		{"fletcher4_scrub_bytes_total", "counter", "Bytes read and verified by scrub passes.", float64(st.bytes.Load() + st.fileDone.Load())},
+		{"fletcher4_scrub_files_total", "counter", "Files verified by scrub passes.", float64(st.files.Load())},
+		{"fletcher4_scrub_mismatches_total", "counter", "Files found changed without being modified.", float64(st.mismatches.Load())},
+		{"fletcher4_scrub_unreadable_total", "counter", "Files that could not be read.", float64(st.unread.Load())},
+		{"fletcher4_scrub_throughput_bytes_per_second", "gauge", "Throughput verifying the current file.", math.Float64frombits(st.fileRate.Load())},
+		{"fletcher4_scrub_in_progress", "gauge", "Whether a scrub pass is running.", inProgress},
+		{"fletcher4_scrub_pass_bytes", "gauge", "Bytes verified by the current or last pass.", float64(done)},
+		{"fletcher4_scrub_pass_progress_ratio", "gauge", "Progress of the current pass, estimated from the size of the files the previous pass saw.", progress},
+		{"fletcher4_scrub_pass_start_timestamp_seconds", "gauge", "Start of the current or last pass.", float64(passStart) / 1e9},
+		{"fletcher4_scrub_pass_end_timestamp_seconds", "gauge", "End of the last complete pass, 0 while one is in progress.", float64(passEnd) / 1e9},
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (s *scrubber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, m := range s.metrics() {
+		fmt.Fprintf(bw, "# HELP %v %v\n# TYPE %v %v\n%v %v\n", m.name, m.help, m.name, m.typ, m.name, m.value)
+	}
+	bw.Flush()
+}
+
+// serveMetrics serves the metrics on /metrics at addr until close is called.
+func (s *scrubber) serveMetrics(addr string) (close func() error, err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	if url := fmt.Sprintf("http://%v/metrics", ln.Addr()); s.c.logger != nil {
+		s.c.logger.Info("serving metrics", slog.String("url", url))
+	} else {
+		fmt.Fprintf(s.c.stderr, "fletcher4sum: serving metrics on %v\n", url)
+	}
+	return srv.Close, nil
+}
@@ -0,0 +1,83 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the metrics of a pass in progress are written in the Prometheus text format
+func TestMetrics(t *testing.T) {
+	s := &scrubber{}
+	s.stats.files.Store(3)
+	s.stats.bytes.Store(300)
+	s.stats.passStart.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	s.stats.passBytes.Store(300)
+	s.stats.fileDone.Store(100)
+	s.stats.expectedBytes.Store(800)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE fletcher4_scrub_bytes_total counter\nfletcher4_scrub_bytes_total 400\n",
+		"fletcher4_scrub_files_total 3\n",
+		"fletcher4_scrub_mismatches_total 0\n",
+		"fletcher4_scrub_in_progress 1\n",
+		"fletcher4_scrub_pass_progress_ratio 0.5\n",
+		"fletcher4_scrub_pass_start_timestamp_seconds 1.7040672e+09\n",
+		"fletcher4_scrub_pass_end_timestamp_seconds 0\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Metrics lack %q:\n%v", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Metrics served as %q", ct)
+	}
+}
+
+// Test that a running scrub serves its metrics
+func TestMetricsServed(t *testing.T) {
+	runs := fakeWatch(t)
+	writeFiles(t, map[string]string{"a": "abcd", "b": "efgh"})
+	var stdout, stderr syncBuffer
+	status := make(chan int)
+	go func() {
+		status <- run([]string{"scrub", "-state", ".state", "-skip-hidden", "-metrics", "127.0.0.1:0", "."}, strings.NewReader(""), &stdout, &stderr)
+	}()
+	stop := <-runs
+	waitFor(t, &stdout, "scrub pass finished")
+	url := regexp.MustCompile(`http://\S+/metrics`).FindString(stderr.String())
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "fletcher4_scrub_files_total 2\n") || !strings.Contains(string(body), "fletcher4_scrub_pass_progress_ratio 1\n") {
+		t.Errorf("Served metrics are %q", body)
+	}
+	close(stop)
+	<-status
+	if _, err := http.Get(url); err == nil {
+		t.Error("Metrics still served after scrub ended")
+	}
+}
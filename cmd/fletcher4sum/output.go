@@ -0,0 +1,266 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/zfs"
+)
+
+// Name of the algorithm in BSD style lines
+const tagName = "FLETCHER4"
+
+// Escapes file names like coreutils does, so every checksum line stays one line
+var nameEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+
+// escape returns name escaped, and the prefix of the line holding it, a backslash if escaping changed it.
+func escape(name string) (escaped, prefix string) {
+	escaped = nameEscaper.Replace(name)
+	if escaped != name {
+		prefix = "\\"
+	}
+	return escaped, prefix
+}
+
+// format returns the checksum line of the file name, without the line terminator.
+func (c *command) format(sum fletcher4.Checksum, name string) string {
+	escaped, prefix := escape(name)
+	if c.zero {
+		escaped, prefix = name, ""
+	}
+	if c.tag {
+		return prefix + tagName + " (" + escaped + ") = " + c.formatSum(sum)
+	}
+	return prefix + c.formatSum(sum) + "  " + escaped
+}
+
+// formatSum formats sum as given with --checksum-format: lower or upper case hex of its serialized form, or the
+// words separated by colons like zdb prints them.
+func (c *command) formatSum(sum fletcher4.Checksum) string {
+	switch c.sumFormat {
+	case "upper":
+		return strings.ToUpper(sum.String())
+	case "zfs":
+		return zfs.FromChecksum(sum).Format(zfs.FormatZdb)
+	}
+	return sum.String()
+}
+
+// parseSum parses a checksum in any of the formats of --checksum-format. Slash separated words, as zstreamdump
+// prints them, and words without zero padding are accepted too.
+func parseSum(s string) (fletcher4.Checksum, error) {
+	if strings.ContainsAny(s, ":/") {
+		z, err := zfs.ParseZioCksum(s)
+		return z.Checksum(), err
+	}
+	return fletcher4.ParseChecksum(s)
+}
+
+// terminator returns the byte ending lines.
+func (c *command) terminator() byte {
+	if c.zero {
+		return 0
+	}
+	return '\n'
+}
+
+// writeLine writes line with its terminator to stdout.
+func (c *command) writeLine(line string) {
+	io.WriteString(c.stdout, line+string(c.terminator()))
+}
+
+// splitLines is a bufio.SplitFunc for lines ending with the terminator. Unlike bufio.ScanLines it keeps a
+// trailing carriage return, parseLine drops it.
+func (c *command) splitLines(data []byte, atEOF bool) (int, []byte, error) {
+	return splitOn(c.terminator())(data, atEOF)
+}
+
+// splitOn returns a bufio.SplitFunc for lines ending with sep, or the end of the data.
+func splitOn(sep byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// output writes the results of hashing files in one of the output formats.
+type output interface {
+	write(res result) error
+	// Finishes the output after the last result
+	close() error
+}
+
+// newOutput returns the output of the format given with --format.
+func (c *command) newOutput() (output, error) {
+	switch c.output {
+	case "text":
+		return textOutput{c}, nil
+	case "json":
+		return &jsonOutput{c: c, array: true}, nil
+	case "jsonl":
+		return &jsonOutput{c: c}, nil
+	case "csv":
+		w := csv.NewWriter(c.stdout)
+		return &csvOutput{c: c, w: w}, w.Write(append(slices.Clip(csvHeader), c.also...))
+	}
+	return nil, fmt.Errorf("unknown output format %q", c.output)
+}
+
+// textOutput writes checksum lines, skipping files that could not be read.
+type textOutput struct {
+	c *command
+}
+
+func (o textOutput) write(res result) error {
+	if res.Err == nil {
+		o.c.writeLine(o.c.format(res.Checksum, res.Path))
+		for i, alg := range o.c.also {
+			o.c.writeLine(o.c.formatDigest(alg, res.Digests[i], res.Path))
+		}
+		if res.Pieces != nil {
+			for _, e := range res.Pieces.Entries {
+				o.c.writeLine(o.c.formatPiece(e))
+			}
+		}
+	}
+	return nil
+}
+
+func (o textOutput) close() error {
+	return nil
+}
+
+// record is the machine readable form of a result.
+type record struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mtime,omitempty"`
+	// Checksum formatted as given with --checksum-format, empty for errors
+	Checksum string `json:"checksum,omitempty"`
+	// Time it took to hash the file, in seconds
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+	// Hex digests of the --also hashes by name
+	Digests map[string]string `json:"digests,omitempty"`
+	Pieces  []pieceRecord     `json:"pieces,omitempty"`
+}
+
+// pieceRecord is the machine readable form of a piece listed with --piece-size.
+type pieceRecord struct {
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+	Checksum string `json:"checksum"`
+}
+
+func (c *command) newRecord(res result) record {
+	r := record{Path: res.Path, Size: res.Size, Duration: res.Duration.Seconds()}
+	if !res.ModTime.IsZero() {
+		r.ModTime = res.ModTime.UTC().Format(time.RFC3339Nano)
+	}
+	if res.Err != nil {
+		r.Error = res.Err.Error()
+	} else {
+		r.Checksum = c.formatSum(res.Checksum)
+		for i, alg := range c.also {
+			if r.Digests == nil {
+				r.Digests = make(map[string]string)
+			}
+			r.Digests[alg] = hex.EncodeToString(res.Digests[i])
+		}
+		if res.Pieces != nil {
+			for _, e := range res.Pieces.Entries {
+				r.Pieces = append(r.Pieces, pieceRecord{e.Offset, e.Length, c.formatSum(e.Checksum)})
+			}
+		}
+	}
+	return r
+}
+
+// jsonOutput writes the records as a JSON array, or as one object per line.
+type jsonOutput struct {
+	c     *command
+	array bool
+	n     int
+}
+
+func (o *jsonOutput) write(res result) error {
+	b, err := json.Marshal(o.c.newRecord(res))
+	if err != nil {
+		return err
+	}
+	line := string(b) + "\n"
+	if o.array {
+		// Objects are separated, and the last one ended, by close
+		line = ",\n" + string(b)
+		if o.n == 0 {
+			line = "[\n" + string(b)
+		}
+	}
+	o.n++
+	_, err = io.WriteString(o.c.stdout, line)
+	return err
+}
+
+func (o *jsonOutput) close() error {
+	if !o.array {
+		return nil
+	}
+	end := "\n]\n"
+	if o.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(o.c.stdout, end)
+	return err
+}
+
+var csvHeader = []string{"path", "size", "mtime", "checksum", "duration", "error"}
+
+// csvOutput writes the records as CSV, after a header line.
+type csvOutput struct {
+	c *command
+	w *csv.Writer
+}
+
+func (o *csvOutput) write(res result) error {
+	r := o.c.newRecord(res)
+	fields := []string{r.Path, strconv.FormatInt(r.Size, 10), r.ModTime, r.Checksum,
+		strconv.FormatFloat(r.Duration, 'f', -1, 64), r.Error}
+	for _, alg := range o.c.also {
+		fields = append(fields, r.Digests[alg])
+	}
+	return o.w.Write(fields)
+}
+
+func (o *csvOutput) close() error {
+	o.w.Flush()
+	return o.w.Error()
+}
@@ -0,0 +1,139 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that names are escaped like sha256sum does, in both styles
+func TestFormat(t *testing.T) {
+	var sum fletcher4.Checksum
+	hex := strings.Repeat("0", 64)
+	for _, test := range []struct {
+		tag       bool
+		name, exp string
+	}{
+		{false, "plain name", hex + "  plain name"},
+		{false, "new\nline", "\\" + hex + "  new\\nline"},
+		{false, "back\\slash", "\\" + hex + "  back\\\\slash"},
+		{true, "plain", "FLETCHER4 (plain) = " + hex},
+		{true, "new\nline", "\\FLETCHER4 (new\\nline) = " + hex},
+	} {
+		c := &command{tag: test.tag}
+		if line := c.format(sum, test.name); line != test.exp {
+			t.Errorf("Line of %q is %q, expected %q", test.name, line, test.exp)
+		}
+	}
+}
+
+// Test that NUL terminated lines leave names unescaped, and that the last style flag wins
+func TestZero(t *testing.T) {
+	writeFiles(t, map[string]string{"new\nline": "data"})
+	status, stdout, _ := fletcher4sum("", "-z", "--tag", "--untagged", "new\nline")
+	if exp := sumOf("data") + "  new\nline\x00"; status != 0 || stdout != exp {
+		t.Errorf("Zero terminated output gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	if status, out, _ := fletcher4sum(stdout, "-c", "--zero"); status != 0 || out != "\\new\\nline: OK\n" {
+		t.Errorf("Check of zero terminated lines gave status %v, %q", status, out)
+	}
+	if _, stdout, _ := fletcher4sum("", "--untagged", "--tag", "-z", "new\nline"); !strings.HasPrefix(stdout, "FLETCHER4 (new\nline)") {
+		t.Errorf("Tagged zero terminated output is %q", stdout)
+	}
+}
+
+// Test the machine readable formats, including files that could not be read
+func TestMachineOutput(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "hello"})
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes("a", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	status, stdout, _ := fletcher4sum("", "--format", "json", "a", "missing")
+	var records []record
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("JSON output %q: %v", stdout, err)
+	}
+	if status != 1 || len(records) != 2 {
+		t.Fatalf("JSON output gave status %v, %+v", status, records)
+	}
+	if r := records[0]; r.Path != "a" || r.Size != 5 || r.ModTime != "2024-05-01T12:00:00Z" || r.Checksum != sumOf("hello") ||
+		r.Duration < 0 || r.Error != "" {
+		t.Errorf("JSON record of a is %+v", r)
+	}
+	if r := records[1]; r.Path != "missing" || r.Checksum != "" || r.Error == "" {
+		t.Errorf("JSON record of missing file is %+v", r)
+	}
+	_, stdout, _ = fletcher4sum("", "--format", "jsonl", "a", "a")
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	var r record
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &r) != nil || r.Checksum != sumOf("hello") {
+		t.Errorf("JSON lines output is %q", stdout)
+	}
+
+	_, stdout, _ = fletcher4sum("", "--format", "csv", "a")
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil || len(rows) != 2 || strings.Join(rows[0], ",") != "path,size,mtime,checksum,duration,error" ||
+		rows[1][0] != "a" || rows[1][1] != "5" || rows[1][3] != sumOf("hello") {
+		t.Errorf("CSV output is %q", stdout)
+	}
+
+	if status, stdout, _ := fletcher4sum("", "--format", "json"); status != 0 || !strings.HasPrefix(stdout, "[\n{") {
+		t.Errorf("JSON output of stdin gave status %v, %q", status, stdout)
+	}
+	if status, _, _ := fletcher4sum("", "--format", "xml", "a"); status != 2 {
+		t.Errorf("Unknown format gave status %v", status)
+	}
+}
+
+// Test that checksums are printed in upper case hex or as zfs words, and that check mode accepts all formats
+func TestChecksumFormat(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "abcd"})
+	sum := fletcher4.Sum([]byte("abcd"))
+	w := [4]uint64(sum)
+	for format, exp := range map[string]string{
+		"lower": sum.String(),
+		"upper": strings.ToUpper(sum.String()),
+		// The cksum= field of zdb, every word being the little endian word of "abcd"
+		"zfs": "0000000064636261:0000000064636261:0000000064636261:0000000064636261",
+	} {
+		status, stdout, _ := fletcher4sum("", "--checksum-format", format, "a")
+		if status != 0 || stdout != exp+"  a\n" {
+			t.Errorf("Format %v gave status %v, %q, expected %q", format, status, stdout, exp)
+		}
+		os.WriteFile("SUMS", []byte(exp+"  a\nFLETCHER4 (a) = "+exp+"\n"), 0o644)
+		if status, stdout, _ := fletcher4sum("", "-c", "SUMS"); status != 0 || stdout != "a: OK\na: OK\n" {
+			t.Errorf("Check of format %v gave status %v, %q", format, status, stdout)
+		}
+	}
+	// Unpadded words as older zdb releases and zstreamdump print them
+	os.WriteFile("SUMS", []byte(fmt.Sprintf("%x/%x/%x/%x  a\n%x:%x:%x:%x  a\n", w[0], w[1], w[2], w[3], w[0], w[1], w[2], w[3])), 0o644)
+	if status, stdout, _ := fletcher4sum("", "-c", "SUMS"); status != 0 || stdout != "a: OK\na: OK\n" {
+		t.Errorf("Check of unpadded words gave status %v, %q", status, stdout)
+	}
+
+	if status, _, _ := fletcher4sum("", "--checksum-format", "base64", "a"); status != 2 {
+		t.Errorf("Unknown checksum format gave status %v", status)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Buffer reading files into the piece index
+const pieceBuffer = 1 << 20
+
+// setPieceSize sets the size of the pieces of --piece-size, which must be a whole number of words.
+func (c *command) setPieceSize(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	if n%fletcher4.BlockSize != 0 {
+		return fmt.Errorf("piece size %v is not a multiple of %v bytes", n, fletcher4.BlockSize)
+	}
+	if int64(n) > fletcher4.MaxIndexChunkSize {
+		return fmt.Errorf("piece size %v is larger than %v bytes", n, fletcher4.MaxIndexChunkSize)
+	}
+	c.pieceSize = int64(n)
+	return nil
+}
+
+// hashPieces hashes the file at path, or standard input for stdinName, in pieces of --piece-size bytes.
+func (c *command) hashPieces(path string) result {
+	res := result{Path: path}
+	start := time.Now()
+	r, err := c.openProgress(path, &res)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer r.Close()
+
+	w := fletcher4.NewIndexWriter(c.pieceSize)
+	if _, err := io.CopyBuffer(w, r, make([]byte, pieceBuffer)); err != nil {
+		res.Err = fmt.Errorf("%v: %w", path, err)
+	}
+	res.Pieces = w.Index()
+	res.Checksum, res.Size = res.Pieces.Checksum(), res.Pieces.Size
+	res.Duration = time.Since(start)
+	c.progress.finished(path, res.Size)
+	return res
+}
+
+// Start of the lines listing pieces, which cannot start checksum lines
+const piecePrefix = "piece "
+
+// formatPiece returns the line of the piece e: its offset, length and checksum.
+func (c *command) formatPiece(e fletcher4.IndexEntry) string {
+	return piecePrefix + strconv.FormatInt(e.Offset, 10) + " " + strconv.FormatInt(e.Length, 10) + " " + c.formatSum(e.Checksum)
+}
+
+// pieceLine reports whether line lists a piece of the file before it, which check mode skips.
+func pieceLine(line string) bool {
+	return strings.HasPrefix(line, piecePrefix)
+}
@@ -0,0 +1,52 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test that a checksum is listed for every piece, and the piece lines skipped in check mode
+func TestPieceSize(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+	writeFiles(t, map[string]string{"a": data})
+	status, stdout, _ := fletcher4sum("", "--piece-size", "4K", "a")
+	exp := sumOf(data) + "  a\n" +
+		"piece 0 4096 " + sumOf(data[:4096]) + "\n" +
+		"piece 4096 4096 " + sumOf(data[4096:8192]) + "\n" +
+		"piece 8192 1808 " + sumOf(data[8192:]) + "\n"
+	if status != 0 || stdout != exp {
+		t.Errorf("Listing pieces gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	if status, out, stderr := fletcher4sum(exp, "-c", "-w"); status != 0 || out != "a: OK\n" || stderr != "" {
+		t.Errorf("Checking piece list gave status %v, %q, %q", status, out, stderr)
+	}
+
+	_, stdout, _ = fletcher4sum("", "--piece-size", "4K", "--format", "jsonl", "a")
+	var rec record
+	if err := json.Unmarshal([]byte(stdout), &rec); err != nil || len(rec.Pieces) != 3 ||
+		rec.Pieces[2] != (pieceRecord{8192, 1808, sumOf(data[8192:])}) {
+		t.Errorf("JSON output %q has pieces %v, %v", stdout, rec.Pieces, err)
+	}
+
+	for _, args := range [][]string{{"--piece-size", "4098", "a"}, {"--piece-size", "0", "a"},
+		{"--piece-size", "4K", "--format", "csv", "a"}, {"--piece-size", "4K", "-c", "a"}} {
+		if status, _, _ := fletcher4sum("", args...); status != 2 {
+			t.Errorf("%q gave status %v", args, status)
+		}
+	}
+}
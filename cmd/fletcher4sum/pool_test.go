@@ -0,0 +1,44 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Test that runOrdered delivers results in the order tasks are submitted, however long they take
+func TestRunOrdered(t *testing.T) {
+	for _, workers := range []int{0, 1, 3} {
+		var got []int
+		for n := range runOrdered(context.Background(), workers, func(submit func(task func() int) bool) {
+			for i := 0; i < 20; i++ {
+				i := i
+				submit(func() int {
+					// Later tasks finish first
+					time.Sleep(time.Duration(20-i) * 100 * time.Microsecond)
+					return i
+				})
+			}
+		}) {
+			got = append(got, n)
+		}
+		if fmt.Sprint(got) != fmt.Sprint([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}) {
+			t.Errorf("runOrdered with %v workers returned %v", workers, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Minimum time between two redraws of the progress line
+const redrawInterval = 200 * time.Millisecond
+
+// progressBar draws a line on stderr with the files and bytes hashed so far, the throughput, and the percentage
+// and ETA of the files being hashed, redrawn in place. A nil progressBar draws nothing.
+type progressBar struct {
+	w     io.Writer
+	mu    sync.Mutex
+	start time.Time
+	last  time.Time
+	// Files hashed, and their bytes
+	files int
+	done  int64
+	// Progress of the files being hashed
+	active map[string]fletcher4.Progress
+	// Whether a line has been drawn since the last clear
+	drawn bool
+}
+
+func newProgressBar(w io.Writer) *progressBar {
+	return &progressBar{w: w, start: time.Now(), active: make(map[string]fletcher4.Progress)}
+}
+
+// options returns the options reporting the progress of hashing path.
+func (p *progressBar) options(path string) []fletcher4.Option {
+	if p == nil {
+		return nil
+	}
+	return []fletcher4.Option{fletcher4.WithProgress(func(pr fletcher4.Progress) {
+		p.update(path, pr)
+	})}
+}
+
+// update records the progress of hashing path.
+func (p *progressBar) update(path string, pr fletcher4.Progress) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active[path] = pr
+	p.redraw(false)
+}
+
+// finished records path as hashed, size bytes long.
+func (p *progressBar) finished(path string, size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.active, path)
+	p.files++
+	p.done += size
+	p.redraw(false)
+}
+
+// clear removes the progress line, before other output is written to the terminal. It is drawn again with the
+// next progress.
+func (p *progressBar) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		io.WriteString(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+}
+
+// redraw draws the progress line, at most every redrawInterval unless forced.
+func (p *progressBar) redraw(force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.last) < redrawInterval {
+		return
+	}
+	p.last = now
+	done := p.done
+	var remaining int64
+	paths := make([]string, 0, len(p.active))
+	for path, pr := range p.active {
+		done += pr.Done
+		if pr.Total > pr.Done {
+			remaining += pr.Total - pr.Done
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	rate := float64(done) / now.Sub(p.start).Seconds()
+	line := fmt.Sprintf("%v files, %v, %v/s", p.files, formatBytes(float64(done)), formatBytes(rate))
+	if len(paths) > 0 {
+		// The first file being hashed stands for all of them
+		pr := p.active[paths[0]]
+		line += fmt.Sprintf(", %v", paths[0])
+		if pr.Total > 0 {
+			line += fmt.Sprintf(" %.0f%%", 100*float64(pr.Done)/float64(pr.Total))
+		}
+		if remaining > 0 && rate > 0 {
+			line += fmt.Sprintf(", ETA %v", time.Duration(float64(remaining)/rate*float64(time.Second)).Round(time.Second))
+		}
+	}
+	fmt.Fprintf(p.w, "\r\x1b[K%v", line)
+	p.drawn = true
+}
+
+// close draws the final progress, and ends the line.
+func (p *progressBar) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.redraw(true)
+	io.WriteString(p.w, "\n")
+}
+
+// formatBytes formats n bytes with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %v", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %v", n, units[i])
+}
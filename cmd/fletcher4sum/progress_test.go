@@ -0,0 +1,66 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test that the progress line is drawn, cleared before output, and finished with a newline
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgressBar(&buf)
+	p.finished("a", 3<<20)
+	p.redraw(true)
+	if s := buf.String(); !strings.Contains(s, "1 files, 3.0 MiB") {
+		t.Errorf("Progress line is %q", s)
+	}
+	p.clear()
+	if s := buf.String(); !strings.HasSuffix(s, "\r\x1b[K") {
+		t.Errorf("Progress line not cleared: %q", s)
+	}
+	p.close()
+	if s := buf.String(); !strings.HasSuffix(s, "\n") {
+		t.Errorf("Progress not ended with a newline: %q", s)
+	}
+
+	// A nil progress bar draws nothing
+	var none *progressBar
+	if none.options("a") != nil {
+		t.Error("Nil progress bar returned options")
+	}
+	none.finished("a", 1)
+	none.clear()
+	none.close()
+
+	writeFiles(t, map[string]string{"a": "abcd"})
+	status, stdout, stderr := fletcher4sum("", "--progress", "a")
+	if status != 0 || stdout != sumOf("abcd")+"  a\n" || !strings.Contains(stderr, "1 files") {
+		t.Errorf("Run with --progress gave status %v, %q, %q", status, stdout, stderr)
+	}
+	if _, _, stderr := fletcher4sum("", "--progress", "--no-progress", "a"); stderr != "" {
+		t.Errorf("Run with --no-progress printed %q", stderr)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, exp := range map[float64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if s := formatBytes(n); s != exp {
+			t.Errorf("formatBytes(%v) = %q, expected %q", n, s, exp)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Minimum time between two saves of the state file
+var checkpointInterval = 30 * time.Second
+
+// checkpoint is how far hashing a file got, as saved in the state file.
+type checkpoint struct {
+	// Size and modification time of the file, which must be unchanged to resume
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Bytes hashed, and the marshaled digest of them
+	Offset int64  `json:"offset"`
+	State  []byte `json:"state"`
+	// Checksum of the whole file once it is hashed
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// stateFile holds the checkpoints of a run with --resume, saved every checkpointInterval so the run can be
+// resumed after an interruption. A nil stateFile keeps nothing.
+type stateFile struct {
+	path  string
+	mu    sync.Mutex
+	files map[string]checkpoint
+	saved time.Time
+}
+
+// loadState reads the state file at path, or returns an empty state if it does not exist.
+func loadState(path string) (*stateFile, error) {
+	s := &stateFile{path: path, files: make(map[string]checkpoint), saved: time.Now()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.files); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return s, nil
+}
+
+// get returns the checkpoint of the file at path, if it has not changed since.
+func (s *stateFile) get(path string, fi fs.FileInfo) (checkpoint, bool) {
+	if s == nil {
+		return checkpoint{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.files[path]
+	return cp, ok && cp.Size == fi.Size() && cp.ModTime.Equal(fi.ModTime())
+}
+
+// put records the checkpoint of the file at path, and saves the state file if it was not saved for
+// checkpointInterval.
+func (s *stateFile) put(path string, cp checkpoint) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = cp
+	if time.Since(s.saved) < checkpointInterval {
+		return nil
+	}
+	return s.save()
+}
+
+// save writes the state file, replacing the previous one only once the new one is complete.
+func (s *stateFile) save() error {
+	data, err := json.Marshal(s.files)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	s.saved = time.Now()
+	return nil
+}
+
+// writeFileAtomic writes data to the file at path, replacing it only once the new file is complete and synced.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// finish saves the state at the end of a run that exits with status, or removes the state file if all files
+// were hashed, and returns the exit status.
+func (s *stateFile) finish(status int, c *command) int {
+	if s == nil {
+		return status
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if status == 0 {
+		err = os.Remove(s.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = s.save()
+	}
+	if err != nil {
+		c.errorf("%v", err)
+		return max(status, 1)
+	}
+	return status
+}
+
+// hashResumable hashes the regular file at path, continuing from its checkpoint in the state file, and
+// recording checkpoints as it goes. Other files are hashed from the start.
+func (c *command) hashResumable(path string) result {
+	res := result{Path: path}
+	start := time.Now()
+	f, err := os.Open(osPath(path))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if !fi.Mode().IsRegular() {
+		res.Checksum, res.Size, res.Err = fletcher4.SumReader(f, c.progress.options(path)...)
+		res.Duration = time.Since(start)
+		c.progress.finished(path, res.Size)
+		return res
+	}
+	res.Size, res.ModTime = fi.Size(), fi.ModTime()
+	defer func() {
+		res.Duration = time.Since(start)
+		c.progress.finished(path, res.Size)
+	}()
+
+	d := fletcher4.New()
+	var offset int64
+	if cp, ok := c.state.get(path, fi); ok {
+		if sum, err := fletcher4.ParseChecksum(cp.Checksum); cp.Checksum != "" && err == nil {
+			res.Checksum = sum
+			return res
+		}
+		if err := d.(encoding.BinaryUnmarshaler).UnmarshalBinary(cp.State); err == nil && cp.Offset <= fi.Size() {
+			if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+				res.Err = err
+				return res
+			}
+			offset = cp.Offset
+		} else {
+			d.Reset()
+		}
+	}
+
+	// Checkpoints fall between whole chunks, the digest only takes whole words
+	buf := make([]byte, fletcher4.DefaultChunkSize)
+	resumed := offset
+	for {
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			res.Err = err
+			return res
+		}
+		words := (n + fletcher4.BlockSize - 1) &^ (fletcher4.BlockSize - 1)
+		clear(buf[n:words])
+		d.Write(buf[:words])
+		offset += int64(n)
+		elapsed := time.Since(start)
+		c.progress.update(path, fletcher4.Progress{Done: offset, Total: fi.Size(), Elapsed: elapsed,
+			Rate: float64(offset-resumed) / elapsed.Seconds()})
+		if err != nil {
+			break
+		}
+		state, _ := d.(encoding.BinaryMarshaler).MarshalBinary()
+		if err := c.state.put(path, checkpoint{Size: fi.Size(), ModTime: fi.ModTime(), Offset: offset, State: state}); err != nil {
+			res.Err = fmt.Errorf("saving state: %w", err)
+			return res
+		}
+	}
+	res.Checksum = d.Sum64x4()
+	if err := c.state.put(path, checkpoint{Size: fi.Size(), ModTime: fi.ModTime(), Offset: offset, Checksum: res.Checksum.String()}); err != nil {
+		res.Err = fmt.Errorf("saving state: %w", err)
+	}
+	return res
+}
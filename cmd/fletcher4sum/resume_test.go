@@ -0,0 +1,93 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that interrupted runs continue from their checkpoints, and that changed files are hashed again
+func TestResume(t *testing.T) {
+	data := strings.Repeat("0123456789abcdef", 40000)
+	writeFiles(t, map[string]string{"big": data, "small": "abc"})
+	defer func(interval time.Duration) { checkpointInterval = interval }(checkpointInterval)
+	checkpointInterval = 0
+	fi, err := os.Stat("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A checkpoint after the first chunk, taken of other data, shows up in the checksum
+	save := func(prefix string) {
+		d := fletcher4.New()
+		d.Write([]byte(prefix[:fletcher4.DefaultChunkSize]))
+		state, _ := d.(encoding.BinaryMarshaler).MarshalBinary()
+		s, err := loadState(".fletcher4sum.state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.put("big", checkpoint{Size: fi.Size(), ModTime: fi.ModTime(), Offset: fletcher4.DefaultChunkSize, State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := strings.Repeat("x", fletcher4.DefaultChunkSize)
+	save(other)
+	status, stdout, _ := fletcher4sum("", "--resume", "big")
+	if exp := sumOf(other+data[len(other):]) + "  big\n"; status != 0 || stdout != exp {
+		t.Errorf("Resumed run gave status %v, %q, expected %q", status, stdout, exp)
+	}
+	if _, err := os.Stat(".fletcher4sum.state"); err == nil {
+		t.Error("State file kept after a complete run")
+	}
+
+	save(data)
+	if status, stdout, _ := fletcher4sum("", "--resume", "big"); status != 0 || stdout != sumOf(data)+"  big\n" {
+		t.Errorf("Resumed run gave status %v, %q", status, stdout)
+	}
+
+	// Files hashed before a failure are not hashed again
+	status, _, _ = fletcher4sum("", "--resume", "--state-file", "state", "small", "missing")
+	if status != 1 {
+		t.Fatalf("Run with a missing file gave status %v", status)
+	}
+	s, err := loadState("state")
+	if err != nil || s.files["small"].Checksum != sumOf("abc") {
+		t.Fatalf("State after failed run is %v, %v", s, err)
+	}
+	cp := s.files["small"]
+	cp.Checksum = sumOf("something else")
+	s.files["small"] = cp
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := fletcher4sum("", "--resume", "--state-file", "state", "small"); stdout != sumOf("something else")+"  small\n" {
+		t.Errorf("Resumed run printed %q, expected the checksum from the state file", stdout)
+	}
+
+	// Until the file changes
+	save(other)
+	if err := os.WriteFile("big", []byte(data+"more"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := fletcher4sum("", "--resume", "big"); stdout != sumOf(data+"more")+"  big\n" {
+		t.Errorf("Changed file gave %q", stdout)
+	}
+}
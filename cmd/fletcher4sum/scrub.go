@@ -0,0 +1,354 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// scrubState is the state of the scrub subcommand, saved while scrubbing so it continues where it was after a
+// restart.
+type scrubState struct {
+	// Files as seen when last verified, by path
+	Files map[string]scrubFile `json:"files"`
+	// Start of the last pass, and its end once it is complete
+	PassStart time.Time `json:"pass_start"`
+	PassEnd   time.Time `json:"pass_end"`
+	// Last file verified by the pass in progress
+	Position scrubPosition `json:"position"`
+}
+
+// scrubFile is a file as seen by a scrub pass.
+type scrubFile struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Checksum string    `json:"checksum"`
+	// Start of the pass that last saw the file, files not seen by a complete pass are forgotten
+	Pass time.Time `json:"pass"`
+}
+
+// scrubPosition is a file in the order scrub passes verify them, the index of the path given and the path of
+// the file below it.
+type scrubPosition struct {
+	Root int    `json:"root"`
+	Path string `json:"path"`
+}
+
+// after reports whether the file path below the path given with index root comes after p. Files are verified
+// in lexical order of their path components.
+func (p scrubPosition) after(root int, path string) bool {
+	if root != p.Root {
+		return root > p.Root
+	}
+	if p.Path == "" {
+		return true
+	}
+	a, b := strings.Split(path, string(filepath.Separator)), strings.Split(p.Path, string(filepath.Separator))
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return len(a) > len(b)
+}
+
+// scrubStats counts what the scrub subcommand has done since it started, and tracks the pass in progress. Read
+// by the metrics handler while scrubbing.
+type scrubStats struct {
+	files      atomic.Int64
+	bytes      atomic.Int64
+	mismatches atomic.Int64
+	unread     atomic.Int64
+	// Start and end of the last pass in Unix nanoseconds, the end being zero while it is in progress
+	passStart, passEnd atomic.Int64
+	// Bytes verified by the pass in progress, and of all files the previous one saw
+	passBytes, expectedBytes atomic.Int64
+	// Progress of the file being verified, its throughput as float64 bits
+	fileDone atomic.Int64
+	fileRate atomic.Uint64
+}
+
+// counts returns the files, bytes, mismatches and unreadable files counted so far.
+func (st *scrubStats) counts() [4]int64 {
+	return [4]int64{st.files.Load(), st.bytes.Load(), st.mismatches.Load(), st.unread.Load()}
+}
+
+// scrubber runs the scrub subcommand.
+type scrubber struct {
+	c        *command
+	paths    []string
+	interval time.Duration
+	opts     []fletcher4.Option
+	path     string
+	state    scrubState
+	saved    time.Time
+	stats    scrubStats
+}
+
+// scrub runs the scrub subcommand, which verifies the files below the paths given on a schedule, like zpool
+// scrub does for pools, and returns the exit status.
+func (c *command) scrub(args []string) int {
+	flags := flag.NewFlagSet("fletcher4sum scrub", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: fletcher4sum scrub [-interval DURATION] [-rate SIZE] [-state FILE] [-once] [--include PATTERN] [--exclude PATTERN] PATH...")
+		flags.PrintDefaults()
+	}
+	s := &scrubber{c: c}
+	flags.DurationVar(&s.interval, "interval", 30*24*time.Hour, "start a pass every `duration`, counting from the start of the previous one")
+	flags.Func("rate", "read at most `size` bytes per second, with optional K, M or G suffix, 0 for no limit", func(v string) error {
+		if v == "0" {
+			return nil
+		}
+		n, err := parseSize(v)
+		s.opts = append(s.opts, fletcher4.WithRateLimit(int64(n)))
+		return err
+	})
+	flags.Func("log-format", "write errors and verification failures to stderr as `text` or json records", c.setLogFormat)
+	flags.StringVar(&s.path, "state", ".fletcher4sum-scrub.state", "`file` the checksums and position are saved in")
+	once := flags.Bool("once", false, "run the next pass if it is due and exit, e.g. from cron")
+	metricsAddr := flags.String("metrics", "", "serve Prometheus metrics on /metrics at `address`, e.g. :9440")
+	flags.Var(&c.walk.include, "include", "only verify files matching the glob `pattern`, may be repeated")
+	flags.Var(&c.walk.exclude, "exclude", "skip files and directories matching the glob `pattern`, may be repeated")
+	flags.BoolVar(&c.walk.skipHidden, "skip-hidden", false, "skip files and directories starting with a dot")
+	flags.BoolVar(&c.walk.follow, "L", false, "follow symbolic links, which are skipped otherwise")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	c.walk.recursive = true
+	s.paths = flags.Args()
+	if err := s.load(); err != nil {
+		c.errorf("%v", err)
+		return 2
+	}
+
+	if *metricsAddr != "" {
+		closeMetrics, err := s.serveMetrics(*metricsAddr)
+		if err != nil {
+			c.errorf("%v", err)
+			return 2
+		}
+		defer closeMetrics()
+	}
+
+	stop, done := interrupted()
+	defer done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	status := 0
+	for {
+		if s.state.PassEnd.After(s.state.PassStart) || s.state.PassStart.IsZero() {
+			// Between passes
+			if wait := time.Until(s.state.PassStart.Add(s.interval)); wait > 0 {
+				if *once {
+					return status
+				}
+				select {
+				case <-ctx.Done():
+					return status
+				case <-time.After(wait):
+				}
+			}
+			s.state.PassStart = time.Now()
+			s.state.Position = scrubPosition{}
+		}
+		if !s.pass(ctx) {
+			status = 1
+		}
+		if err := s.save(); err != nil {
+			c.errorf("%v", err)
+			return 1
+		}
+		if ctx.Err() != nil || *once {
+			return status
+		}
+	}
+}
+
+// load reads the state file, if there is one.
+func (s *scrubber) load() error {
+	s.state.Files = make(map[string]scrubFile)
+	s.saved = time.Now()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return fmt.Errorf("%v: %w", s.path, err)
+	}
+	return nil
+}
+
+// save writes the state file.
+func (s *scrubber) save() error {
+	data, err := json.Marshal(&s.state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	s.saved = time.Now()
+	return nil
+}
+
+// pass verifies the files from the position of the pass in progress on, until all are verified or ctx is done.
+// It returns false if any file did not match or could not be read.
+func (s *scrubber) pass(ctx context.Context) bool {
+	c := s.c
+	ok := true
+	start := time.Now()
+	before := s.stats.counts()
+	// A restarted pass has already verified the files it saw
+	var verified, expected int64
+	for _, f := range s.state.Files {
+		if f.Pass.Equal(s.state.PassStart) {
+			verified += f.Size
+		}
+		expected += f.Size
+	}
+	s.stats.passBytes.Store(verified)
+	s.stats.expectedBytes.Store(expected)
+	s.stats.passStart.Store(s.state.PassStart.UnixNano())
+	s.stats.passEnd.Store(0)
+	for root, path := range s.paths {
+		if root < s.state.Position.Root {
+			continue
+		}
+		c.expand(path, func(path string, err error) {
+			if ctx.Err() != nil || !s.state.Position.after(root, path) {
+				return
+			}
+			if err != nil {
+				c.errorf("%v", err)
+				s.stats.unread.Add(1)
+				ok = false
+				return
+			}
+			if !s.verify(ctx, path) {
+				ok = false
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			s.state.Position = scrubPosition{Root: root, Path: path}
+			if time.Since(s.saved) >= checkpointInterval {
+				if err := s.save(); err != nil {
+					c.errorf("%v", err)
+					ok = false
+				}
+			}
+		})
+	}
+	if ctx.Err() != nil {
+		return ok
+	}
+
+	for path, f := range s.state.Files {
+		if !f.Pass.Equal(s.state.PassStart) {
+			delete(s.state.Files, path)
+		}
+	}
+	s.state.PassEnd = time.Now()
+	s.stats.passEnd.Store(s.state.PassEnd.UnixNano())
+	after := s.stats.counts()
+	fmt.Fprintf(c.stdout, "scrub pass finished in %v: %v, %v, %v, %v\n", s.state.PassEnd.Sub(start).Round(time.Second),
+		plural(int(after[0]-before[0]), "file", "files", "verified"), formatBytes(float64(after[1]-before[1])),
+		plural(int(after[2]-before[2]), "mismatch", "mismatches", "found"),
+		plural(int(after[3]-before[3]), "file", "files", "unreadable"))
+	return ok
+}
+
+// verify hashes the file at path and compares it to how the last pass saw it. A file whose checksum changed
+// while its size and modification time did not is reported as a mismatch, and keeps its old checksum to be
+// reported again by later passes until it is restored. It returns false on mismatches and read errors.
+func (s *scrubber) verify(ctx context.Context, path string) bool {
+	c := s.c
+	prev, known := s.state.Files[path]
+	expected, _ := fletcher4.ParseChecksum(prev.Checksum)
+	unreadable := func(err error) bool {
+		c.failure(result{Path: path, Size: prev.Size, Expected: expected, Err: err}, err.Error())
+		s.stats.unread.Add(1)
+		return false
+	}
+	fi, err := os.Stat(osPath(path))
+	if err != nil {
+		return unreadable(err)
+	}
+	f, err := os.Open(osPath(path))
+	if err != nil {
+		return unreadable(err)
+	}
+	opts := append(s.opts[:len(s.opts):len(s.opts)], fletcher4.WithProgress(func(p fletcher4.Progress) {
+		s.stats.fileDone.Store(p.Done)
+		s.stats.fileRate.Store(math.Float64bits(p.Rate))
+	}))
+	sum, n, err := fletcher4.SumReaderContext(ctx, f, opts...)
+	f.Close()
+	s.stats.fileDone.Store(0)
+	s.stats.fileRate.Store(0)
+	s.stats.bytes.Add(n)
+	s.stats.passBytes.Add(n)
+	if ctx.Err() != nil {
+		return true
+	}
+	if err != nil {
+		return unreadable(fmt.Errorf("%v: %w", path, err))
+	}
+	s.stats.files.Add(1)
+
+	seen := scrubFile{Size: fi.Size(), ModTime: fi.ModTime(), Checksum: sum.String(), Pass: s.state.PassStart}
+	after, err := os.Stat(osPath(path))
+	modified := !known || err != nil || prev.Size != seen.Size || !prev.ModTime.Equal(seen.ModTime) ||
+		after.Size() != seen.Size || !after.ModTime().Equal(seen.ModTime)
+	if !modified && prev.Checksum != seen.Checksum {
+		c.failure(result{Path: path, Size: n, Checksum: sum, Expected: expected},
+			fmt.Sprintf("WARNING: %v changed without being modified, checksum changed from %v to %v", path, prev.Checksum, seen.Checksum))
+		c.report(path, "FAILED")
+		s.stats.mismatches.Add(1)
+		prev.Pass = seen.Pass
+		s.state.Files[path] = prev
+		return false
+	}
+	s.state.Files[path] = seen
+	return true
+}
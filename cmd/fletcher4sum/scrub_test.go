@@ -0,0 +1,121 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that scrub passes flag files changed without being modified, and continue from their position
+func TestScrub(t *testing.T) {
+	writeFiles(t, nil)
+	os.Mkdir("data", 0o755)
+	a, b := filepath.Join("data", "a"), filepath.Join("data", "b")
+	os.WriteFile(a, []byte("abcd"), 0o644)
+	os.WriteFile(b, []byte("efgh"), 0o644)
+	scrub := func(args ...string) (int, string, string) {
+		return fletcher4sum("", append([]string{"scrub", "-once", "-state", "state"}, args...)...)
+	}
+
+	status, stdout, _ := scrub("data")
+	if status != 0 || !strings.HasPrefix(stdout, "scrub pass finished in 0s: 2 files verified, 8 B, 0 mismatches found, 0 files unreadable\n") {
+		t.Fatalf("First pass gave status %v, %q", status, stdout)
+	}
+	// The next pass is not due yet
+	if status, stdout, _ := scrub("data"); status != 0 || stdout != "" {
+		t.Errorf("Pass not due gave status %v, %q", status, stdout)
+	}
+
+	// Rot keeps the modification time, changes made by users do not
+	fi, _ := os.Stat(a)
+	os.WriteFile(a, []byte("abcx"), 0o644)
+	os.Chtimes(a, fi.ModTime(), fi.ModTime())
+	os.WriteFile(b, []byte("new data"), 0o644)
+	os.Chtimes(b, fi.ModTime(), fi.ModTime().Add(time.Second))
+	for i := 0; i < 2; i++ {
+		status, stdout, stderr := scrub("-interval", "0", "data")
+		if status != 1 || !strings.HasPrefix(stdout, a+": FAILED\nscrub pass finished in 0s: 2 files verified, 12 B, 1 mismatch found") ||
+			!strings.Contains(stderr, "WARNING: "+a+" changed without being modified, checksum changed from "+sumOf("abcd")+" to "+sumOf("abcx")) {
+			t.Errorf("Pass %v after rot gave status %v, %q, %q", i, status, stdout, stderr)
+		}
+	}
+
+	// A restarted pass continues after the last file verified
+	s := &scrubber{path: "state"}
+	if err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	s.state.PassEnd = time.Time{}
+	s.state.Position = scrubPosition{Path: a}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if status, stdout, _ := scrub("data"); status != 0 || !strings.HasPrefix(stdout, "scrub pass finished in 0s: 1 file verified, 8 B") {
+		t.Errorf("Restarted pass gave status %v, %q", status, stdout)
+	}
+
+	// Removed files are forgotten
+	os.Remove(b)
+	scrub("-interval", "0", "data")
+	if err := s.load(); err != nil || len(s.state.Files) != 1 {
+		t.Errorf("State after removing a file holds %v, %v", s.state.Files, err)
+	}
+
+	if status, _, _ := fletcher4sum("", "scrub"); status != 2 {
+		t.Errorf("Scrub without paths gave status %v", status)
+	}
+}
+
+// Test that scrub keeps running until interrupted
+func TestScrubDaemon(t *testing.T) {
+	runs := fakeWatch(t)
+	writeFiles(t, map[string]string{"a": "abcd"})
+	var stdout, stderr syncBuffer
+	status := make(chan int)
+	go func() {
+		status <- run([]string{"scrub", "-state", ".state", "-skip-hidden", "."}, strings.NewReader(""), &stdout, &stderr)
+	}()
+	stop := <-runs
+	waitFor(t, &stdout, "scrub pass finished in 0s: 1 file verified")
+	close(stop)
+	if s := <-status; s != 0 {
+		t.Errorf("Scrub gave status %v, %q", s, stderr.String())
+	}
+}
+
+func TestScrubPosition(t *testing.T) {
+	p := scrubPosition{Root: 1, Path: filepath.Join("d", "a", "x")}
+	for _, c := range []struct {
+		root  int
+		path  string
+		after bool
+	}{
+		{0, filepath.Join("d", "z"), false},
+		{2, filepath.Join("d", "a"), true},
+		{1, filepath.Join("d", "a", "x"), false},
+		{1, filepath.Join("d", "a", "w"), false},
+		{1, filepath.Join("d", "a", "x", "y"), true},
+		{1, filepath.Join("d", "a-b"), true},
+		{1, filepath.Join("d", "b"), true},
+	} {
+		if after := p.after(c.root, c.path); after != c.after {
+			t.Errorf("%v %v after %v is %v", c.root, c.path, p, after)
+		}
+	}
+}
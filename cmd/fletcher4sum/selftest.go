@@ -0,0 +1,133 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/corpus"
+)
+
+// Sizes and offsets of the inputs the implementations are compared on besides the corpus, odd lengths and
+// misaligned starts the corpus does not cover
+var (
+	paritySizes   = []int{1, 3, 5, 63, 257, 4097, 65539}
+	parityOffsets = []int{0, 1, 2, 3}
+)
+
+// Length of the writes of the parity inputs, which split words and SIMD blocks
+const parityWrite = 4093
+
+// selftest runs the selftest subcommand, which checks every implementation available on this cpu against the
+// embedded test corpus and the scalar implementation, and returns the exit status.
+func (c *command) selftest(args []string) int {
+	flags := flag.NewFlagSet("fletcher4sum selftest", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: fletcher4sum selftest")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// Checking selects each implementation in turn
+	current := fletcher4.Implementation()
+	defer fletcher4.SetImplementation(current)
+	if err := fletcher4.SetImplementation("scalar"); err != nil {
+		c.errorf("%v", err)
+		return 1
+	}
+	parity := parityInputs()
+	reference := make([]fletcher4.Checksum, len(parity))
+	for i, p := range parity {
+		reference[i] = fletcher4.Sum(p)
+	}
+
+	status := 0
+	vectors := corpus.Vectors()
+	for _, name := range fletcher4.Implementations() {
+		if err := fletcher4.SetImplementation(name); err != nil {
+			c.errorf("%v", err)
+			return 1
+		}
+		failed := 0
+		for _, v := range vectors {
+			input := v.Input()
+			if sum := fletcher4.Sum(input); sum != v.Checksum {
+				c.errorf("%v: vector %v: expected %v, got %v", name, v.Name, v.Checksum, sum)
+				failed++
+			}
+			if sum := fletcher4.ChecksumByteswap(input); sum != v.Byteswap {
+				c.errorf("%v: vector %v byte swapped: expected %v, got %v", name, v.Name, v.Byteswap, sum)
+				failed++
+			}
+		}
+		for i, p := range parity {
+			if sum := writeSum(p); sum != reference[i] {
+				c.errorf("%v: %v bytes at offset %v differ from scalar: expected %v, got %v", name, len(p),
+					offsetOf(i), reference[i], sum)
+				failed++
+			}
+		}
+		outcome := "OK"
+		if failed > 0 {
+			outcome = "FAILED"
+			status = 1
+		}
+		fmt.Fprintf(c.stdout, "%v: %v, %v vectors and %v parity checks\n", name, outcome, 2*len(vectors), len(parity))
+	}
+	if status != 0 {
+		c.errorf("WARNING: implementations compute wrong checksums, do not rely on this build")
+	}
+	return status
+}
+
+// parityInputs returns the inputs compared with the scalar implementation, of every parity size at every
+// offset into a pseudo random buffer.
+func parityInputs() [][]byte {
+	buf := make([]byte, paritySizes[len(paritySizes)-1]+parityOffsets[len(parityOffsets)-1])
+	corpus.Fill("lcg", buf)
+	var inputs [][]byte
+	for _, off := range parityOffsets {
+		for _, n := range paritySizes {
+			inputs = append(inputs, buf[off:off+n])
+		}
+	}
+	return inputs
+}
+
+// offsetOf returns the offset of the parity input i.
+func offsetOf(i int) int {
+	return parityOffsets[i/len(paritySizes)]
+}
+
+// writeSum returns the checksum of p written in pieces of parityWrite bytes.
+func writeSum(p []byte) fletcher4.Checksum {
+	h := fletcher4.NewHashingWriter(io.Discard)
+	for len(p) > parityWrite {
+		h.Write(p[:parityWrite])
+		p = p[parityWrite:]
+	}
+	h.Write(p)
+	return h.Checksum()
+}
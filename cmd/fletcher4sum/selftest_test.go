@@ -0,0 +1,42 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that every implementation passes the self test, and the implementation in use is restored
+func TestSelftest(t *testing.T) {
+	current := fletcher4.Implementation()
+	status, stdout, stderr := fletcher4sum("", "selftest")
+	if status != 0 || stderr != "" {
+		t.Errorf("Self test gave status %v, %q", status, stderr)
+	}
+	for _, name := range fletcher4.Implementations() {
+		if !strings.Contains(stdout, name+": OK") {
+			t.Errorf("Output %q does not pass %v", stdout, name)
+		}
+	}
+	if fletcher4.Implementation() != current {
+		t.Errorf("Self test left %v in use, not %v", fletcher4.Implementation(), current)
+	}
+	if status, _, _ := fletcher4sum("", "selftest", "extra"); status != 2 {
+		t.Errorf("Self test with an argument gave status %v", status)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patterns is a flag given several times, collecting glob patterns.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(pattern string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+	// Backslash separators, as given on Windows, are matched like slashes
+	*p = append(*p, filepath.ToSlash(pattern))
+	return nil
+}
+
+// match reports whether any pattern matches the base name of the slash separated path rel, or the whole of
+// it for patterns holding a slash.
+func (p patterns) match(rel string) bool {
+	for _, pattern := range p {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkOptions select the files hashed by -r.
+type walkOptions struct {
+	recursive bool
+	include   patterns
+	exclude   patterns
+	// Whether to skip files and directories whose name starts with a dot
+	skipHidden bool
+	// Whether to follow symbolic links, which are skipped otherwise
+	follow bool
+}
+
+// expand calls fn with path, or with every file below it in lexical order if it is a directory and -r is
+// given, and with the errors finding them.
+func (c *command) expand(path string, fn func(path string, err error)) {
+	if !c.walk.recursive || path == stdinName {
+		fn(path, nil)
+		return
+	}
+	fi, err := os.Stat(osPath(path))
+	if err != nil || !fi.IsDir() {
+		fn(path, nil)
+		return
+	}
+	c.walkDir(path, "", []os.FileInfo{fi}, fn)
+}
+
+// walkDir walks the directory dir, at the slash separated path rel below the root. parents are the
+// directories walked into, to stop at symbolic links looping back to one of them.
+func (c *command) walkDir(dir, rel string, parents []os.FileInfo, fn func(path string, err error)) {
+	entries, err := os.ReadDir(osPath(dir))
+	if err != nil {
+		fn(dir, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		erel := e.Name()
+		if rel != "" {
+			erel = rel + "/" + e.Name()
+		}
+		if c.walk.skipHidden && strings.HasPrefix(e.Name(), ".") || c.walk.exclude.match(erel) {
+			continue
+		}
+
+		mode := e.Type()
+		if mode&fs.ModeSymlink != 0 {
+			if !c.walk.follow {
+				continue
+			}
+			fi, err := os.Stat(osPath(path))
+			if err != nil {
+				fn(path, err)
+				continue
+			}
+			mode = fi.Mode().Type()
+		}
+		switch {
+		case mode.IsDir():
+			fi, err := os.Stat(osPath(path))
+			if err != nil {
+				fn(path, err)
+				continue
+			}
+			if !looping(fi, parents) {
+				c.walkDir(path, erel, append(parents, fi), fn)
+			}
+		case mode.IsRegular():
+			if len(c.walk.include) == 0 || c.walk.include.match(erel) {
+				fn(path, nil)
+			}
+		}
+	}
+}
+
+func looping(fi os.FileInfo, parents []os.FileInfo) bool {
+	for _, p := range parents {
+		if os.SameFile(fi, p) {
+			return true
+		}
+	}
+	return false
+}
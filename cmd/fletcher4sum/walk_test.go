@@ -0,0 +1,74 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that -r hashes trees in lexical order, with the include, exclude, hidden and symlink policies
+func TestRecursive(t *testing.T) {
+	writeFiles(t, map[string]string{})
+	for _, dir := range []string{"tree/sub", "tree/.git", "tree/skip"} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"tree/b.txt", "tree/a.log", "tree/sub/c.txt", "tree/.hidden", "tree/.git/config",
+		"tree/skip/d.txt", "tree/sub/odd\nname.txt"} {
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlinks := os.Symlink("sub", "tree/link") == nil && os.Symlink("..", "tree/sub/loop") == nil
+
+	paths := func(args ...string) string {
+		status, stdout, stderr := fletcher4sum("", append([]string{"-z"}, args...)...)
+		if status != 0 {
+			t.Errorf("%v gave status %v: %v", args, status, stderr)
+		}
+		var names []string
+		for _, line := range strings.Split(strings.TrimSuffix(stdout, "\x00"), "\x00") {
+			names = append(names, filepath.ToSlash(line[len(sumOf(""))+2:]))
+		}
+		return strings.Join(names, " ")
+	}
+	if got, exp := paths("-r", "tree"), "tree/.git/config tree/.hidden tree/a.log tree/b.txt tree/skip/d.txt tree/sub/c.txt tree/sub/odd\nname.txt"; got != exp {
+		t.Errorf("Tree hashed as %q, expected %q", got, exp)
+	}
+	if got, exp := paths("-r", "--skip-hidden", "--include", "*.txt", "--exclude", "skip", "tree"), "tree/b.txt tree/sub/c.txt tree/sub/odd\nname.txt"; got != exp {
+		t.Errorf("Filtered tree hashed as %q, expected %q", got, exp)
+	}
+	if got, exp := paths("-r", "--include", "sub/*.txt", "tree"), "tree/sub/c.txt tree/sub/odd\nname.txt"; got != exp {
+		t.Errorf("Tree filtered by path hashed as %q, expected %q", got, exp)
+	}
+	if symlinks {
+		// The link to the parent directory is not followed in circles
+		got := paths("-r", "-L", "--skip-hidden", "--include", "c.txt", "tree")
+		if exp := "tree/link/c.txt tree/sub/c.txt"; got != exp {
+			t.Errorf("Tree with followed symlinks hashed as %q, expected %q", got, exp)
+		}
+	}
+
+	if status, _, _ := fletcher4sum("", "tree"); status != 1 {
+		t.Errorf("Hashing a directory without -r gave status %v", status)
+	}
+	if status, _, _ := fletcher4sum("", "--exclude", "[", "tree"); status != 2 {
+		t.Errorf("Invalid pattern gave status %v", status)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.solidsystem.no/fletcher4"
+)
+
+// Time a file must be left alone after a change before it is hashed again, so files being written are hashed
+// once they are complete
+var watchSettle = time.Second
+
+// interrupted returns a channel closed when the process is asked to stop, ending --watch, and a function to
+// call when done waiting. Replaced by the tests.
+var interrupted = func() (<-chan struct{}, func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		if _, ok := <-sig; ok {
+			close(stop)
+		}
+	}()
+	return stop, func() {
+		signal.Stop(sig)
+		close(sig)
+	}
+}
+
+// remember records the checksum the file at path should keep with --watch, the one it was listed with in
+// check mode or the one it first had otherwise.
+func (c *command) remember(path string, sum fletcher4.Checksum) {
+	if c.watched == nil || path == stdinName {
+		return
+	}
+	c.watched[filepath.Clean(path)] = watchedFile{path: path, expected: sum}
+}
+
+// watchedFile is a file hashed again whenever it changes with --watch.
+type watchedFile struct {
+	path     string
+	expected fletcher4.Checksum
+	// Whether the file was last reported as modified
+	modified bool
+}
+
+// watch hashes the remembered files again as they change until interrupted, and flags those no longer having
+// their expected checksum. In check mode the outcome is reported like for -c, otherwise the new checksums are
+// written to out. It returns the exit status, status or 1 if any file was modified.
+func (c *command) watch(out output, status int) int {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.errorf("%v", err)
+		return 1
+	}
+	defer w.Close()
+	// Files are often replaced rather than written, so their directories are watched
+	dirs := map[string]bool{}
+	for _, f := range c.watched {
+		dir := filepath.Dir(f.path)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := w.Add(dir); err != nil {
+			c.errorf("watching %v: %v", dir, err)
+			status = 1
+		}
+	}
+
+	stop, done := interrupted()
+	defer done()
+	ticker := time.NewTicker(watchSettle / 4)
+	defer ticker.Stop()
+	changed := map[string]time.Time{}
+	for {
+		select {
+		case <-stop:
+			return status
+		case ev := <-w.Events:
+			if _, ok := c.watched[filepath.Clean(ev.Name)]; ok {
+				changed[filepath.Clean(ev.Name)] = time.Now()
+			}
+		case err := <-w.Errors:
+			c.errorf("watching: %v", err)
+			status = 1
+		case now := <-ticker.C:
+			var settled []string
+			for name, t := range changed {
+				if now.Sub(t) >= watchSettle {
+					settled = append(settled, name)
+					delete(changed, name)
+				}
+			}
+			sort.Strings(settled)
+			for _, name := range settled {
+				if c.rehash(name, out) {
+					status = 1
+				}
+			}
+		}
+	}
+}
+
+// rehash hashes the watched file name again after a change, and reports it if it was modified, or restored
+// after being reported. It returns whether the file was modified.
+func (c *command) rehash(name string, out output) bool {
+	f := c.watched[name]
+	res := c.hash(f.path)
+	res.Expected = f.expected
+	modified := res.Err != nil || res.Checksum != f.expected
+	defer func() {
+		f.modified = modified
+		c.watched[name] = f
+	}()
+	if !modified && !f.modified {
+		// Only touched, or rewritten with the same data
+		return false
+	}
+
+	c.progress.clear()
+	switch {
+	case errors.Is(res.Err, fs.ErrNotExist):
+		c.failure(res, fmt.Sprintf("WARNING: %v was removed", f.path))
+	case res.Err != nil:
+		c.failure(res, res.Err.Error())
+	case modified:
+		c.failure(res, fmt.Sprintf("WARNING: %v was modified, checksum changed from %v to %v", f.path, c.formatSum(f.expected), c.formatSum(res.Checksum)))
+	}
+	if c.check {
+		switch {
+		case res.Err != nil:
+			c.report(f.path, "FAILED open or read")
+		case modified:
+			c.report(f.path, "FAILED")
+		default:
+			c.report(f.path, "OK")
+		}
+	} else if res.Err == nil {
+		if err := out.write(res); err != nil {
+			c.errorf("%v", err)
+		}
+	}
+	return modified
+}
@@ -0,0 +1,133 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to write and read from several goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until the buffer holds s
+func waitFor(t *testing.T, b *syncBuffer, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(b.String(), s); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Waiting for %q, got %q", s, b.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// fakeWatch makes --watch settle quickly and stop when told, for the rest of the test. Each run started with
+// watching sends its stop channel once it watches.
+func fakeWatch(t *testing.T) chan chan struct{} {
+	settle, fn := watchSettle, interrupted
+	t.Cleanup(func() { watchSettle, interrupted = settle, fn })
+	watchSettle = 50 * time.Millisecond
+	runs := make(chan chan struct{})
+	interrupted = func() (<-chan struct{}, func()) {
+		stop := make(chan struct{})
+		runs <- stop
+		return stop, func() {}
+	}
+	return runs
+}
+
+// watching runs the command with --watch in the background, and returns its output and a function stopping it
+// and returning its exit status
+func watching(runs chan chan struct{}, args ...string) (*syncBuffer, *syncBuffer, func() int) {
+	var stdout, stderr syncBuffer
+	status := make(chan int)
+	go func() {
+		status <- run(append([]string{"--watch"}, args...), strings.NewReader(""), &stdout, &stderr)
+	}()
+	stop := <-runs
+	return &stdout, &stderr, func() int {
+		close(stop)
+		return <-status
+	}
+}
+
+// Test that modified files are hashed again and flagged in check mode, and reported as OK once restored
+func TestWatchCheck(t *testing.T) {
+	runs := fakeWatch(t)
+	writeFiles(t, map[string]string{"a": "abc", "b": "def"})
+	if err := os.WriteFile("SUMS", []byte(sumOf("abc")+"  a\n"+sumOf("def")+"  b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, stop := watching(runs, "-c", "SUMS")
+	waitFor(t, stdout, "a: OK\nb: OK\n")
+
+	// Touching a file with the same data is not reported
+	os.WriteFile("a", []byte("abc"), 0o644)
+	os.WriteFile("b", []byte("changed"), 0o644)
+	waitFor(t, stdout, "b: FAILED\n")
+	waitFor(t, stderr, "WARNING: b was modified, checksum changed from "+sumOf("def")+" to "+sumOf("changed"))
+	os.WriteFile("b", []byte("def"), 0o644)
+	waitFor(t, stdout, "b: FAILED\nb: OK\n")
+	os.Remove("a")
+	waitFor(t, stdout, "a: FAILED open or read\n")
+	waitFor(t, stderr, "WARNING: a was removed")
+
+	if status := stop(); status != 1 {
+		t.Errorf("Watch gave status %v", status)
+	}
+	if strings.Contains(stdout.String(), "a: OK\nb: OK\na: OK") {
+		t.Errorf("Unmodified file reported: %q", stdout.String())
+	}
+}
+
+// Test that the new checksums of modified files are printed when hashing
+func TestWatchHash(t *testing.T) {
+	runs := fakeWatch(t)
+	writeFiles(t, map[string]string{"a": "abc"})
+	stdout, stderr, stop := watching(runs, "a")
+	waitFor(t, stdout, sumOf("abc")+"  a\n")
+	os.WriteFile("a", []byte("rot"), 0o644)
+	waitFor(t, stdout, sumOf("rot")+"  a\n")
+	waitFor(t, stderr, "WARNING: a was modified")
+	if status := stop(); status != 1 {
+		t.Errorf("Watch gave status %v", status)
+	}
+
+	writeFiles(t, map[string]string{"a": "abc"})
+	stdout, _, stop = watching(runs, "a")
+	waitFor(t, stdout, sumOf("abc")+"  a\n")
+	if status := stop(); status != 0 {
+		t.Errorf("Watch of unchanged file gave status %v", status)
+	}
+}
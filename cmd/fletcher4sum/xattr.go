@@ -0,0 +1,139 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// checkXattrs verifies the files at paths, or below them with -r, against the checksums stored in their extended
+// attributes, and returns the exit status. Files modified since their checksum was stored are reported, but do not
+// fail, only files whose data changed while their modification time did not.
+func (c *command) checkXattrs(paths []string) int {
+	var failed, unread, modified, unstored int
+	results := runOrdered(context.Background(), c.jobs, func(submit func(func() result) bool) {
+		for _, path := range paths {
+			c.expand(path, func(path string, err error) {
+				submit(func() result {
+					if err != nil {
+						return result{Path: path, Err: err}
+					}
+					return c.verifyXattr(path)
+				})
+			})
+		}
+	})
+	for r := range results {
+		c.progress.clear()
+		switch {
+		case errors.Is(r.Err, fletcher4.ErrNoXattr):
+			if !c.statusOnly {
+				c.errorf("%v: no stored checksum", r.Path)
+			}
+			unstored++
+		case r.Err != nil:
+			c.failure(r, r.Err.Error())
+			c.report(r.Path, "FAILED open or read")
+			unread++
+		case r.Xattr.Status == fletcher4.XattrCorrupt:
+			if !c.statusOnly {
+				c.failure(r, fmt.Sprintf("%v: checksum changed since hashed at %v: expected %v, got %v", r.Path,
+					formatTime(r.Xattr.Stored.ModTime), c.formatSum(r.Expected), c.formatSum(r.Checksum)))
+			}
+			c.report(r.Path, "FAILED")
+			failed++
+		case r.Xattr.Status == fletcher4.XattrModified:
+			c.report(r.Path, "MODIFIED since hashed at "+formatTime(r.Xattr.Stored.ModTime))
+			modified++
+		default:
+			c.report(r.Path, "OK")
+		}
+	}
+
+	if !c.statusOnly {
+		if unstored > 0 {
+			c.errorf("WARNING: %v", plural(unstored, "file has", "files have", "no stored checksum"))
+		}
+		if modified > 0 {
+			c.errorf("WARNING: %v", plural(modified, "file was", "files were", "modified since hashed"))
+		}
+		if unread > 0 {
+			c.errorf("WARNING: %v", plural(unread, "file", "files", "could not be read"))
+		}
+		if failed > 0 {
+			c.errorf("WARNING: %v", plural(failed, "computed checksum", "computed checksums", "did NOT match"))
+		}
+	}
+	if failed > 0 || unread > 0 {
+		return 1
+	}
+	return 0
+}
+
+// verifyXattr hashes the file at path and compares it to the checksum stored in its extended attribute.
+func (c *command) verifyXattr(path string) result {
+	res := result{Path: path}
+	if fi, err := os.Stat(path); err == nil {
+		res.Size = fi.Size()
+	}
+	start := time.Now()
+	res.Xattr, res.Err = fletcher4.VerifyXattr(path, c.progress.options(path)...)
+	res.Duration = time.Since(start)
+	c.progress.finished(path, res.Size)
+	if res.Err == nil {
+		res.Checksum, res.ModTime = res.Xattr.Actual.Checksum, res.Xattr.Actual.ModTime
+		res.Expected = res.Xattr.Stored.Checksum
+	} else if !errors.Is(res.Err, fletcher4.ErrNoXattr) {
+		res.Err = fmt.Errorf("%v: %w", path, res.Err)
+	}
+	return res
+}
+
+// formatTime formats a modification time in messages, in UTC like the machine readable records.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// storeXattr hashes the file at path and stores its checksum in its extended attribute, unless the checksum stored
+// already has the modification time of the file and force is false.
+func (c *command) storeXattr(path string, force bool) result {
+	res := result{Path: path}
+	fi, err := os.Stat(path)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Size, res.ModTime = fi.Size(), fi.ModTime()
+	if stored, err := fletcher4.ReadXattr(path); err == nil && !force && stored.ModTime.Equal(fi.ModTime()) {
+		res.Skipped = true
+		return res
+	}
+	start := time.Now()
+	stored, err := fletcher4.StoreXattr(path, c.progress.options(path)...)
+	res.Duration = time.Since(start)
+	c.progress.finished(path, res.Size)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Checksum, res.ModTime = stored.Checksum, stored.ModTime
+	return res
+}
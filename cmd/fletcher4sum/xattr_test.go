@@ -0,0 +1,115 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// storeXattr stores the checksum of the file name, skipping the test where the file system does not support user
+// extended attributes
+func storeXattr(t *testing.T, name string) fletcher4.StoredSum {
+	t.Helper()
+	stored, err := fletcher4.StoreXattr(name)
+	if errors.Is(err, fletcher4.ErrXattrUnsupported) {
+		t.Skipf("Extended attributes not supported: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// Test that files are verified against their extended attributes, failing only those changed silently
+func TestXattrVerify(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "intact", "b": "rotting", "c": "edited", "d": "unhashed"})
+	storeXattr(t, "a")
+	stored := storeXattr(t, "b")
+	storeXattr(t, "c")
+	if err := os.WriteFile("b", []byte("rotted!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes("b", time.Now(), stored.ModTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes("c", time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	status, stdout, stderr := fletcher4sum("", "--xattr-verify", "a", "b", "c", "d")
+	hashed := stored.ModTime.UTC().Format(time.RFC3339Nano)
+	if exp := "a: OK\nb: FAILED\nc: MODIFIED since hashed at "; status != 1 || !strings.HasPrefix(stdout, exp) {
+		t.Errorf("Verifying gave status %v, %q", status, stdout)
+	}
+	drift := "b: checksum changed since hashed at " + hashed + ": expected " + sumOf("rotting") + ", got " + sumOf("rotted!")
+	for _, s := range []string{drift, "d: no stored checksum", "1 computed checksum did NOT match", "1 file has no stored checksum"} {
+		if !strings.Contains(stderr, s) {
+			t.Errorf("Warnings %q do not contain %q", stderr, s)
+		}
+	}
+
+	if status, stdout, _ := fletcher4sum("", "--xattr-verify", "--quiet", "a", "c", "d"); status != 0 || strings.Contains(stdout, "OK") {
+		t.Errorf("Verifying unchanged files with --quiet gave status %v, %q", status, stdout)
+	}
+	if status, stdout, stderr := fletcher4sum("", "--xattr-verify", "--status", "b"); status != 1 || stdout != "" || stderr != "" {
+		t.Errorf("Verifying with --status gave status %v, %q, %q", status, stdout, stderr)
+	}
+	if status, _, stderr := fletcher4sum("", "--xattr-verify", "missing"); status != 1 || !strings.Contains(stderr, "missing") {
+		t.Errorf("Verifying missing file gave status %v, %q", status, stderr)
+	}
+	for _, args := range [][]string{{"--xattr-verify"}, {"--xattr-verify", "-c", "a"}, {"--xattr-verify", "--format", "json", "a"}} {
+		if status, _, _ := fletcher4sum("", args...); status != 2 {
+			t.Errorf("%q gave status %v", args, status)
+		}
+	}
+}
+
+// Test that checksums are stored, and files unchanged since skipped unless forced
+func TestXattrStore(t *testing.T) {
+	writeFiles(t, map[string]string{"a": "hello", "b": "world"})
+	storeXattr(t, "a")
+	status, stdout, stderr := fletcher4sum("", "--xattr-store", "a", "b")
+	if status != 0 || stdout != sumOf("world")+"  b\n" || !strings.Contains(stderr, "1 file skipped") {
+		t.Errorf("Storing gave status %v, %q, %q", status, stdout, stderr)
+	}
+	if stored, err := fletcher4.ReadXattr("b"); err != nil || stored.Checksum.String() != sumOf("world") {
+		t.Errorf("Stored %v, %v for b", stored, err)
+	}
+
+	if err := os.WriteFile("a", []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes("a", time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, _ = fletcher4sum("", "--xattr-store", "--force", "a", "b")
+	if exp := sumOf("changed") + "  a\n" + sumOf("world") + "  b\n"; status != 0 || stdout != exp {
+		t.Errorf("Storing with --force gave status %v, %q", status, stdout)
+	}
+	if status, stdout, _ := fletcher4sum("", "--xattr-verify", "a", "b"); status != 0 || stdout != "a: OK\nb: OK\n" {
+		t.Errorf("Verifying stored checksums gave status %v, %q", status, stdout)
+	}
+	for _, args := range [][]string{{"--force", "a"}, {"--xattr-store"}, {"--xattr-store", "--xattr-verify", "a"}} {
+		if status, _, _ := fletcher4sum("", args...); status != 2 {
+			t.Errorf("%q gave status %v", args, status)
+		}
+	}
+}
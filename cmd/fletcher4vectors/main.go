@@ -0,0 +1,110 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command fletcher4vectors writes the test vectors of the corpus package with their inputs, for checking other
+// implementations against this one, e.g. in C, Rust or Python.
+//
+// Each vector is one line of JSON, with the checksum words as hex strings as JSON numbers cannot hold 64 bit
+// values everywhere:
+//
+//	{"name": "incrementing-64", "size": 64, "input": "000102...", "checksum": ["...", "...", "...", "..."], "serialized": "..."}
+//
+// The input is hex encoded and its size always a multiple of 4 bytes. The checksum words are a, b, c and d, also
+// serialized as 64 hex digits of their little endian bytes in "serialized". With -format text the same vectors
+// are written as lines of "name size input a b c d" instead, with "-" for the empty input.
+//
+// Usage:
+//
+//	fletcher4vectors [-format json|text] [-max bytes] > vectors.jsonl
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/corpus"
+)
+
+// Vector is one test vector.
+type Vector struct {
+	Name       string    `json:"name"`
+	Size       int       `json:"size"`
+	Input      string    `json:"input"`
+	Checksum   [4]string `json:"checksum"`
+	Serialized string    `json:"serialized"`
+}
+
+func newVector(name string, input []byte) Vector {
+	h := fletcher4.New()
+	h.Write(input)
+	sum := fletcher4.Checksum(h.Sum64x4())
+	v := Vector{Name: name, Size: len(input), Input: hex.EncodeToString(input), Serialized: sum.String()}
+	for i, w := range sum {
+		v.Checksum[i] = fmt.Sprintf("%016x", w)
+	}
+	return v
+}
+
+// vectors returns all vectors of the embedded corpus of at most max bytes.
+func vectors(max int) []Vector {
+	var res []Vector
+	for _, v := range corpus.Vectors() {
+		if v.Size <= max {
+			res = append(res, newVector(v.Name, v.Input()))
+		}
+	}
+	return res
+}
+
+func write(w io.Writer, vs []Vector, format string) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, v := range vs {
+		var err error
+		switch format {
+		case "json":
+			err = enc.Encode(v)
+		case "text":
+			input := v.Input
+			if input == "" {
+				input = "-"
+			}
+			_, err = fmt.Fprintln(bw, v.Name, v.Size, input, v.Checksum[0], v.Checksum[1], v.Checksum[2], v.Checksum[3])
+		default:
+			return fmt.Errorf("unknown format %q", format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func main() {
+	format := flag.String("format", "json", "output format, json or text")
+	max := flag.Int("max", 64<<10, "largest input in bytes")
+	flag.Parse()
+	log.SetFlags(0)
+
+	if err := write(os.Stdout, vectors(*max), *format); err != nil {
+		log.Fatal(err)
+	}
+}
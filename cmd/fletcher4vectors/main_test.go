@@ -0,0 +1,61 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4/corpus"
+)
+
+// Test that vectors are written in both formats and the known ones hold the reference values
+func TestVectors(t *testing.T) {
+	vs := vectors(64)
+	if len(vs) != 10*len(corpus.Patterns) {
+		t.Fatalf("Got %v vectors of at most 64 bytes", len(vs))
+	}
+	for _, v := range vs {
+		if v.Name == "zeros-0" && v.Serialized != strings.Repeat("0", 64) {
+			t.Errorf("Checksum of no input is %v", v.Serialized)
+		}
+		// a is the sum of the words, 1+..+1 for the all ones word 0xffffffff
+		if v.Name == "ones-8" && v.Checksum[0] != "00000001fffffffe" {
+			t.Errorf("Checksum of 8 bytes of ones is %v", v.Checksum)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := write(&out, vs, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var first Vector
+	if err := json.Unmarshal(bytes.SplitN(out.Bytes(), []byte("\n"), 2)[0], &first); err != nil || first != vs[0] {
+		t.Errorf("First line decoded to %+v, %v", first, err)
+	}
+
+	out.Reset()
+	if err := write(&out, vs[:1], "text"); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "zeros-0 0 - 0000000000000000 0000000000000000 0000000000000000 0000000000000000\n"; out.String() != exp {
+		t.Errorf("Text format gave %q, expected %q", out.String(), exp)
+	}
+	if err := write(&out, vs, "xml"); err == nil {
+		t.Error("Unknown format succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+
+typedef struct fletcher4_ctx {
+	uint64_t sum[4];
+	uint8_t partial[4];
+	uint32_t npartial;
+} fletcher4_ctx;
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Fails to compile if state and fletcher4_ctx differ in size
+var _ [unsafe.Sizeof(state{}) - C.sizeof_fletcher4_ctx]byte
+var _ [C.sizeof_fletcher4_ctx - unsafe.Sizeof(state{})]byte
+
+func ctxState(ctx *C.fletcher4_ctx) *state {
+	return (*state)(unsafe.Pointer(ctx))
+}
+
+func bytes(buf unsafe.Pointer, n C.size_t) []byte {
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(buf), n)
+}
+
+func store(out *C.uint64_t, sum fletcher4.Checksum) {
+	copy(unsafe.Slice((*uint64)(unsafe.Pointer(out)), 4), sum[:])
+}
+
+//export fletcher4_init
+func fletcher4_init(ctx *C.fletcher4_ctx) {
+	*ctxState(ctx) = state{}
+}
+
+//export fletcher4_update
+func fletcher4_update(ctx *C.fletcher4_ctx, buf unsafe.Pointer, n C.size_t) {
+	ctxState(ctx).update(bytes(buf, n))
+}
+
+//export fletcher4_final
+func fletcher4_final(ctx *C.fletcher4_ctx, out *C.uint64_t) {
+	store(out, ctxState(ctx).final())
+}
+
+//export fletcher4_checksum
+func fletcher4_checksum(buf unsafe.Pointer, n C.size_t, out *C.uint64_t) {
+	store(out, fletcher4.Sum(bytes(buf, n)))
+}
@@ -0,0 +1,71 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command libfletcher4 is built as a C shared library exporting the fletcher4 checksum of this module, so C and
+// Python tooling can use it instead of a second implementation:
+//
+//	go build -buildmode=c-shared -o libfletcher4.so ./cmd/libfletcher4
+//
+// which also writes libfletcher4.h. The library exports:
+//
+//	typedef struct fletcher4_ctx { uint64_t sum[4]; uint8_t partial[4]; uint32_t npartial; } fletcher4_ctx;
+//
+//	void fletcher4_init(fletcher4_ctx *ctx);
+//	void fletcher4_update(fletcher4_ctx *ctx, void *buf, size_t len);
+//	void fletcher4_final(fletcher4_ctx *ctx, uint64_t sum[4]);
+//	void fletcher4_checksum(void *buf, size_t len, uint64_t sum[4]);
+//
+// The buffers are only read. Updates may have any length, a trailing partial word is padded with zero bytes by
+// fletcher4_final, which leaves the context unchanged so more data may follow. The context is plain data owned by the caller, it may
+// be copied, and is not safe for concurrent use. The words of the checksum are a, b, c and d, as in the
+// zio_cksum_t of ZFS. From Python:
+//
+//	lib = ctypes.CDLL("./libfletcher4.so")
+//	sum = (ctypes.c_uint64 * 4)()
+//	lib.fletcher4_checksum(data, len(data), sum)
+//
+// The kernel is selected like in Go programs, see fletcher4.EnvImplementation.
+package main
+
+import (
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/internal/words"
+)
+
+// Required by the c-shared build mode, never called
+func main() {}
+
+// state has the layout of fletcher4_ctx.
+type state struct {
+	sum     [4]uint64
+	partial words.Partial
+}
+
+// update adds p to the sum, keeping back a trailing partial word like the streams of the library do.
+func (s *state) update(p []byte) {
+	s.partial.Write(p, s.add)
+}
+
+// add adds p, a whole number of words long, to the sum.
+func (s *state) add(p []byte) {
+	s.sum = fletcher4.Combine(s.sum, fletcher4.Sum(p), int64(len(p)))
+}
+
+func (s *state) final() fletcher4.Checksum {
+	last, ok := s.partial.Padded()
+	if !ok {
+		return s.sum
+	}
+	return fletcher4.Combine(s.sum, fletcher4.Sum(last[:]), fletcher4.BlockSize)
+}
@@ -0,0 +1,43 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that updates of any length sum up to the checksum of all data at once, and that final leaves the state
+// unchanged
+func TestState(t *testing.T) {
+	data := make([]byte, 10003)
+	for i := range data {
+		data[i] = byte(i*7 + i>>5)
+	}
+	for _, step := range []int{1, 3, 4, 7, 4096, len(data)} {
+		var s state
+		for p := data; len(p) > 0; p = p[min(len(p), step):] {
+			s.update(p[:min(len(p), step)])
+			if n := len(data) - len(p) + min(len(p), step); s.final() != fletcher4.Sum(data[:n]) {
+				t.Fatalf("Checksum of %v bytes in steps of %v differs", n, step)
+			}
+		}
+	}
+	var s state
+	if s.final() != (fletcher4.Checksum{}) {
+		t.Error("Checksum of no data is not zero")
+	}
+}
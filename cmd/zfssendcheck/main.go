@@ -0,0 +1,76 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command zfssendcheck passes a zfs send stream from its standard input to its standard output unchanged, while
+// verifying the checksums of its records, for use in send pipelines:
+//
+//	zfs send -R pool/fs@snap | zfssendcheck | ssh backup zfs receive -u tank/fs
+//
+// Checksum failures are reported on standard error, followed by the statistics of the stream
+// once it ends: the number of streams and records, bytes, throughput and failures. The exit status is 1 if the
+// stream was damaged or could not be parsed, and 2 for usage errors. The stream is passed on in full either
+// way, leaving it to the receiving end to reject it.
+//
+// Usage:
+//
+//	zfssendcheck [-q] [-mode cumulative|per-record]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.solidsystem.no/fletcher4/zfssend"
+)
+
+var modes = map[string]zfssend.Mode{"cumulative": zfssend.Cumulative, "per-record": zfssend.PerRecord}
+
+// run passes stdin to stdout and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("zfssendcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	quiet := flags.Bool("q", false, "only report failures, not the statistics")
+	mode := flags.String("mode", "cumulative", "checksum convention of the stream, cumulative or per-record")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	m, ok := modes[*mode]
+	if !ok || flags.NArg() > 0 {
+		fmt.Fprintln(stderr, "usage: zfssendcheck [-q] [-mode cumulative|per-record]")
+		return 2
+	}
+
+	stats, err := zfssend.Pipe(stdout, stdin, zfssend.WithMode(m))
+	for _, f := range stats.Failures {
+		fmt.Fprintln(stderr, "zfssendcheck:", f)
+	}
+	var cerr *zfssend.ChecksumError
+	if err != nil && !errors.As(err, &cerr) {
+		fmt.Fprintln(stderr, "zfssendcheck:", err)
+	}
+	if !*quiet {
+		fmt.Fprintln(stderr, "zfssendcheck:", stats)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
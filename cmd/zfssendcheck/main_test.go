@@ -0,0 +1,68 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/zfssend"
+)
+
+// stream returns a send stream of an empty snapshot, a BEGIN and an END record
+func stream() []byte {
+	b := make([]byte, 2*zfssend.RecordSize)
+	binary.LittleEndian.PutUint64(b[8:], zfssend.BackupMagic)
+	end := b[zfssend.RecordSize:]
+	binary.LittleEndian.PutUint32(end, uint32(zfssend.End))
+	h := fletcher4.NewHashingWriter(io.Discard)
+	h.Write(b[:len(b)-fletcher4.Size])
+	h.Checksum().AppendBinary(end[:zfssend.RecordSize-fletcher4.Size])
+	return b
+}
+
+// Test that the stream is passed through, and damage and usage errors are reported in the exit status
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run(nil, bytes.NewReader(stream()), &stdout, &stderr); status != 0 {
+		t.Fatalf("Exit status %v: %v", status, stderr.String())
+	}
+	if !bytes.Equal(stdout.Bytes(), stream()) {
+		t.Error("Stream changed")
+	}
+	if !strings.Contains(stderr.String(), "1 streams, 2 records") {
+		t.Errorf("Statistics are %q", stderr.String())
+	}
+
+	corrupt := stream()
+	corrupt[100] ^= 1
+	stdout.Reset()
+	stderr.Reset()
+	if status := run([]string{"-q"}, bytes.NewReader(corrupt), &stdout, &stderr); status != 1 {
+		t.Errorf("Exit status of corrupt stream is %v", status)
+	}
+	if !bytes.Equal(stdout.Bytes(), corrupt) || !strings.Contains(stderr.String(), "checksum mismatch") ||
+		strings.Contains(stderr.String(), "records") {
+		t.Errorf("Corrupt stream reported as %q", stderr.String())
+	}
+
+	if status := run([]string{"-mode", "other"}, bytes.NewReader(nil), &stdout, &stderr); status != 2 {
+		t.Errorf("Exit status of unknown mode is %v", status)
+	}
+}
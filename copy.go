@@ -0,0 +1,66 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io"
+)
+
+// Copy copies from src to dst until EOF, like io.Copy, and returns the checksum of the data copied as well.
+// Each chunk is hashed right after it is read, while it is still in the cache, and then written, rather than
+// traversing the data twice as with io.TeeReader. A trailing partial word is padded with zero bytes.
+func Copy(dst io.Writer, src io.Reader, opts ...Option) (written int64, sum Checksum, err error) {
+	o := newOptions(opts)
+	m := o.newMeter(-1)
+	defer m.finish()
+	return copyHashed(dst, m.reader(context.Background(), src), make([]byte, o.chunkSizeFor(-1)))
+}
+
+// CopyN copies n bytes, or until an error, from src to dst and returns the checksum of the data copied as well.
+// Like io.CopyN, the error is io.EOF if fewer than n bytes were copied because src ended.
+func CopyN(dst io.Writer, src io.Reader, n int64, opts ...Option) (written int64, sum Checksum, err error) {
+	o := newOptions(opts)
+	m := o.newMeter(n)
+	defer m.finish()
+	written, sum, err = copyHashed(dst, m.reader(context.Background(), io.LimitReader(src, n)), make([]byte, o.chunkSizeFor(n)))
+	if written < n && err == nil {
+		err = io.EOF
+	}
+	return written, sum, err
+}
+
+func copyHashed(dst io.Writer, src io.Reader, buf []byte) (int64, Checksum, error) {
+	var s stream
+	for {
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			nw, werr := dst.Write(buf[:nr])
+			s.write(buf[:nw])
+			if werr == nil && nw != nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				return s.n, s.checksum(), werr
+			}
+		}
+		if rerr == io.EOF {
+			return s.n, s.checksum(), nil
+		}
+		if rerr != nil {
+			return s.n, s.checksum(), rerr
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Test that Copy copies everything and returns its checksum
+func TestCopy(t *testing.T) {
+	data := randomBytes(70001)
+	var out bytes.Buffer
+	written, sum, err := Copy(&out, bytes.NewReader(data), WithChunkSize(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Copy wrote %v bytes, expected %v unchanged", written, len(data))
+	}
+	if exp := paddedChecksum(data); sum != exp {
+		t.Errorf("Copy:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+	}
+}
+
+// Test that CopyN stops after n bytes, and returns EOF if the source is shorter
+func TestCopyN(t *testing.T) {
+	data := randomBytes(10000)
+	var out bytes.Buffer
+	written, sum, err := CopyN(&out, bytes.NewReader(data), 4321)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 4321 || !bytes.Equal(out.Bytes(), data[:4321]) {
+		t.Errorf("CopyN wrote %v bytes, expected 4321", written)
+	}
+	if exp := paddedChecksum(data[:4321]); sum != exp {
+		t.Errorf("CopyN:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+	}
+
+	if _, _, err := CopyN(io.Discard, bytes.NewReader(data), 20000); err != io.EOF {
+		t.Errorf("CopyN past the end returned error %v, expected %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package corpus embeds a corpus of fletcher4 test vectors, inputs and their expected native and byte swapped
+// checksums, so other implementations and their CI can be checked against the same values as this one.
+//
+// The inputs mirror the buffers the OpenZFS checksum tests run the kernels over: zeros, all ones, incrementing
+// bytes and pseudo random data, of sizes around the unrolled and SIMD block boundaries up to the 128K records
+// and 1M buffers benchmarked. Rather than storing the inputs, each vector names the pattern filling it, which
+// Fill reproduces. The expected values were computed with the scalar kernel, the reference all others are
+// tested against, and are the same cmd/fletcher4vectors writes. They are checked against OpenZFS as well: the
+// tests of the libzpool package, run with -tags libzpool, recompute every vector with fletcher_4_native and
+// fletcher_4_byteswap of each OpenZFS kernel.
+package corpus // import go.solidsystem.no/fletcher4/corpus
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+//go:embed vectors.txt
+var vectorsFile string
+
+// Vector is one test vector.
+type Vector struct {
+	// Pattern and size, e.g. "lcg-4096"
+	Name    string
+	Pattern string
+	Size    int
+	// Checksum of the input, and of the input read as big endian words
+	Checksum fletcher4.Checksum
+	Byteswap fletcher4.Checksum
+}
+
+// Input returns the input of the vector.
+func (v Vector) Input() []byte {
+	p := make([]byte, v.Size)
+	Fill(v.Pattern, p)
+	return p
+}
+
+// Patterns are the names of the patterns filling the inputs, as accepted by Fill.
+var Patterns = []string{"zeros", "ones", "incrementing", "lcg"}
+
+// Fill fills p with the named pattern. The pseudo random one, lcg, is a 32 bit linear congruential generator
+// seeded with the length of p, easily reproduced in other languages. Fill panics if the pattern is unknown.
+func Fill(pattern string, p []byte) {
+	switch pattern {
+	case "zeros":
+		clear(p)
+	case "ones":
+		for i := range p {
+			p[i] = 0xff
+		}
+	case "incrementing":
+		for i := range p {
+			p[i] = byte(i)
+		}
+	case "lcg":
+		x := uint32(len(p))
+		for i := range p {
+			x = x*1664525 + 1013904223
+			p[i] = byte(x >> 24)
+		}
+	default:
+		panic(fmt.Sprintf("Unknown pattern %q.", pattern))
+	}
+}
+
+var (
+	parseOnce sync.Once
+	vectors   []Vector
+	byName    map[string]int
+)
+
+// Vectors returns all vectors of the corpus, by increasing size. The slice is shared, do not modify it.
+func Vectors() []Vector {
+	parseOnce.Do(parse)
+	return vectors
+}
+
+// Lookup returns the vector of the given name.
+func Lookup(name string) (Vector, bool) {
+	parseOnce.Do(parse)
+	i, ok := byName[name]
+	if !ok {
+		return Vector{}, false
+	}
+	return vectors[i], true
+}
+
+// parse decodes the embedded vectors, lines of "pattern size checksum byteswapped" after the comments. The file
+// is tested, so errors are programmer errors.
+func parse() {
+	byName = make(map[string]int)
+	for i, line := range strings.Split(strings.TrimSpace(vectorsFile), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, err := parseVector(line)
+		if err != nil {
+			panic(fmt.Sprintf("Corpus line %v is invalid: %v.", i+1, err))
+		}
+		byName[v.Name] = len(vectors)
+		vectors = append(vectors, v)
+	}
+}
+
+func parseVector(line string) (Vector, error) {
+	var v Vector
+	fields := strings.Fields(line)
+	if len(fields) != 4 {
+		return v, fmt.Errorf("expected 4 fields, got %v", len(fields))
+	}
+	v.Pattern = fields[0]
+	var err error
+	if v.Size, err = strconv.Atoi(fields[1]); err != nil {
+		return v, err
+	}
+	v.Name = fmt.Sprintf("%v-%v", v.Pattern, v.Size)
+	if v.Checksum, err = fletcher4.ParseChecksum(fields[2]); err != nil {
+		return v, err
+	}
+	if v.Byteswap, err = fletcher4.ParseChecksum(fields[3]); err != nil {
+		return v, err
+	}
+	return v, nil
+}
@@ -0,0 +1,91 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package corpus
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+var update = flag.Bool("update", false, "rewrite vectors.txt with the values of the scalar kernel")
+
+// Sizes of the vectors, the empty input, single words, the unrolled and SIMD block boundaries and their
+// neighbours, and the record and buffer sizes where the sums wrap around
+var sizes = []int{0, 4, 8, 12, 16, 28, 32, 36, 60, 64, 68, 124, 128, 132, 252, 256, 260, 508, 512, 516, 1020, 1024,
+	4096, 4100, 16384, 65536, 131072, 1 << 20}
+
+// reference returns the corpus file as computed by the scalar kernel.
+func reference(t *testing.T) []byte {
+	defer fletcher4.SetImplementation(fletcher4.Implementation())
+	if err := fletcher4.SetImplementation("scalar"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	fmt.Fprintln(&out, "# fletcher4 test corpus, regenerate with go test -update")
+	fmt.Fprintln(&out, "# pattern size checksum byteswapped")
+	for _, size := range sizes {
+		for _, pattern := range Patterns {
+			input := make([]byte, size)
+			Fill(pattern, input)
+			native := fletcher4.Sum(input)
+			fmt.Fprintln(&out, pattern, size, native, fletcher4.ChecksumByteswap(input))
+		}
+	}
+	return out.Bytes()
+}
+
+// Test that the embedded corpus holds the values of the reference kernel
+func TestCorpus(t *testing.T) {
+	ref := reference(t)
+	if *update {
+		if err := os.WriteFile("vectors.txt", ref, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if vectorsFile != string(ref) {
+		t.Fatal("vectors.txt differs from the reference values, regenerate with go test -update")
+	}
+	if n := len(Vectors()); n != len(sizes)*len(Patterns) {
+		t.Errorf("Corpus has %v vectors", n)
+	}
+}
+
+// Test that every vector matches the kernel in use, and can be looked up by name
+func TestVectors(t *testing.T) {
+	for _, v := range Vectors() {
+		input := v.Input()
+		if sum := fletcher4.Sum(input); sum != v.Checksum {
+			t.Errorf("%v: expected %v, got %v", v.Name, v.Checksum, sum)
+		}
+		if sum := fletcher4.ChecksumByteswap(input); sum != v.Byteswap {
+			t.Errorf("%v byte swapped: expected %v, got %v", v.Name, v.Byteswap, sum)
+		}
+		if got, ok := Lookup(v.Name); !ok || got != v {
+			t.Errorf("Lookup of %v returned %+v, %v", v.Name, got, ok)
+		}
+	}
+	if _, ok := Lookup("zeros-3"); ok {
+		t.Error("Lookup of unknown vector succeeded")
+	}
+	if v, _ := Lookup("ones-8"); v.Checksum[0] != 0x1fffffffe {
+		t.Errorf("Checksum of 8 bytes of ones is %v", v.Checksum)
+	}
+}
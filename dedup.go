@@ -0,0 +1,99 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import "fmt"
+
+// Fingerprint identifies a block by its fletcher4 checksum and length. Fletcher4 is fast but not collision
+// resistant, blocks made to collide are easy to construct, so equal fingerprints only mean two blocks may be
+// equal. Use it as the cheap filter in front of a strong hash or a byte comparison, the way ZFS only trusts
+// dedup with a cryptographic checksum or verify.
+type Fingerprint struct {
+	Checksum Checksum
+	Length   int64
+}
+
+// NewFingerprint returns the fingerprint of p. A trailing partial word is padded with zero bytes, the length
+// tells such blocks apart from those holding the zeros.
+func NewFingerprint(p []byte) Fingerprint {
+	return Fingerprint{Checksum: Sum(p), Length: int64(len(p))}
+}
+
+// String returns the length and checksum, e.g. "4096:0a1b...".
+func (f Fingerprint) String() string {
+	return fmt.Sprintf("%v:%v", f.Length, f.Checksum)
+}
+
+// DedupIndex is the weak filter stage of a dedup pipeline. It maps fingerprints to the blocks already stored,
+// e.g. their addresses, and confirms candidates with a strong check before reporting a duplicate.
+type DedupIndex[V any] struct {
+	blocks  map[Fingerprint][]V
+	confirm func(stored V, data []byte) bool
+}
+
+// NewDedupIndex returns an empty index. confirm is called for every stored block whose fingerprint matches new
+// data, and reports whether it is really identical, e.g. by comparing SHA-256 hashes or the bytes read back.
+// If confirm is nil, matching fingerprints are trusted, only safe where no one can choose the data.
+func NewDedupIndex[V any](confirm func(stored V, data []byte) bool) *DedupIndex[V] {
+	return &DedupIndex[V]{blocks: make(map[Fingerprint][]V), confirm: confirm}
+}
+
+// Lookup returns the stored block holding the same data, if any, and the fingerprint of data for adding it.
+func (x *DedupIndex[V]) Lookup(data []byte) (stored V, fp Fingerprint, found bool) {
+	fp = NewFingerprint(data)
+	for _, v := range x.blocks[fp] {
+		if x.confirm == nil || x.confirm(v, data) {
+			return v, fp, true
+		}
+	}
+	return stored, fp, false
+}
+
+// Add records that the block with fingerprint fp is stored as v. Blocks with the same fingerprint but
+// different data, as the confirm function tells, are all kept.
+func (x *DedupIndex[V]) Add(fp Fingerprint, v V) {
+	x.blocks[fp] = append(x.blocks[fp], v)
+}
+
+// Len returns the number of blocks in the index.
+func (x *DedupIndex[V]) Len() int {
+	n := 0
+	for _, vs := range x.blocks {
+		n += len(vs)
+	}
+	return n
+}
+
+// WouldRewrite reports whether data must be written over a block whose checksum is old, i.e. whether the checksum
+// of data differs. Like the nopwrite optimization of ZFS, a storage layer can skip writes of blocks identical to
+// the ones already in place, saving the write and keeping snapshots sharing the block:
+//
+//	if !fletcher4.WouldRewrite(stored.Checksum, data) {
+//		return nil // already on disk
+//	}
+//
+// The old block must have the same length as data, compare them first. ZFS only does nopwrite with
+// cryptographic checksums, as blocks with the same fletcher4 checksum can be constructed. Where data may come
+// from untrusted sources, use WouldRewriteConfirmed.
+func WouldRewrite(old Checksum, data []byte) bool {
+	return NewFingerprint(data).Checksum != old
+}
+
+// WouldRewriteConfirmed is like WouldRewrite, but if the checksums match, confirm is called to make sure the old
+// block really holds data, e.g. by comparing SHA-256 hashes or reading the block back. The write is only
+// skipped if confirm returns true.
+func WouldRewriteConfirmed(old Checksum, data []byte, confirm func() bool) bool {
+	return WouldRewrite(old, data) || !confirm()
+}
@@ -0,0 +1,89 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// Test that fingerprints tell apart blocks differing in their zero padding
+func TestFingerprint(t *testing.T) {
+	a := NewFingerprint([]byte{1, 2, 3})
+	b := NewFingerprint([]byte{1, 2, 3, 0})
+	if a.Checksum != b.Checksum || a == b {
+		t.Errorf("Fingerprints %v and %v", a, b)
+	}
+	if a != NewFingerprint([]byte{1, 2, 3}) {
+		t.Error("Fingerprints of equal data differ")
+	}
+}
+
+// Test that the index finds confirmed duplicates and keeps colliding blocks apart
+func TestDedupIndex(t *testing.T) {
+	stored := map[int][]byte{}
+	x := NewDedupIndex(func(id int, data []byte) bool {
+		return sha256.Sum256(stored[id]) == sha256.Sum256(data)
+	})
+	blocks := [][]byte{randomBytes(4096), randomBytes(100), bytes.Repeat([]byte{100, 0, 0, 0}, 16)}
+	for i, block := range blocks {
+		if _, fp, found := x.Lookup(block); !found {
+			stored[i] = block
+			x.Add(fp, i)
+		}
+	}
+	if x.Len() != 3 {
+		t.Fatalf("Index holds %v blocks, expected 3", x.Len())
+	}
+	if id, _, found := x.Lookup(bytes.Clone(blocks[1])); !found || id != 1 {
+		t.Errorf("Lookup of duplicate returned %v, %v", id, found)
+	}
+
+	// Adding 1, -4, 6, -4, 1 to consecutive words keeps all four sums, as their weights are polynomials of
+	// degree three at most in the word position. The colliding block is rejected by the confirmation.
+	collide := bytes.Clone(blocks[2])
+	for i, delta := range []int{1, -4, 6, -4, 1} {
+		collide[4*i] = byte(int(collide[4*i]) + delta)
+	}
+	if NewFingerprint(collide) != NewFingerprint(blocks[2]) {
+		t.Fatal("Constructed block does not collide")
+	}
+	if _, _, found := x.Lookup(collide); found {
+		t.Error("Colliding block found as duplicate")
+	}
+}
+
+// Test that only changed blocks are rewritten, and that a rejected confirmation forces the write
+func TestWouldRewrite(t *testing.T) {
+	block := randomBytes(4096)
+	old := Sum(block)
+	if WouldRewrite(old, block) {
+		t.Error("Unchanged block would be rewritten")
+	}
+	changed := bytes.Clone(block)
+	changed[0] ^= 1
+	if !WouldRewrite(old, changed) {
+		t.Error("Changed block would not be rewritten")
+	}
+
+	confirmed := false
+	if WouldRewriteConfirmed(old, block, func() bool { confirmed = true; return true }) || !confirmed {
+		t.Errorf("Confirmed unchanged block would be rewritten, confirmation called: %v", confirmed)
+	}
+	if !WouldRewriteConfirmed(old, block, func() bool { return false }) {
+		t.Error("Block with rejected confirmation would not be rewritten")
+	}
+}
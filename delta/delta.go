@@ -0,0 +1,274 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Op is one instruction of a delta. It copies the Length bytes at Offset of the basis, or writes Data, of Length
+// bytes, if it is not nil.
+type Op struct {
+	Offset int64
+	Length int64
+	Data   []byte
+}
+
+// Delta turns a basis into a new file.
+type Delta struct {
+	Ops []Op
+	// Length and checksum of the new file
+	Length   int64
+	Checksum fletcher4.Checksum
+}
+
+// Data between blocks found is cut in operations of at most this many bytes, bounding the memory Diff buffers
+const maxLiteral = 64 << 10
+
+// Diff reads the new file from r until EOF, and returns the delta turning the basis of sig into it. Blocks of
+// the signature are found at any offset, and consecutive blocks copied by one operation. strong must be the hash
+// the signature was made with, or nil if it was made without.
+//
+// Memory used is bounded by the signature and the block size, not the size of the new file, apart from the
+// delta itself.
+func Diff(sig *Signature, r io.Reader, strong StrongHash) (*Delta, error) {
+	if err := checkBlockSize(sig.BlockSize); err != nil {
+		return nil, err
+	}
+	if err := sig.checkStrong(strong); err != nil {
+		return nil, err
+	}
+	size := sig.BlockSize
+	d := &differ{
+		sig:    sig,
+		strong: strong,
+		blocks: make(map[fletcher4.Checksum][]int),
+		short:  -1,
+		r:      fletcher4.NewHashingReader(r),
+		buf:    make([]byte, 0, maxLiteral+size+fletcher4.BlockSize),
+		delta:  &Delta{},
+	}
+	for i, b := range sig.Blocks {
+		if b.Length == size {
+			d.blocks[b.Checksum] = append(d.blocks[b.Checksum], i)
+		} else {
+			d.short = i
+		}
+	}
+	// A word followed by size bytes contributes a multiple of itself to each sum, the checksum of the word 1
+	// followed by as many zero bytes
+	d.weights = fletcher4.Combine(fletcher4.Checksum{1, 1, 1, 1}, fletcher4.Checksum{}, int64(size))
+
+	// Start of the data not part of an operation yet, and of the window looked up, in buf
+	lit, p := 0, 0
+	for {
+		if len(d.buf)-p < size && !d.eof {
+			// Keep the pending data, and the word before the window, which rolling it on removes
+			keep := min(lit, max(p-fletcher4.BlockSize, 0))
+			d.buf = d.buf[:copy(d.buf, d.buf[keep:])]
+			d.base += int64(keep)
+			lit -= keep
+			p -= keep
+			if err := d.fill(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(d.buf)-p < size {
+			break
+		}
+		if p-lit >= maxLiteral {
+			d.literal(d.buf[lit:p])
+			lit = p
+		}
+		if i, ok := d.match(d.roll(p), d.buf[p:p+size]); ok {
+			d.literal(d.buf[lit:p])
+			d.copy(i)
+			p += size
+			lit = p
+			continue
+		}
+		p++
+	}
+
+	// A shorter last block of the basis can only be found at the end
+	end := len(d.buf)
+	if d.short >= 0 {
+		b := d.sig.Blocks[d.short]
+		if start := end - b.Length; start >= lit {
+			tail := d.buf[start:end]
+			if fletcher4.Sum(tail) == b.Checksum && (strong == nil || bytes.Equal(strong(tail), b.Strong)) {
+				d.literal(d.buf[lit:start])
+				d.copy(d.short)
+				lit = end
+			}
+		}
+	}
+	d.literal(d.buf[lit:end])
+	d.delta.Length = d.r.Count()
+	d.delta.Checksum = d.r.Checksum()
+	return d.delta, nil
+}
+
+// window is the checksum rolled over the new file at offsets of one phase, modulo the word size.
+type window struct {
+	start int64
+	sum   fletcher4.Checksum
+	ok    bool
+}
+
+// differ holds the state of Diff.
+type differ struct {
+	sig    *Signature
+	strong StrongHash
+	// Blocks of the full block size by checksum, and the index of a shorter last block or -1
+	blocks map[fletcher4.Checksum][]int
+	short  int
+	r      *fletcher4.HashingReader
+	eof    bool
+	// Data read from r, starting at offset base of the new file
+	buf     []byte
+	base    int64
+	windows [fletcher4.BlockSize]window
+	// Weights of the word rolled out of a window in the sums
+	weights fletcher4.Checksum
+	delta   *Delta
+}
+
+// fill reads from r until buf is full or EOF.
+func (d *differ) fill() error {
+	for len(d.buf) < cap(d.buf) {
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err == io.EOF {
+			d.eof = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+	}
+	return nil
+}
+
+// roll returns the checksum of the block at p in buf. Moving the window of a phase on by one word only adds the
+// word entering it and removes the word leaving it, the checksum is computed from scratch otherwise.
+func (d *differ) roll(p int) fletcher4.Checksum {
+	size := d.sig.BlockSize
+	abs := d.base + int64(p)
+	w := &d.windows[abs%fletcher4.BlockSize]
+	switch {
+	case w.ok && w.start == abs:
+	case w.ok && w.start == abs-fletcher4.BlockSize:
+		in := uint64(binary.LittleEndian.Uint32(d.buf[p+size-fletcher4.BlockSize:]))
+		out := uint64(binary.LittleEndian.Uint32(d.buf[p-fletcher4.BlockSize:]))
+		s := &w.sum
+		s[0] += in
+		s[1] += s[0]
+		s[2] += s[1]
+		s[3] += s[2]
+		for i := range s {
+			s[i] -= d.weights[i] * out
+		}
+	default:
+		w.sum = fletcher4.Sum(d.buf[p : p+size])
+	}
+	w.start, w.ok = abs, true
+	return w.sum
+}
+
+// match returns the block of the signature holding data, whose checksum is sum. The block following the last
+// one copied is preferred, so runs of equal blocks are copied by one operation.
+func (d *differ) match(sum fletcher4.Checksum, data []byte) (int, bool) {
+	candidates := d.blocks[sum]
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	next := int64(-1)
+	if n := len(d.delta.Ops); n > 0 && d.delta.Ops[n-1].Data == nil {
+		next = d.delta.Ops[n-1].Offset + d.delta.Ops[n-1].Length
+	}
+	var digest []byte
+	if d.strong != nil {
+		digest = d.strong(data)
+	}
+	found := -1
+	for _, i := range candidates {
+		if d.strong != nil && !bytes.Equal(digest, d.sig.Blocks[i].Strong) {
+			continue
+		}
+		if d.sig.Blocks[i].Offset == next {
+			return i, true
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// copy adds copying block i of the basis to the delta, extending the last operation if it copies the blocks
+// before.
+func (d *differ) copy(i int) {
+	b := d.sig.Blocks[i]
+	if n := len(d.delta.Ops); n > 0 {
+		if last := &d.delta.Ops[n-1]; last.Data == nil && last.Offset+last.Length == b.Offset {
+			last.Length += int64(b.Length)
+			return
+		}
+	}
+	d.delta.Ops = append(d.delta.Ops, Op{Offset: b.Offset, Length: int64(b.Length)})
+}
+
+// literal adds writing a copy of data to the delta, unless it is empty.
+func (d *differ) literal(data []byte) {
+	if len(data) > 0 {
+		d.delta.Ops = append(d.delta.Ops, Op{Length: int64(len(data)), Data: bytes.Clone(data)})
+	}
+}
+
+// Apply writes the new file of the delta to w, copying blocks from basis, and verifies what it wrote against the
+// length and checksum of the delta. A basis other than the one the signature was made of, or a block wrongly
+// matched by Diff, gives an error matching fletcher4.ErrChecksumMismatch, after the data has been written.
+func Apply(w io.Writer, basis io.ReaderAt, delta *Delta) error {
+	hw := fletcher4.NewHashingWriter(w)
+	for _, op := range delta.Ops {
+		if op.Data != nil {
+			if _, err := hw.Write(op.Data); err != nil {
+				return fmt.Errorf("delta: %w", err)
+			}
+			continue
+		}
+		n, err := io.Copy(hw, io.NewSectionReader(basis, op.Offset, op.Length))
+		if err == nil && n < op.Length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("delta: copying %v bytes at %v of the basis: %w", op.Length, op.Offset, err)
+		}
+	}
+	if hw.Count() != delta.Length {
+		return fmt.Errorf("delta: new file has %v bytes, expected %v: %w", hw.Count(), delta.Length, fletcher4.ErrChecksumMismatch)
+	}
+	if sum := hw.Checksum(); sum != delta.Checksum {
+		return fmt.Errorf("delta: %w", &fletcher4.MismatchError{Expected: delta.Checksum, Actual: sum})
+	}
+	return nil
+}
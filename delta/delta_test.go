@@ -0,0 +1,133 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// diff returns the delta of the new file against the basis, and checks that applying it gives the new file.
+func diff(t *testing.T, basis, file []byte, blockSize int, strong StrongHash) *Delta {
+	t.Helper()
+	sig, err := NewSignature(bytes.NewReader(basis), blockSize, strong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := Diff(sig, iotest.HalfReader(bytes.NewReader(file)), strong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Apply(&out, bytes.NewReader(basis), delta); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), file) {
+		t.Fatalf("Applying the delta gave %v bytes differing from the %v of the new file", out.Len(), len(file))
+	}
+	return delta
+}
+
+// literals returns the number of bytes the delta holds as data.
+func literals(delta *Delta) int {
+	n := 0
+	for _, op := range delta.Ops {
+		n += len(op.Data)
+	}
+	return n
+}
+
+// Test that blocks are found at any byte offset, so only changed data is sent
+func TestDiff(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	basis := make([]byte, 300<<10+13)
+	rnd.Read(basis)
+	for _, strong := range []StrongHash{nil, sha256Hash} {
+		// Unchanged, all in one copy of the basis
+		if delta := diff(t, basis, basis, 1024, strong); len(delta.Ops) != 1 || delta.Ops[0].Data != nil || delta.Ops[0].Length != int64(len(basis)) {
+			t.Errorf("Delta of the basis itself has operations %v", len(delta.Ops))
+		}
+		// Bytes inserted and removed at offsets of every phase, and data appended
+		var file []byte
+		file = append(file, basis[:1001]...)
+		file = append(file, "inserted"...)
+		file = append(file, basis[1001:50002]...)
+		file = append(file, basis[50005:200003]...)
+		file = append(file, "x"...)
+		file = append(file, basis[200003:]...)
+		file = append(file, "appended"...)
+		if delta := diff(t, basis, file, 1024, strong); literals(delta) > 5*1024 {
+			t.Errorf("Delta of a few edits holds %v bytes of data", literals(delta))
+		}
+		// Nothing in common, cut in operations of bounded size
+		other := make([]byte, 200<<10)
+		rnd.Read(other)
+		if delta := diff(t, basis, other, 1024, strong); literals(delta) != len(other) || len(delta.Ops) < len(other)/maxLiteral {
+			t.Errorf("Delta of another file holds %v bytes in %v operations", literals(delta), len(delta.Ops))
+		}
+	}
+	// Runs of equal blocks are copied by one operation
+	zeros := make([]byte, 64<<10)
+	if delta := diff(t, zeros, zeros[:40<<10], 512, nil); len(delta.Ops) != 1 {
+		t.Errorf("Delta of zeros has %v operations", len(delta.Ops))
+	}
+	diff(t, nil, []byte("new"), 8, nil)
+	diff(t, []byte("old"), nil, 8, nil)
+}
+
+// collide returns a copy of p with the checksum unchanged but different data: changing five consecutive words
+// by 1, -4, 6, -4 and 1, the fourth difference, cancels out in all four sums, weighted by polynomials of
+// degree three at most.
+func collide(p []byte) []byte {
+	q := bytes.Clone(p)
+	for i, delta := range []int8{1, -4, 6, -4, 1} {
+		q[4*i] = byte(int8(q[4*i]) + delta)
+	}
+	return q
+}
+
+// Test that only a strong hash tells blocks with colliding checksums apart, and that Apply catches those it lets
+// through
+func TestDiffCollision(t *testing.T) {
+	basis := bytes.Repeat([]byte{100}, 64)
+	file := collide(basis)
+	if fletcher4.NewFingerprint(file) != fletcher4.NewFingerprint(basis) {
+		t.Fatal("Blocks do not collide")
+	}
+	if delta := diff(t, basis, file, 64, sha256Hash); literals(delta) != len(file) {
+		t.Errorf("Delta confirmed by a strong hash holds %v bytes of data", literals(delta))
+	}
+	sig, _ := NewSignature(bytes.NewReader(basis), 64, nil)
+	delta, err := Diff(sig, bytes.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if literals(delta) != 0 || Apply(&out, bytes.NewReader(basis), delta) != nil {
+		t.Errorf("Unconfirmed block was not matched")
+	}
+	delta.Checksum[0]++
+	if err := Apply(&out, bytes.NewReader(basis), delta); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Apply of a delta with a wrong checksum returned %v", err)
+	}
+	if _, err := Diff(sig, bytes.NewReader(file), sha256Hash); err == nil {
+		t.Errorf("Diff accepted a strong hash for a signature without digests")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package delta computes rsync style deltas between files, using fletcher4 checksums of fixed size blocks.
+//
+// The side holding the old version of a file, the basis, makes a Signature of it with NewSignature: the
+// checksum of every block of the basis. The side holding the new version finds the blocks of the signature
+// anywhere in it with Diff, at any byte offset, and returns a Delta made of references to those blocks and the
+// data in between. Apply rebuilds the new version from the basis and the delta. For large files that changed
+// little, the signature and delta are a fraction of the size of the file.
+//
+// Blocks are found by rolling the checksum over the new file one byte at a time, which costs about as much as
+// hashing it once. Fletcher4 only tells blocks apart by chance though, and blocks made to collide are easy to
+// construct. Give a StrongHash, the same one to NewSignature and Diff, to confirm each block found with a
+// cryptographic digest before it is used. The checksum of the whole new file is part of the delta, so Apply
+// detects wrong data from a false match or a basis that is not the one the signature was made of.
+package delta // import go.solidsystem.no/fletcher4/delta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// StrongHash returns a collision resistant digest of p, confirming blocks whose fletcher4 checksums match, e.g.
+//
+//	func(p []byte) []byte { sum := sha256.Sum256(p); return sum[:] }
+type StrongHash func(p []byte) []byte
+
+// Block is the signature of one block of the basis.
+type Block struct {
+	Offset int64
+	// Length of the block, the block size of the signature but for a shorter last block
+	Length   int
+	Checksum fletcher4.Checksum
+	// Digest of the block by the strong hash the signature was made with, nil if it was made without
+	Strong []byte
+}
+
+// Signature lists the blocks of a basis.
+type Signature struct {
+	BlockSize int
+	Blocks    []Block
+}
+
+// NewSignature reads basis until EOF and returns the signature of its blocks of blockSize bytes, the last of
+// which may be shorter. blockSize must be a positive multiple of fletcher4.BlockSize, the checksum rolls a word
+// at a time. If strong is not nil, the strong digest of every block is included.
+func NewSignature(basis io.Reader, blockSize int, strong StrongHash) (*Signature, error) {
+	if err := checkBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	var off int64
+	for {
+		n, err := io.ReadFull(basis, buf)
+		if n > 0 {
+			b := Block{Offset: off, Length: n, Checksum: fletcher4.Sum(buf[:n])}
+			if strong != nil {
+				b.Strong = strong(buf[:n])
+			}
+			sig.Blocks = append(sig.Blocks, b)
+			off += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("delta: %w", err)
+		}
+	}
+}
+
+// Length returns the length of the basis the signature was made of.
+func (s *Signature) Length() int64 {
+	if len(s.Blocks) == 0 {
+		return 0
+	}
+	last := s.Blocks[len(s.Blocks)-1]
+	return last.Offset + int64(last.Length)
+}
+
+func checkBlockSize(n int) error {
+	if n <= 0 || n%fletcher4.BlockSize != 0 {
+		return fmt.Errorf("delta: block size %v is not a positive multiple of %v", n, fletcher4.BlockSize)
+	}
+	return nil
+}
+
+// checkStrong returns an error unless the blocks of the signature have strong digests exactly if strong is not nil.
+func (s *Signature) checkStrong(strong StrongHash) error {
+	for _, b := range s.Blocks {
+		if (b.Strong == nil) != (strong == nil) {
+			if strong == nil {
+				return errors.New("delta: signature has strong digests, but no strong hash was given")
+			}
+			return errors.New("delta: signature has no strong digests to confirm blocks with")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"testing/iotest"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+func sha256Hash(p []byte) []byte {
+	sum := sha256.Sum256(p)
+	return sum[:]
+}
+
+// Test that the basis is cut in blocks of the block size but for the last, each with its checksums
+func TestNewSignature(t *testing.T) {
+	basis := bytes.Repeat([]byte("0123456789"), 10)
+	sig, err := NewSignature(iotest.OneByteReader(bytes.NewReader(basis)), 32, sha256Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.BlockSize != 32 || len(sig.Blocks) != 4 || sig.Length() != 100 {
+		t.Fatalf("Signature has block size %v, %v blocks of %v bytes", sig.BlockSize, len(sig.Blocks), sig.Length())
+	}
+	for i, b := range sig.Blocks {
+		data := basis[b.Offset : b.Offset+int64(b.Length)]
+		if b.Offset != int64(32*i) || b.Checksum != fletcher4.Sum(data) || !bytes.Equal(b.Strong, sha256Hash(data)) {
+			t.Errorf("Block %v is %+v", i, b)
+		}
+	}
+	if sig.Blocks[3].Length != 4 {
+		t.Errorf("Last block has %v bytes, expected 4", sig.Blocks[3].Length)
+	}
+
+	if sig, err := NewSignature(bytes.NewReader(nil), 32, nil); err != nil || len(sig.Blocks) != 0 || sig.Length() != 0 {
+		t.Errorf("Signature of an empty basis is %+v, %v", sig, err)
+	}
+	for _, size := range []int{0, -4, 30} {
+		if _, err := NewSignature(bytes.NewReader(basis), size, nil); err == nil {
+			t.Errorf("Block size %v was accepted", size)
+		}
+	}
+}
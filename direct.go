@@ -0,0 +1,80 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"errors"
+	"io"
+	"unsafe"
+)
+
+// ErrDirectIOUnsupported is returned by SumFile with WithDirectIO on platforms without direct I/O.
+var ErrDirectIOUnsupported = errors.New("fletcher4: direct I/O not supported on this platform")
+
+// Alignment of buffers, offsets and lengths for direct I/O, the page size of common systems and a multiple of the
+// logical block size of all common devices
+const directAlignment = 4096
+
+// WithDirectIO makes SumFile bypass the page cache, reading with O_DIRECT on Linux and F_NOCACHE on macOS.
+// Meant for verifying whole disks or large files on production hosts without evicting their working set.
+// The chunk size is rounded up to a multiple of 4096 bytes. Files on file systems refusing direct I/O, e.g.
+// tmpfs, fail to open.
+func WithDirectIO() Option {
+	return func(o *options) {
+		o.direct = true
+	}
+}
+
+// sumDirect returns the checksum and size of the file at path, read with direct I/O.
+func sumDirect(path string, o options) (Checksum, int64, error) {
+	f, err := openDirect(path)
+	if err != nil {
+		return Checksum{}, 0, err
+	}
+	defer f.Close()
+	total := int64(-1)
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		total = fi.Size()
+	}
+	m := o.newMeter(total)
+	defer m.finish()
+
+	size := (o.chunkSize + directAlignment - 1) &^ (directAlignment - 1)
+	buf := alignedBuffer(size, directAlignment)
+	var s stream
+	for {
+		// Each Read is a single read call. A short unaligned read only happens at the end of the file, reading
+		// again from the unaligned offset would be refused.
+		n, err := f.Read(buf)
+		s.write(buf[:n])
+		if werr := m.read(context.Background(), n); werr != nil && err == nil {
+			err = werr
+		}
+		if err == io.EOF || err == nil && n%directAlignment != 0 {
+			return s.checksum(), s.n, nil
+		}
+		if err != nil {
+			return Checksum{}, s.n, err
+		}
+	}
+}
+
+// alignedBuffer returns a buffer of size bytes starting at a multiple of align, which must be a power of two.
+func alignedBuffer(size, align int) []byte {
+	buf := make([]byte, size+align)
+	skip := int(-uintptr(unsafe.Pointer(unsafe.SliceData(buf))) & uintptr(align-1))
+	return buf[skip : skip+size : skip+size]
+}
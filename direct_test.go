@@ -0,0 +1,55 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+// Test SumFile with direct I/O on files of aligned and unaligned sizes
+func TestSumFileDirect(t *testing.T) {
+	for _, size := range []int{0, 13, directAlignment, 3*directAlignment + 5, mmapThreshold + 5} {
+		data := randomBytes(size)
+		sum, n, err := SumFile(writeTestFile(t, data), WithDirectIO(), WithChunkSize(5000))
+		if errors.Is(err, ErrDirectIOUnsupported) {
+			t.Skip(err)
+		}
+		if err != nil {
+			// Some file systems, e.g. tmpfs, refuse to open files for direct I/O
+			t.Skipf("Direct I/O not supported by the temporary directory: %v", err)
+		}
+		if n != int64(size) {
+			t.Errorf("SumFile of %v bytes returned size %v", size, n)
+		}
+		if exp := paddedChecksum(data); sum != exp {
+			t.Errorf("SumFile of %v bytes:\nexpected\t%x,\ngot\t\t%x", size, exp, sum)
+		}
+	}
+}
+
+// Test that aligned buffers start at the alignment and have the requested size
+func TestAlignedBuffer(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		buf := alignedBuffer(8192+i, directAlignment)
+		if len(buf) != 8192+i || cap(buf) != len(buf) {
+			t.Errorf("Aligned buffer of %v bytes has length %v and capacity %v", 8192+i, len(buf), cap(buf))
+		}
+		if addr := uintptr(unsafe.Pointer(&buf[0])); addr%directAlignment != 0 {
+			t.Errorf("Aligned buffer starts at %#x", addr)
+		}
+	}
+}
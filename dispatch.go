@@ -0,0 +1,168 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fastest can be given to SetImplementation to select the kernel measured to be fastest on this machine.
+const Fastest = "fastest"
+
+// EnvImplementation is the environment variable which may hold the name of the kernel to use instead of the
+// default one, e.g. FLETCHER4_IMPL=scalar. It is read at startup and accepts the same names as SetImplementation.
+// Unknown or unsupported names are ignored, use Implementation to confirm the kernel in use.
+const EnvImplementation = "FLETCHER4_IMPL"
+
+// implementation is one kernel able to compute the fletcher4 checksum.
+type implementation struct {
+	// Name reported by Implementation
+	name string
+	// Whether the running cpu supports the kernel
+	available bool
+	// Adds p to the running checksum. Length of p is always a multiple of BlockSize.
+	update func(dig digest, p []byte) digest
+	// Number of buffers multi hashes at once, one per lane, zero if the kernel has no multi buffer variant
+	multiLanes int
+	// Appends the checksums of multiLanes buffers of the same length, a multiple of BlockSize, to dst
+	multi func(dst []Checksum, bufs [][]byte) []Checksum
+}
+
+var scalarImplementation = &implementation{name: "scalar", available: true, update: updateScalar}
+
+// Implementations written in Go, available everywhere. The unrolled scalar kernel measures faster than
+// superscalar4 on common amd64 cpus, as the 16 lane sums do not fit in registers, so it is preferred. Fastest
+// picks superscalar4 where it wins.
+var portableImplementations = []*implementation{
+	{name: "superscalar4", available: true, update: updateSuperscalar4},
+	scalarImplementation,
+}
+
+// All known implementations, in increasing order of preference. The arch specific ones are found in the
+// dispatch_<arch>.go files.
+var implementations = append(portableImplementations, archImplementations...)
+
+// The implementation used by all checksummers. Atomic since it may be changed with SetImplementation while
+// checksummers are in use.
+var active atomic.Pointer[implementation]
+
+func init() {
+	selectDefault(os.Getenv(EnvImplementation))
+}
+
+// selectDefault selects the implementation named by the environment override if set and valid, the most
+// preferred available one otherwise.
+func selectDefault(override string) {
+	if override != "" && SetImplementation(override) == nil {
+		return
+	}
+	active.Store(best())
+}
+
+// best returns the most preferred implementation available on this cpu.
+func best() *implementation {
+	for i := len(implementations) - 1; i >= 0; i-- {
+		if implementations[i].available {
+			return implementations[i]
+		}
+	}
+	return scalarImplementation
+}
+
+// Implementation returns the name of the kernel currently used to compute checksums, e.g. "scalar" or "avx2".
+func Implementation() string {
+	return active.Load().name
+}
+
+// Implementations returns the names of all kernels available on this cpu, in increasing order of preference.
+func Implementations() []string {
+	var names []string
+	for _, impl := range implementations {
+		if impl.available {
+			names = append(names, impl.name)
+		}
+	}
+	return names
+}
+
+// SetImplementation selects the kernel used by all checksummers, mirroring the fletcher_4 module parameter of
+// OpenZFS. Useful to pin a specific kernel when benchmarking or debugging, e.g. "scalar".
+// An error is returned if the name is unknown or the kernel is not supported by this cpu.
+//
+// If name is Fastest, all available kernels are timed the first time it is requested and the fastest one is
+// selected. The theoretically best instruction set is not always the fastest, e.g. due to AVX-512 downclocking.
+func SetImplementation(name string) error {
+	if name == Fastest {
+		fastestOnce.Do(func() { fastest = benchmark() })
+		active.Store(fastest)
+		return nil
+	}
+	for _, impl := range implementations {
+		if impl.name == name {
+			if !impl.available {
+				return fmt.Errorf("fletcher4: implementation %q is not supported by this cpu", name)
+			}
+			active.Store(impl)
+			return nil
+		}
+	}
+	return fmt.Errorf("fletcher4: unknown implementation %q", name)
+}
+
+// Result of the timing pass done for Fastest
+var (
+	fastestOnce sync.Once
+	fastest     *implementation
+)
+
+// Size of the buffer and the minimum time each kernel is given in the timing pass
+const (
+	benchmarkSize     = 16 << 10
+	benchmarkDuration = time.Millisecond
+)
+
+// benchmark times all available implementations and returns the one with the highest throughput.
+func benchmark() *implementation {
+	buf := make([]byte, benchmarkSize)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	best := scalarImplementation
+	bestRate := 0.0
+	for _, impl := range implementations {
+		if !impl.available {
+			continue
+		}
+		var dig digest
+		bytes := 0
+		start := time.Now()
+		elapsed := time.Duration(0)
+		for elapsed < benchmarkDuration {
+			dig = impl.update(dig, buf)
+			bytes += len(buf)
+			elapsed = time.Since(start)
+		}
+		if rate := float64(bytes) / float64(elapsed); rate > bestRate {
+			best = impl
+			bestRate = rate
+		}
+	}
+	return best
+}
@@ -0,0 +1,81 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build amd64 && !purego
+
+package fletcher4
+
+import "golang.org/x/sys/cpu"
+
+// The lane kernels in fletcher4_amd64.s are generated with avo by the asm module.
+//go:generate go run -C asm . -out ../fletcher4_amd64.s -stubs ../stub_amd64.go -pkg fletcher4
+
+var archImplementations = []*implementation{
+	{name: "sse2", available: cpu.X86.HasSSE2, update: updateSSE2},
+	{name: "avx2", available: cpu.X86.HasAVX2, update: updateAVX2, multiLanes: 4, multi: multiAVX2},
+	{name: "avx512f", available: cpu.X86.HasAVX512F, update: updateAVX512F, multiLanes: 8, multi: multiAVX512F},
+}
+
+func updateSSE2(dig digest, p []byte) digest {
+	n := len(p) &^ (2*BlockSize - 1)
+	if n > 0 {
+		var lanes [4][2]uint64
+		fletcherSSE2(&lanes, p[:n])
+		dig = combine(dig, uint64(n), finiLanes(lanes[0][:], lanes[1][:], lanes[2][:], lanes[3][:]))
+	}
+	return updateScalar(dig, p[n:])
+}
+
+func updateAVX2(dig digest, p []byte) digest {
+	n := len(p) &^ (4*BlockSize - 1)
+	if n > 0 {
+		var lanes [4][4]uint64
+		fletcherAVX2(&lanes, p[:n])
+		dig = combine(dig, uint64(n), finiLanes(lanes[0][:], lanes[1][:], lanes[2][:], lanes[3][:]))
+	}
+	return updateScalar(dig, p[n:])
+}
+
+func updateAVX512F(dig digest, p []byte) digest {
+	n := len(p) &^ (8*BlockSize - 1)
+	if n > 0 {
+		var lanes [4][8]uint64
+		fletcherAVX512F(&lanes, p[:n])
+		dig = combine(dig, uint64(n), finiLanes(lanes[0][:], lanes[1][:], lanes[2][:], lanes[3][:]))
+	}
+	return updateScalar(dig, p[n:])
+}
+
+func multiAVX2(dst []Checksum, bufs [][]byte) []Checksum {
+	n := len(bufs[0]) &^ (4*BlockSize - 1)
+	var sums [4][4]uint64
+	fletcherMultiAVX2(&sums, (*[4][]byte)(bufs), n)
+	return appendMulti(dst, bufs, n, sums[0][:], sums[1][:], sums[2][:], sums[3][:])
+}
+
+func multiAVX512F(dst []Checksum, bufs [][]byte) []Checksum {
+	n := len(bufs[0]) &^ (4*BlockSize - 1)
+	var sums [4][8]uint64
+	fletcherMultiAVX512F(&sums, (*[8][]byte)(bufs), n)
+	return appendMulti(dst, bufs, n, sums[0][:], sums[1][:], sums[2][:], sums[3][:])
+}
+
+// appendMulti appends the checksums of bufs to dst, given the sums of their first n bytes computed by a multi
+// buffer kernel, one buffer per lane. The last words after those are added by the scalar kernel.
+func appendMulti(dst []Checksum, bufs [][]byte, n int, a, b, c, d []uint64) []Checksum {
+	for l, p := range bufs {
+		dst = append(dst, Checksum(updateScalar(digest{a[l], b[l], c[l], d[l]}, p[n:])))
+	}
+	return dst
+}
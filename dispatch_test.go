@@ -0,0 +1,129 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomBytes(n int) []byte {
+	p := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(p)
+	return p
+}
+
+// Test that every implementation available on this cpu computes the same checksum as the scalar one, also when
+// continuing from a non zero running checksum.
+func TestImplementationsMatchScalar(t *testing.T) {
+	data := randomBytes(4096 + 3*BlockSize)
+	start := updateScalar(digest{}, data[:3*BlockSize])
+	for _, impl := range implementations {
+		if !impl.available {
+			continue
+		}
+		for n := 0; n <= len(data); n += BlockSize {
+			exp := updateScalar(start, data[:n])
+			got := impl.update(start, data[:n])
+			if got != exp {
+				t.Fatalf("Implementation %v, %v bytes:\nexpected\t%x,\ngot\t\t%x", impl.name, n, exp, got)
+			}
+		}
+	}
+}
+
+// Test that combining the checksums of two halves gives the checksum of the whole buffer
+func TestCombine(t *testing.T) {
+	data := randomBytes(1024)
+	exp := updateScalar(digest{}, data)
+	for split := 0; split <= len(data); split += BlockSize {
+		first := updateScalar(digest{}, data[:split])
+		second := updateScalar(digest{}, data[split:])
+		if got := combine(first, uint64(len(data)-split), second); got != exp {
+			t.Fatalf("Combine split at %v:\nexpected\t%x,\ngot\t\t%x", split, exp, got)
+		}
+	}
+}
+
+// Test that the selected implementation is one of the available ones
+func TestImplementation(t *testing.T) {
+	name := Implementation()
+	for _, impl := range implementations {
+		if impl.name == name {
+			if !impl.available {
+				t.Errorf("Selected implementation %v is not available", name)
+			}
+			return
+		}
+	}
+	t.Errorf("Selected implementation %v is unknown", name)
+}
+
+// Test that every listed implementation can be selected and is then used by the checksummer
+func TestSetImplementation(t *testing.T) {
+	defer SetImplementation(Implementation())
+
+	data := randomBytes(1024)
+	exp := updateScalar(digest{}, data)
+	for _, name := range Implementations() {
+		if err := SetImplementation(name); err != nil {
+			t.Fatal(err)
+		}
+		if got := Implementation(); got != name {
+			t.Errorf("Selected implementation %v, but %v is in use", name, got)
+		}
+		checksummer := New()
+		if _, err := checksummer.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if got := checksummer.Sum64x4(); got != exp {
+			t.Errorf("Implementation %v:\nexpected\t%x,\ngot\t\t%x", name, exp, got)
+		}
+	}
+
+	if err := SetImplementation("no-such-kernel"); err == nil {
+		t.Error("Selecting an unknown implementation did not fail")
+	}
+}
+
+// Test that selecting the fastest implementation picks one of the available ones
+func TestSetImplementationFastest(t *testing.T) {
+	defer SetImplementation(Implementation())
+
+	if err := SetImplementation(Fastest); err != nil {
+		t.Fatal(err)
+	}
+	name := Implementation()
+	for _, impl := range Implementations() {
+		if impl == name {
+			return
+		}
+	}
+	t.Errorf("Fastest implementation %v is not one of the available ones", name)
+}
+
+// Test that the environment override selects the named implementation, and that bad values are ignored
+func TestEnvironmentOverride(t *testing.T) {
+	defer SetImplementation(Implementation())
+
+	selectDefault("scalar")
+	if got := Implementation(); got != "scalar" {
+		t.Errorf("Override with scalar selected %v", got)
+	}
+	selectDefault("no-such-kernel")
+	if got, exp := Implementation(), best().name; got != exp {
+		t.Errorf("Override with unknown name selected %v, expected default %v", got, exp)
+	}
+}
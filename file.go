@@ -0,0 +1,144 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/debug"
+)
+
+// Regular files at least this large are memory mapped, smaller ones are cheaper to read.
+const mmapThreshold = 1 << 20
+
+// Returned by mapFile on platforms without memory mapping support
+var errNoMmap = errors.New("fletcher4: memory mapping not supported")
+
+// Returned by dataSegments where holes in files cannot be found
+var errNoSparse = errors.New("fletcher4: finding holes not supported")
+
+// segment is a region of a file holding data.
+type segment struct {
+	off, n int64
+}
+
+// SumFile returns the checksum and size of the file at path. Large regular files are memory mapped on Unix and
+// Windows, everything else is read through a buffer sized by the chunk size option. Holes in large sparse
+// files, e.g. VM images, are skipped where the file system reports them and accounted for without reading.
+// A trailing partial word is padded with zero bytes, like SumReader does.
+func SumFile(path string, opts ...Option) (Checksum, int64, error) {
+	o := newOptions(opts)
+	if o.direct {
+		return sumDirect(path, o)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Checksum{}, 0, err
+	}
+	defer f.Close()
+
+	return sumOpenFile(f, o)
+}
+
+// sumOpenFile returns the checksum and size of the open file f, memory mapping it if it is large and regular.
+func sumOpenFile(f *os.File, o options) (Checksum, int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return Checksum{}, 0, err
+	}
+	size := int64(-1)
+	if fi.Mode().IsRegular() {
+		size = fi.Size()
+	}
+	m := o.newMeter(size)
+	defer m.finish()
+	if size >= mmapThreshold {
+		// Files without holes are a single segment, and mapped below
+		if segs, err := dataSegments(f, size); err == nil && !(len(segs) == 1 && segs[0].n == size) {
+			sum, err := sumSparse(f, size, segs, &o, m)
+			return sum, size, err
+		}
+	}
+	if size >= mmapThreshold && size <= math.MaxInt {
+		if sum, err := sumMapped(f, int(size), &o, m); !errors.Is(err, errNoMmap) {
+			return sum, size, err
+		}
+	}
+
+	var s stream
+	n, err := s.readFrom(context.Background(), m.reader(context.Background(), f), make([]byte, o.chunkSizeFor(size)))
+	return s.checksum(), n, err
+}
+
+// sumSparse hashes the data segments of the first size bytes of f, and the holes between them as zeros.
+func sumSparse(f *os.File, size int64, segs []segment, o *options, m *meter) (Checksum, error) {
+	var s stream
+	buf := make([]byte, o.chunkSize)
+	for _, seg := range segs {
+		m.add(seg.off - s.n)
+		s.zeros(seg.off - s.n)
+		n, err := s.readFrom(context.Background(), m.reader(context.Background(), io.NewSectionReader(f, seg.off, seg.n)), buf)
+		if err != nil {
+			return Checksum{}, err
+		}
+		if n < seg.n {
+			return Checksum{}, io.ErrUnexpectedEOF
+		}
+	}
+	m.add(size - s.n)
+	s.zeros(size - s.n)
+	return s.checksum(), nil
+}
+
+// sumMapped memory maps the first size bytes of f and hashes them. Returns errNoMmap if the file could not be
+// mapped, the caller should read it instead.
+func sumMapped(f *os.File, size int, o *options, m *meter) (sum Checksum, err error) {
+	data, unmap, err := mapFile(f, size)
+	if err != nil {
+		return Checksum{}, errNoMmap
+	}
+	defer func() {
+		if uerr := unmap(); err == nil {
+			err = uerr
+		}
+	}()
+
+	// Accessing the mapping faults if the file is truncated while it is hashed. Turn that into an error rather
+	// than crashing the program.
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("fletcher4: reading memory mapped %v failed: %v", f.Name(), r)
+		}
+	}()
+
+	var s stream
+	for len(data) > 0 {
+		n := o.chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		s.write(data[:n])
+		if err := m.read(context.Background(), n); err != nil {
+			return Checksum{}, err
+		}
+		data = data[n:]
+	}
+	return s.checksum(), nil
+}
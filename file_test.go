@@ -0,0 +1,90 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFile writes data to a new file in a temporary directory and returns its path
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Test SumFile on a small file which is read, and a large file which is memory mapped where supported
+func TestSumFile(t *testing.T) {
+	for _, size := range []int{0, 13, mmapThreshold + 5} {
+		data := randomBytes(size)
+		sum, n, err := SumFile(writeTestFile(t, data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(size) {
+			t.Errorf("SumFile of %v bytes returned size %v", size, n)
+		}
+		if exp := paddedChecksum(data); sum != exp {
+			t.Errorf("SumFile of %v bytes:\nexpected\t%x,\ngot\t\t%x", size, exp, sum)
+		}
+	}
+}
+
+// Test SumFile on a sparse file, with holes at the start, in the middle and at the end
+func TestSumFileSparse(t *testing.T) {
+	const size = 8 << 20
+	data := make([]byte, size)
+	path := writeTestFile(t, nil)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int64{3 << 20, 5<<20 + 13} {
+		chunk := randomBytes(100 << 10)
+		copy(data[off:], chunk)
+		if _, err := f.WriteAt(chunk, off); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, n, err := SumFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size {
+		t.Errorf("SumFile of sparse file returned size %v, expected %v", n, size)
+	}
+	if exp := paddedChecksum(data); sum != exp {
+		t.Errorf("SumFile of sparse file:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+	}
+}
+
+// Test that SumFile fails for missing files
+func TestSumFileMissing(t *testing.T) {
+	if _, _, err := SumFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("SumFile of missing file returned error %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Code generated by command: go run main.go -out ../fletcher4_amd64.s -stubs ../stub_amd64.go -pkg fletcher4. DO NOT EDIT.
+
+//go:build !purego
+
+#include "textflag.h"
+
+// func fletcherSSE2(lanes *[4][2]uint64, p []byte)
+// Requires: SSE2
+TEXT ·fletcherSSE2(SB), NOSPLIT, $0-32
+	MOVQ  lanes+0(FP), AX
+	MOVQ  p_base+8(FP), CX
+	MOVQ  p_len+16(FP), DX
+	PXOR  X0, X0
+	PXOR  X1, X1
+	PXOR  X2, X2
+	PXOR  X3, X3
+	PXOR  X5, X5
+	TESTQ DX, DX
+	JZ    fletcherSSE2done
+
+fletcherSSE2loop:
+	MOVQ      (CX), X4
+	PUNPCKLLQ X5, X4
+	PADDQ     X4, X0
+	PADDQ     X0, X1
+	PADDQ     X1, X2
+	PADDQ     X2, X3
+	ADDQ      $0x00000008, CX
+	SUBQ      $0x00000008, DX
+	JNZ       fletcherSSE2loop
+
+fletcherSSE2done:
+	MOVOU X0, (AX)
+	MOVOU X1, 16(AX)
+	MOVOU X2, 32(AX)
+	MOVOU X3, 48(AX)
+	RET
+
+// func fletcherAVX2(lanes *[4][4]uint64, p []byte)
+// Requires: AVX, AVX2
+TEXT ·fletcherAVX2(SB), NOSPLIT, $0-32
+	MOVQ  lanes+0(FP), AX
+	MOVQ  p_base+8(FP), CX
+	MOVQ  p_len+16(FP), DX
+	VPXOR Y0, Y0, Y0
+	VPXOR Y1, Y1, Y1
+	VPXOR Y2, Y2, Y2
+	VPXOR Y3, Y3, Y3
+	VPXOR Y4, Y4, Y4
+	TESTQ DX, DX
+	JZ    fletcherAVX2done
+
+fletcherAVX2loop:
+	VPMOVZXDQ (CX), Y4
+	VPADDQ    Y4, Y0, Y0
+	VPADDQ    Y0, Y1, Y1
+	VPADDQ    Y1, Y2, Y2
+	VPADDQ    Y2, Y3, Y3
+	ADDQ      $0x00000010, CX
+	SUBQ      $0x00000010, DX
+	JNZ       fletcherAVX2loop
+
+fletcherAVX2done:
+	VMOVDQU Y0, (AX)
+	VMOVDQU Y1, 32(AX)
+	VMOVDQU Y2, 64(AX)
+	VMOVDQU Y3, 96(AX)
+	VZEROUPPER
+	RET
+
+// func fletcherAVX512F(lanes *[4][8]uint64, p []byte)
+// Requires: AVX, AVX512F
+TEXT ·fletcherAVX512F(SB), NOSPLIT, $0-32
+	MOVQ   lanes+0(FP), AX
+	MOVQ   p_base+8(FP), CX
+	MOVQ   p_len+16(FP), DX
+	VPXORQ Z0, Z0, Z0
+	VPXORQ Z1, Z1, Z1
+	VPXORQ Z2, Z2, Z2
+	VPXORQ Z3, Z3, Z3
+	VPXORQ Z4, Z4, Z4
+	TESTQ  DX, DX
+	JZ     fletcherAVX512Fdone
+
+fletcherAVX512Floop:
+	VPMOVZXDQ (CX), Z4
+	VPADDQ    Z4, Z0, Z0
+	VPADDQ    Z0, Z1, Z1
+	VPADDQ    Z1, Z2, Z2
+	VPADDQ    Z2, Z3, Z3
+	ADDQ      $0x00000020, CX
+	SUBQ      $0x00000020, DX
+	JNZ       fletcherAVX512Floop
+
+fletcherAVX512Fdone:
+	VMOVDQU64 Z0, (AX)
+	VMOVDQU64 Z1, 64(AX)
+	VMOVDQU64 Z2, 128(AX)
+	VMOVDQU64 Z3, 192(AX)
+	VZEROUPPER
+	RET
+
+// func fletcherMultiAVX2(sums *[4][4]uint64, bufs *[4][]byte, n int)
+// Requires: AVX, AVX2
+TEXT ·fletcherMultiAVX2(SB), NOSPLIT, $0-24
+	MOVQ  sums+0(FP), AX
+	MOVQ  bufs+8(FP), CX
+	MOVQ  n+16(FP), DX
+	MOVQ  (CX), BX
+	MOVQ  24(CX), SI
+	MOVQ  48(CX), DI
+	MOVQ  72(CX), CX
+	XORQ  R8, R8
+	VPXOR Y0, Y0, Y0
+	VPXOR Y1, Y1, Y1
+	VPXOR Y2, Y2, Y2
+	VPXOR Y3, Y3, Y3
+	TESTQ DX, DX
+	JZ    fletcherMultiAVX2done
+
+fletcherMultiAVX2loop:
+	VMOVDQU     (BX)(R8*1), X4
+	VMOVDQU     (SI)(R8*1), X5
+	VMOVDQU     (DI)(R8*1), X6
+	VMOVDQU     (CX)(R8*1), X7
+	VPUNPCKLDQ  X5, X4, X8
+	VPUNPCKHDQ  X5, X4, X9
+	VPUNPCKLDQ  X7, X6, X5
+	VPUNPCKHDQ  X7, X6, X7
+	VPUNPCKLQDQ X5, X8, X4
+	VPUNPCKHQDQ X5, X8, X5
+	VPUNPCKLQDQ X7, X9, X6
+	VPUNPCKHQDQ X7, X9, X7
+	VPMOVZXDQ   X4, Y4
+	VPADDQ      Y4, Y0, Y0
+	VPADDQ      Y0, Y1, Y1
+	VPADDQ      Y1, Y2, Y2
+	VPADDQ      Y2, Y3, Y3
+	VPMOVZXDQ   X5, Y4
+	VPADDQ      Y4, Y0, Y0
+	VPADDQ      Y0, Y1, Y1
+	VPADDQ      Y1, Y2, Y2
+	VPADDQ      Y2, Y3, Y3
+	VPMOVZXDQ   X6, Y4
+	VPADDQ      Y4, Y0, Y0
+	VPADDQ      Y0, Y1, Y1
+	VPADDQ      Y1, Y2, Y2
+	VPADDQ      Y2, Y3, Y3
+	VPMOVZXDQ   X7, Y4
+	VPADDQ      Y4, Y0, Y0
+	VPADDQ      Y0, Y1, Y1
+	VPADDQ      Y1, Y2, Y2
+	VPADDQ      Y2, Y3, Y3
+	ADDQ        $0x00000010, R8
+	SUBQ        $0x00000010, DX
+	JNZ         fletcherMultiAVX2loop
+
+fletcherMultiAVX2done:
+	VMOVDQU Y0, (AX)
+	VMOVDQU Y1, 32(AX)
+	VMOVDQU Y2, 64(AX)
+	VMOVDQU Y3, 96(AX)
+	VZEROUPPER
+	RET
+
+// func fletcherMultiAVX512F(sums *[4][8]uint64, bufs *[8][]byte, n int)
+// Requires: AVX, AVX2, AVX512F
+TEXT ·fletcherMultiAVX512F(SB), NOSPLIT, $0-24
+	MOVQ   sums+0(FP), AX
+	MOVQ   bufs+8(FP), CX
+	MOVQ   n+16(FP), DX
+	MOVQ   (CX), BX
+	MOVQ   24(CX), SI
+	MOVQ   48(CX), DI
+	MOVQ   72(CX), R8
+	MOVQ   96(CX), R9
+	MOVQ   120(CX), R10
+	MOVQ   144(CX), R11
+	MOVQ   168(CX), CX
+	XORQ   R12, R12
+	VPXORQ Z0, Z0, Z0
+	VPXORQ Z1, Z1, Z1
+	VPXORQ Z2, Z2, Z2
+	VPXORQ Z3, Z3, Z3
+	TESTQ  DX, DX
+	JZ     fletcherMultiAVX512Fdone
+
+fletcherMultiAVX512Floop:
+	VMOVDQU     (BX)(R12*1), X4
+	VMOVDQU     (SI)(R12*1), X5
+	VMOVDQU     (DI)(R12*1), X6
+	VMOVDQU     (R8)(R12*1), X7
+	VPUNPCKLDQ  X5, X4, X8
+	VPUNPCKHDQ  X5, X4, X9
+	VPUNPCKLDQ  X7, X6, X5
+	VPUNPCKHDQ  X7, X6, X7
+	VPUNPCKLQDQ X5, X8, X4
+	VPUNPCKHQDQ X5, X8, X5
+	VPUNPCKLQDQ X7, X9, X6
+	VPUNPCKHQDQ X7, X9, X7
+	VMOVDQU     (R9)(R12*1), X8
+	VMOVDQU     (R10)(R12*1), X9
+	VMOVDQU     (R11)(R12*1), X10
+	VMOVDQU     (CX)(R12*1), X11
+	VPUNPCKLDQ  X9, X8, X12
+	VPUNPCKHDQ  X9, X8, X13
+	VPUNPCKLDQ  X11, X10, X9
+	VPUNPCKHDQ  X11, X10, X11
+	VPUNPCKLQDQ X9, X12, X8
+	VPUNPCKHQDQ X9, X12, X9
+	VPUNPCKLQDQ X11, X13, X10
+	VPUNPCKHQDQ X11, X13, X11
+	VINSERTI128 $0x01, X8, Y4, Y8
+	VPMOVZXDQ   Y8, Z8
+	VPADDQ      Z8, Z0, Z0
+	VPADDQ      Z0, Z1, Z1
+	VPADDQ      Z1, Z2, Z2
+	VPADDQ      Z2, Z3, Z3
+	VINSERTI128 $0x01, X9, Y5, Y8
+	VPMOVZXDQ   Y8, Z8
+	VPADDQ      Z8, Z0, Z0
+	VPADDQ      Z0, Z1, Z1
+	VPADDQ      Z1, Z2, Z2
+	VPADDQ      Z2, Z3, Z3
+	VINSERTI128 $0x01, X10, Y6, Y8
+	VPMOVZXDQ   Y8, Z8
+	VPADDQ      Z8, Z0, Z0
+	VPADDQ      Z0, Z1, Z1
+	VPADDQ      Z1, Z2, Z2
+	VPADDQ      Z2, Z3, Z3
+	VINSERTI128 $0x01, X11, Y7, Y8
+	VPMOVZXDQ   Y8, Z8
+	VPADDQ      Z8, Z0, Z0
+	VPADDQ      Z0, Z1, Z1
+	VPADDQ      Z1, Z2, Z2
+	VPADDQ      Z2, Z3, Z3
+	ADDQ        $0x00000010, R12
+	SUBQ        $0x00000010, DX
+	JNZ         fletcherMultiAVX512Floop
+
+fletcherMultiAVX512Fdone:
+	VMOVDQU64 Z0, (AX)
+	VMOVDQU64 Z1, 64(AX)
+	VMOVDQU64 Z2, 128(AX)
+	VMOVDQU64 Z3, 192(AX)
+	VZEROUPPER
+	RET
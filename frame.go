@@ -0,0 +1,140 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A frame is a 4 byte little endian payload length, the payload, and a checksum trailer of Size bytes covering
+// the length and the payload, serialized like Sum does. In cumulative mode the checksum covers the lengths and
+// payloads of all frames up to and including this one.
+const frameHeaderSize = 4
+
+// DefaultMaxFrameSize is the largest payload a FrameReader accepts, unless set with WithMaxFrameSize.
+const DefaultMaxFrameSize = 16 << 20
+
+// WithMaxFrameSize sets the largest payload a FrameReader accepts, protecting against huge allocations caused
+// by a corrupt length. Zero or negative values select DefaultMaxFrameSize.
+func WithMaxFrameSize(n int) Option {
+	return func(o *options) {
+		o.maxFrameSize = n
+	}
+}
+
+// WithCumulative makes the checksum of each frame cover all bytes of the stream up to and including that frame,
+// as ZFS send streams do. A truncated or reordered stream is then detected at the first affected frame, not
+// only corrupt frames. The FrameWriter and FrameReader of a stream must agree on this option.
+func WithCumulative() Option {
+	return func(o *options) {
+		o.cumulative = true
+	}
+}
+
+// FrameWriter writes records as frames with a checksum each, read back by FrameReader.
+type FrameWriter struct {
+	w          io.Writer
+	header     [frameHeaderSize]byte
+	cumulative bool
+	// Running checksum of all frames in cumulative mode
+	s stream
+}
+
+// NewFrameWriter returns a FrameWriter writing frames to w.
+func NewFrameWriter(w io.Writer, opts ...Option) *FrameWriter {
+	o := newOptions(opts)
+	return &FrameWriter{w: w, cumulative: o.cumulative}
+}
+
+// WriteFrame writes p as one frame.
+func (f *FrameWriter) WriteFrame(p []byte) error {
+	if uint64(len(p)) > 1<<32-1 {
+		return fmt.Errorf("fletcher4: frame of %v bytes is too large", len(p))
+	}
+	binary.LittleEndian.PutUint32(f.header[:], uint32(len(p)))
+	if !f.cumulative {
+		f.s = stream{}
+	}
+	f.s.write(f.header[:])
+	f.s.write(p)
+	trailer, _ := f.s.checksum().MarshalBinary()
+
+	for _, b := range [][]byte{f.header[:], p, trailer} {
+		if _, err := f.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FrameReader reads the frames written by FrameWriter, verifying the checksum of each.
+type FrameReader struct {
+	r          io.Reader
+	max        int
+	header     [frameHeaderSize]byte
+	cumulative bool
+	s          stream
+	// Number of frames and bytes read, used in errors
+	frames int64
+	offset int64
+}
+
+// NewFrameReader returns a FrameReader reading frames from r.
+func NewFrameReader(r io.Reader, opts ...Option) *FrameReader {
+	o := newOptions(opts)
+	return &FrameReader{r: r, max: o.maxFrameSize, cumulative: o.cumulative}
+}
+
+// ReadFrame reads and verifies the next frame and returns its payload. It returns io.EOF if there are no more
+// frames, io.ErrUnexpectedEOF if the data ends within a frame, and an error wrapping a *MismatchError if the
+// frame does not match its checksum.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(f.r, f.header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(f.header[:])
+	if uint64(n) > uint64(f.max) {
+		return nil, fmt.Errorf("fletcher4: frame %v at offset %v has length %v, larger than the maximum %v",
+			f.frames, f.offset, n, f.max)
+	}
+
+	buf := make([]byte, int(n)+Size)
+	if _, err := io.ReadFull(f.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	payload := buf[:n]
+	var expected Checksum
+	if err := expected.UnmarshalBinary(buf[n:]); err != nil {
+		return nil, err
+	}
+
+	if !f.cumulative {
+		f.s = stream{}
+	}
+	f.s.write(f.header[:])
+	f.s.write(payload)
+	if actual := f.s.checksum(); actual != expected {
+		return nil, fmt.Errorf("fletcher4: frame %v at offset %v: %w", f.frames, f.offset,
+			&MismatchError{Expected: expected, Actual: actual})
+	}
+	f.frames++
+	f.offset += frameHeaderSize + int64(len(buf))
+	return payload, nil
+}
@@ -0,0 +1,103 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// writeFrames writes the records as frames and returns the encoded data
+func writeFrames(t *testing.T, records [][]byte, opts ...Option) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := NewFrameWriter(&out, opts...)
+	for _, rec := range records {
+		if err := w.WriteFrame(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out.Bytes()
+}
+
+// Test that frames are read back unchanged
+func TestFrames(t *testing.T) {
+	records := [][]byte{randomBytes(0), randomBytes(1), randomBytes(1001), randomBytes(64)}
+	r := NewFrameReader(bytes.NewReader(writeFrames(t, records)))
+	for i, exp := range records {
+		got, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("Frame %v: %v", i, err)
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("Frame %v read back changed", i)
+		}
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("Read after the last frame returned error %v, expected EOF", err)
+	}
+}
+
+// Test that corrupt, truncated and oversized frames are detected
+func TestFramesCorrupt(t *testing.T) {
+	data := writeFrames(t, [][]byte{randomBytes(100), randomBytes(100)})
+
+	corrupt := append([]byte{}, data...)
+	corrupt[frameHeaderSize+100+Size+frameHeaderSize+10] ^= 1
+	r := NewFrameReader(bytes.NewReader(corrupt))
+	if _, err := r.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadFrame(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Corrupt frame returned error %v", err)
+	}
+
+	r = NewFrameReader(bytes.NewReader(data[:50]))
+	if _, err := r.ReadFrame(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Truncated frame returned error %v", err)
+	}
+
+	r = NewFrameReader(bytes.NewReader(data), WithMaxFrameSize(50))
+	if _, err := r.ReadFrame(); err == nil {
+		t.Error("Frame larger than the maximum was accepted")
+	}
+}
+
+// Test that cumulative frames are read back, and that reordered frames are detected
+func TestFramesCumulative(t *testing.T) {
+	records := [][]byte{randomBytes(100), randomBytes(200), randomBytes(300)}
+	data := writeFrames(t, records, WithCumulative())
+	r := NewFrameReader(bytes.NewReader(data), WithCumulative())
+	for i, exp := range records {
+		got, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("Frame %v: %v", i, err)
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("Frame %v read back changed", i)
+		}
+	}
+
+	// Swap the first two frames, each valid on its own
+	first := frameHeaderSize + 100 + Size
+	second := frameHeaderSize + 200 + Size
+	swapped := append(append(append([]byte{}, data[first:first+second]...), data[:first]...), data[first+second:]...)
+	r = NewFrameReader(bytes.NewReader(swapped), WithCumulative())
+	if _, err := r.ReadFrame(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Reordered cumulative frames returned error %v", err)
+	}
+}
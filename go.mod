@@ -1,3 +1,5 @@
 module go.solidsystem.no/fletcher4
 
 go 1.21.1
+
+require golang.org/x/sys v0.25.0
@@ -0,0 +1,85 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import "io"
+
+// HashingWriter forwards writes to an underlying writer while accumulating the checksum of everything written
+// to it. Writes may have any length, a trailing partial word is padded with zero bytes in the checksum.
+type HashingWriter struct {
+	w io.Writer
+	s stream
+}
+
+// NewHashingWriter returns a HashingWriter forwarding to w.
+func NewHashingWriter(w io.Writer) *HashingWriter {
+	return &HashingWriter{w: w}
+}
+
+// Write writes p to the underlying writer, and adds the bytes it accepted to the checksum.
+func (h *HashingWriter) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.s.write(p[:n])
+	return n, err
+}
+
+// Checksum returns the checksum of everything written so far.
+func (h *HashingWriter) Checksum() Checksum {
+	return h.s.checksum()
+}
+
+// Sum64x4 returns the checksum of everything written so far as 4 words.
+func (h *HashingWriter) Sum64x4() [4]uint64 {
+	return h.s.checksum()
+}
+
+// Count returns the number of bytes written so far.
+func (h *HashingWriter) Count() int64 {
+	return h.s.n
+}
+
+// HashingReader reads from an underlying reader while accumulating the checksum of everything read through it.
+// A trailing partial word is padded with zero bytes in the checksum.
+type HashingReader struct {
+	r io.Reader
+	s stream
+}
+
+// NewHashingReader returns a HashingReader reading from r.
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r}
+}
+
+// Read reads from the underlying reader into p, and adds the bytes read to the checksum.
+func (h *HashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.s.write(p[:n])
+	return n, err
+}
+
+// Checksum returns the checksum of everything read so far.
+func (h *HashingReader) Checksum() Checksum {
+	return h.s.checksum()
+}
+
+// Sum64x4 returns the checksum of everything read so far as 4 words.
+func (h *HashingReader) Sum64x4() [4]uint64 {
+	return h.s.checksum()
+}
+
+// Count returns the number of bytes read so far.
+func (h *HashingReader) Count() int64 {
+	return h.s.n
+}
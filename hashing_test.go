@@ -0,0 +1,60 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// Test that HashingWriter forwards everything and checksums it
+func TestHashingWriter(t *testing.T) {
+	data := randomBytes(50001)
+	var out bytes.Buffer
+	w := NewHashingWriter(&out)
+	if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Error("HashingWriter did not forward the data unchanged")
+	}
+	if exp := paddedChecksum(data); w.Checksum() != exp || w.Sum64x4() != exp {
+		t.Errorf("HashingWriter:\nexpected\t%x,\ngot\t\t%x", exp, w.Checksum())
+	}
+	if w.Count() != int64(len(data)) {
+		t.Errorf("HashingWriter counted %v bytes, expected %v", w.Count(), len(data))
+	}
+}
+
+// Test that HashingReader passes everything through and checksums it
+func TestHashingReader(t *testing.T) {
+	data := randomBytes(50001)
+	r := NewHashingReader(iotest.HalfReader(bytes.NewReader(data)))
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("HashingReader did not pass the data through unchanged")
+	}
+	if exp := paddedChecksum(data); r.Checksum() != exp || r.Sum64x4() != exp {
+		t.Errorf("HashingReader:\nexpected\t%x,\ngot\t\t%x", exp, r.Checksum())
+	}
+	if r.Count() != int64(len(data)) {
+		t.Errorf("HashingReader counted %v bytes, expected %v", r.Count(), len(data))
+	}
+}
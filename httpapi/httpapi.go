@@ -0,0 +1,145 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpapi provides HTTP handlers computing and verifying fletcher4 checksums of uploaded blobs, to be
+// embedded in internal services. Bodies are hashed as they stream in, never buffered, up to a size limit.
+//
+//	POST /checksum            returns {"checksum": "...", "size": N}
+//	POST /verify?checksum=... returns {"ok": true, ...}, or status 422 and "ok": false on a mismatch
+//
+// The expected checksum of /verify is given in hex, or as a Content-Digest field as httpdigest formats it.
+// Errors are returned as {"error": "..."} with status 400, 405 or 413.
+//
+//	http.Handle("/fletcher4/", http.StripPrefix("/fletcher4", &httpapi.Handler{MaxSize: 10 << 30}))
+package httpapi // import go.solidsystem.no/fletcher4/httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/httpdigest"
+)
+
+// DefaultMaxSize is the largest body accepted by a Handler without MaxSize.
+const DefaultMaxSize = 1 << 30
+
+// Handler serves the checksum API.
+type Handler struct {
+	// Largest body accepted, in bytes, DefaultMaxSize if 0
+	MaxSize int64
+	// Options for hashing the bodies, e.g. a rate limit
+	Options []fletcher4.Option
+}
+
+// Response is the body of successful responses, and of verification failures.
+type Response struct {
+	// Checksum of the body in hex, and its size
+	Checksum string `json:"checksum"`
+	Size     int64  `json:"size"`
+	// Outcome of /verify, and the checksum expected
+	OK       *bool  `json:"ok,omitempty"`
+	Expected string `json:"expected,omitempty"`
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/checksum":
+		h.serve(w, r, false)
+	case "/verify":
+		h.serve(w, r, true)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serve hashes the body of r, and compares it to the checksum expected if verify is set.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, verify bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("httpapi: method %v not allowed", r.Method))
+		return
+	}
+	var expected fletcher4.Checksum
+	if verify {
+		var err error
+		if expected, err = expectedChecksum(r); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	maxSize := h.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	if r.ContentLength > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("httpapi: body larger than %v bytes", maxSize))
+		return
+	}
+	sum, size, err := fletcher4.SumReader(http.MaxBytesReader(w, r.Body, maxSize), h.Options...)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("httpapi: body larger than %v bytes", maxSize))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("httpapi: reading body: %w", err))
+		return
+	}
+
+	resp := Response{Checksum: sum.String(), Size: size}
+	code := http.StatusOK
+	if verify {
+		ok := sum == expected
+		resp.OK, resp.Expected = &ok, expected.String()
+		if !ok {
+			code = http.StatusUnprocessableEntity
+		}
+	}
+	writeJSON(w, code, resp)
+}
+
+// expectedChecksum returns the checksum the body of r is verified against, from the checksum parameter or the
+// Content-Digest header.
+func expectedChecksum(r *http.Request) (fletcher4.Checksum, error) {
+	if s := r.URL.Query().Get("checksum"); s != "" {
+		sum, err := fletcher4.ParseChecksum(s)
+		if err != nil {
+			return sum, fmt.Errorf("httpapi: checksum parameter: %w", err)
+		}
+		return sum, nil
+	}
+	if value := r.Header.Get(httpdigest.Field); value != "" {
+		return httpdigest.Parse(value)
+	}
+	return fletcher4.Checksum{}, errors.New("httpapi: no checksum parameter or Content-Digest header to verify against")
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
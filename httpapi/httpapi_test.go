@@ -0,0 +1,94 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/httpdigest"
+)
+
+// serve sends a request with body to h and returns the status and the decoded response.
+func serve(t *testing.T, h http.Handler, method, target, body string, header http.Header) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v %v returned %q: %v", method, target, rec.Body, err)
+	}
+	return rec.Code, resp
+}
+
+// Test computing and verifying the checksums of blobs, and the errors
+func TestHandler(t *testing.T) {
+	h := &Handler{MaxSize: 100}
+	hello := fletcher4.Sum([]byte("hello"))
+	if code, resp := serve(t, h, "POST", "/checksum", "hello", nil); code != 200 || resp["checksum"] != hello.String() || resp["size"] != 5.0 {
+		t.Errorf("Checksum returned %v, %v", code, resp)
+	}
+
+	if code, resp := serve(t, h, "POST", "/verify?checksum="+hello.String(), "hello", nil); code != 200 || resp["ok"] != true {
+		t.Errorf("Verifying matching blob returned %v, %v", code, resp)
+	}
+	digest := http.Header{httpdigest.Field: {httpdigest.Format(hello)}}
+	if code, resp := serve(t, h, "POST", "/verify", "jello", digest); code != 422 || resp["ok"] != false || resp["expected"] != hello.String() {
+		t.Errorf("Verifying changed blob returned %v, %v", code, resp)
+	}
+
+	for _, test := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"GET", "/checksum", "", 405},
+		{"POST", "/verify", "hello", 400},
+		{"POST", "/verify?checksum=xyz", "hello", 400},
+		{"POST", "/checksum", strings.Repeat("x", 101), 413},
+	} {
+		if code, resp := serve(t, h, test.method, test.target, test.body, nil); code != test.code || resp["error"] == nil {
+			t.Errorf("%v %v returned %v, %v, expected %v", test.method, test.target, code, resp, test.code)
+		}
+	}
+}
+
+// Test that the size limit holds for bodies of unknown length
+func TestHandlerStreaming(t *testing.T) {
+	srv := httptest.NewServer(&Handler{MaxSize: 1 << 16})
+	defer srv.Close()
+	for _, test := range []struct {
+		size int
+		code int
+	}{{1 << 16, 200}, {1<<16 + 1, 413}} {
+		// A reader without a known length is sent chunked
+		body := struct{ *strings.Reader }{strings.NewReader(strings.Repeat("y", test.size))}
+		resp, err := http.Post(srv.URL+"/checksum", "application/octet-stream", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("Streaming %v bytes returned %v, expected %v", test.size, resp.StatusCode, test.code)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpdigest
+
+import (
+	"net/http"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Handler returns a handler serving the responses of h with the checksum of their body in a Content-Digest
+// trailer. The body is hashed as h writes it. Declaring the trailer makes HTTP/1.1 responses chunked, so a
+// Content-Length set by h is dropped, as net/http would drop the trailer otherwise.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dw := &responseWriter{ResponseWriter: w}
+		dw.hash = fletcher4.NewHashingWriter(w)
+		h.ServeHTTP(dw, r)
+		if !dw.wroteHeader {
+			dw.WriteHeader(http.StatusOK)
+		}
+		w.Header().Set(Field, Format(dw.hash.Checksum()))
+	})
+}
+
+// responseWriter hashes the body written to the ResponseWriter it wraps, and declares the trailer.
+type responseWriter struct {
+	http.ResponseWriter
+	hash        *fletcher4.HashingWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses come before the final header, which the trailer is declared in
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		w.Header().Add("Trailer", Field)
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.hash.Write(p)
+}
+
+// Flush sends buffered data to the client, if the wrapped ResponseWriter supports it.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
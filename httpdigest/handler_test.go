@@ -0,0 +1,74 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpdigest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// get fetches url and returns the body and the parsed digest trailer.
+func get(t *testing.T, url string) (string, fletcher4.Checksum) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := Parse(resp.Trailer.Get(Field))
+	if err != nil {
+		t.Fatalf("Trailer %q: %v", resp.Trailer, err)
+	}
+	return string(body), sum
+}
+
+// Test that the digest of streamed, sized and empty responses is sent as a trailer
+func TestHandler(t *testing.T) {
+	body := strings.Repeat("streamed data ", 10000) + "odd"
+	srv := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			for i := 0; i < len(body); i += 1000 {
+				io.WriteString(w, body[i:min(i+1000, len(body))])
+				w.(http.Flusher).Flush()
+			}
+		case "/sized":
+			w.Header().Set("Content-Length", "5")
+			io.WriteString(w, "sized")
+		}
+	})))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		path, body string
+	}{{"/stream", body}, {"/sized", "sized"}, {"/empty", ""}} {
+		got, sum := get(t, srv.URL+test.path)
+		if got != test.body {
+			t.Errorf("%v: body is %v bytes, expected %v", test.path, len(got), len(test.body))
+		}
+		if exp := fletcher4.Sum([]byte(test.body)); sum != exp {
+			t.Errorf("%v: digest is %v, expected %v", test.path, sum, exp)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpdigest carries fletcher4 checksums of HTTP message bodies in the Content-Digest field of RFC 9530,
+// for cheap end to end integrity of payloads between internal services. The checksum is computed while the body
+// streams, so responses are never buffered, and sent as a trailer by Handler. Clients using Transport verify it
+// as they read the body, so payloads are checked end to end.
+//
+// The field is a structured dictionary, the checksum is its fletcher4 member holding the 32 bytes of the
+// serialized checksum as a byte sequence, here of "hello world":
+//
+//	Content-Digest: fletcher4=:SfJH3AAAAACI3ZckAgAAAC8uVNkDAAAAPuR8+gUAAAA=:
+//
+// fletcher4 is not a registered algorithm of RFC 9530, and detects accidental corruption only, not tampering.
+package httpdigest // import go.solidsystem.no/fletcher4/httpdigest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Field is the name of the header or trailer field carrying the digest.
+const Field = "Content-Digest"
+
+// Algorithm is the key of the checksum in the dictionary of the field.
+const Algorithm = "fletcher4"
+
+// ErrNoDigest is returned by Parse for fields without a fletcher4 member.
+var ErrNoDigest = errors.New("httpdigest: no fletcher4 digest")
+
+// Format returns the value of the field carrying sum.
+func Format(sum fletcher4.Checksum) string {
+	b, _ := sum.MarshalBinary()
+	return Algorithm + "=:" + base64.StdEncoding.EncodeToString(b) + ":"
+}
+
+// Parse returns the checksum in the value of the field, which may hold the digests of other algorithms as well.
+// Parameters of the members are ignored.
+func Parse(value string) (fletcher4.Checksum, error) {
+	for _, member := range strings.Split(value, ",") {
+		key, item, _ := strings.Cut(strings.TrimSpace(member), "=")
+		if !strings.EqualFold(key, Algorithm) {
+			continue
+		}
+		item, _, _ = strings.Cut(item, ";")
+		encoded, prefixed := strings.CutPrefix(item, ":")
+		encoded, suffixed := strings.CutSuffix(encoded, ":")
+		if !prefixed || !suffixed {
+			return fletcher4.Checksum{}, fmt.Errorf("httpdigest: digest %q is not a byte sequence", item)
+		}
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fletcher4.Checksum{}, fmt.Errorf("httpdigest: digest %q: %w", item, err)
+		}
+		var sum fletcher4.Checksum
+		if err := sum.UnmarshalBinary(b); err != nil {
+			return fletcher4.Checksum{}, fmt.Errorf("httpdigest: digest %q: %w", item, err)
+		}
+		return sum, nil
+	}
+	return fletcher4.Checksum{}, ErrNoDigest
+}
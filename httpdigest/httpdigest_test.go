@@ -0,0 +1,45 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpdigest
+
+import (
+	"errors"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that formatted digests parse back, also among the digests of other algorithms
+func TestParse(t *testing.T) {
+	sum := fletcher4.Sum([]byte("hello world"))
+	value := Format(sum)
+	if value != "fletcher4=:SfJH3AAAAACI3ZckAgAAAC8uVNkDAAAAPuR8+gUAAAA=:" {
+		t.Errorf("Format returned %q", value)
+	}
+	for _, field := range []string{value, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:, " + value + ";p=1"} {
+		if parsed, err := Parse(field); err != nil || parsed != sum {
+			t.Errorf("Parse(%q) returned %v, %v", field, parsed, err)
+		}
+	}
+
+	if _, err := Parse("sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"); !errors.Is(err, ErrNoDigest) {
+		t.Errorf("Parsing field without fletcher4 member returned %v", err)
+	}
+	for _, field := range []string{"fletcher4=abc", "fletcher4=:!!!:", "fletcher4=:AAAA:"} {
+		if _, err := Parse(field); err == nil || errors.Is(err, ErrNoDigest) {
+			t.Errorf("Parsing invalid field %q returned %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpdigest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Transport is an http.RoundTripper verifying the bodies of responses against their Content-Digest, in a
+// trailer as Handler sends it or in the header. The body is hashed as it is read, and the read returning the end
+// of the body returns an error wrapping a *fletcher4.MismatchError instead of io.EOF if it does not match.
+//
+// Bodies that net/http decompressed cannot be verified, as the digest is of the compressed content, and are
+// returned unverified. Set DisableCompression on the base transport to have them verified.
+type Transport struct {
+	// Transport making the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// Whether responses without a digest fail with ErrNoDigest rather than being returned unverified
+	Require bool
+}
+
+// RoundTrip makes the request with the base transport, and wraps the body of the response to verify it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody || resp.Uncompressed || req.Method == http.MethodHead {
+		return resp, err
+	}
+	resp.Body = &verifiedBody{r: fletcher4.NewHashingReader(resp.Body), body: resp.Body, resp: resp,
+		url: req.URL.Redacted(), require: t.Require}
+	return resp, nil
+}
+
+// verifiedBody verifies the body of resp once it is read to its end.
+type verifiedBody struct {
+	r       *fletcher4.HashingReader
+	body    io.ReadCloser
+	resp    *http.Response
+	url     string
+	require bool
+	// Outcome of the verification, returned by every read after the end
+	err error
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		err = b.verify()
+		b.err = err
+	}
+	return n, err
+}
+
+// verify returns io.EOF if the body matches its digest, the trailers having been read with it.
+func (b *verifiedBody) verify() error {
+	value := b.resp.Trailer.Get(Field)
+	if value == "" {
+		value = b.resp.Header.Get(Field)
+	}
+	expected, err := Parse(value)
+	if errors.Is(err, ErrNoDigest) && !b.require {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("httpdigest: body of %v: %w", b.url, err)
+	}
+	if actual := b.r.Checksum(); actual != expected {
+		return fmt.Errorf("httpdigest: body of %v: %w", b.url,
+			&fletcher4.MismatchError{Expected: expected, Actual: actual})
+	}
+	return io.EOF
+}
+
+func (b *verifiedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,82 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpdigest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that bodies matching their digest read to EOF, and others fail at their end
+func TestTransport(t *testing.T) {
+	body := strings.Repeat("payload ", 5000)
+	good := Format(fletcher4.Sum([]byte(body)))
+	bad := Format(fletcher4.Sum([]byte("other")))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/handler":
+			Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, body) })).ServeHTTP(w, r)
+			return
+		case "/header":
+			w.Header().Set(Field, good)
+		case "/corrupt":
+			w.Header().Set("Trailer", Field)
+			defer w.Header().Set(Field, bad)
+		}
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		path    string
+		require bool
+		err     error
+	}{
+		{"/handler", true, nil},
+		{"/header", true, nil},
+		{"/plain", false, nil},
+		{"/plain", true, ErrNoDigest},
+		{"/corrupt", false, fletcher4.ErrChecksumMismatch},
+	} {
+		client := &http.Client{Transport: &Transport{Require: test.require}}
+		resp, err := client.Get(srv.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if test.err == nil && (err != nil || string(got) != body) {
+			t.Errorf("%v: read %v bytes, %v", test.path, len(got), err)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%v: read returned %v, expected %v", test.path, err, test.err)
+		}
+	}
+	var mismatch *fletcher4.MismatchError
+	resp, err := (&http.Client{Transport: &Transport{}}).Get(srv.URL + "/corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.As(err, &mismatch) || mismatch.Actual != fletcher4.Sum([]byte(body)) {
+		t.Errorf("Corrupt body returned %v", err)
+	}
+}
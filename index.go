@@ -0,0 +1,232 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+)
+
+// IndexEntry is the checksum of one chunk of a larger file.
+type IndexEntry struct {
+	Offset   int64
+	Length   int64
+	Checksum Checksum
+}
+
+// Index holds the checksum of every chunk of a file, so parts of it can be verified later without rehashing
+// all of it. All chunks are ChunkSize bytes long, except the last one which may be shorter.
+type Index struct {
+	ChunkSize int64
+	Size      int64
+	Entries   []IndexEntry
+}
+
+// First line of a serialized index, followed by the chunk size and the size
+const indexHeader = "fletcher4-index 1"
+
+// MaxIndexChunkSize is the largest chunk size of an index. Indexes read from files claiming larger chunks are
+// refused, as verifying such a chunk would read an unbounded amount of data at once.
+const MaxIndexChunkSize int64 = 1 << 32
+
+// Checksum returns the checksum of the whole file, combined from the checksums of the chunks.
+func (x *Index) Checksum() Checksum {
+	var res Checksum
+	for _, e := range x.Entries {
+		padded := (e.Length + BlockSize - 1) &^ (BlockSize - 1)
+		res = Combine(res, e.Checksum, padded)
+	}
+	return res
+}
+
+// WriteTo writes the index as text, a header line followed by one "offset length checksum" line per chunk.
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	fmt.Fprintf(cw, "%v %v %v\n", indexHeader, x.ChunkSize, x.Size)
+	for _, e := range x.Entries {
+		fmt.Fprintf(cw, "%v %v %v\n", e.Offset, e.Length, e.Checksum)
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// ReadIndex reads an index written by Index.WriteTo. Indexes that are not consistent, e.g. with chunks that do
+// not follow each other from offset zero or are not of the chunk size, are refused, so verifying with an index
+// read from an untrusted file cannot go wrong.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	x := &Index{}
+	if _, err := fmt.Fscanf(br, indexHeader+" %d %d\n", &x.ChunkSize, &x.Size); err != nil {
+		return nil, fmt.Errorf("fletcher4: invalid index header: %w", err)
+	}
+	for {
+		var e IndexEntry
+		var sum string
+		_, err := fmt.Fscanf(br, "%d %d %s\n", &e.Offset, &e.Length, &sum)
+		if err == io.EOF {
+			if err := x.validate(); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fletcher4: invalid index entry %v: %w", len(x.Entries), err)
+		}
+		if e.Checksum, err = ParseChecksum(sum); err != nil {
+			return nil, err
+		}
+		x.Entries = append(x.Entries, e)
+	}
+}
+
+// validate returns an error unless the chunk size is a positive multiple of BlockSize no larger than
+// MaxIndexChunkSize, and the entries are chunks of that size following each other from offset zero to the size,
+// but for a shorter last one.
+func (x *Index) validate() error {
+	if x.ChunkSize <= 0 || x.ChunkSize%BlockSize != 0 || x.ChunkSize > MaxIndexChunkSize {
+		return fmt.Errorf("fletcher4: invalid index chunk size %v", x.ChunkSize)
+	}
+	var off int64
+	for i, e := range x.Entries {
+		last := i == len(x.Entries)-1
+		if e.Offset != off || e.Length > x.ChunkSize || !last && e.Length != x.ChunkSize || e.Length <= 0 {
+			return fmt.Errorf("fletcher4: invalid index entry %v, %v bytes at offset %v", i, e.Length, e.Offset)
+		}
+		off += e.Length
+	}
+	if off != x.Size {
+		return fmt.Errorf("fletcher4: index entries cover %v bytes, not the size of %v", off, x.Size)
+	}
+	return nil
+}
+
+// IndexWriter checksums everything written to it in chunks of a fixed size, building an Index while the data
+// streams past, e.g. while copying or hashing a large file.
+type IndexWriter struct {
+	index Index
+	s     stream
+}
+
+// NewIndexWriter returns an IndexWriter recording a checksum per chunkSize bytes, which must be a positive
+// multiple of BlockSize no larger than MaxIndexChunkSize.
+func NewIndexWriter(chunkSize int64) *IndexWriter {
+	if chunkSize <= 0 || chunkSize%BlockSize != 0 || chunkSize > MaxIndexChunkSize {
+		panic(fmt.Sprintf("Index chunk size must be a positive multiple of %v bytes, up to %v.", BlockSize, MaxIndexChunkSize))
+	}
+	return &IndexWriter{index: Index{ChunkSize: chunkSize}}
+}
+
+// Write adds p to the index. It never returns an error.
+func (w *IndexWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := w.index.ChunkSize - w.s.n
+		if take > int64(len(p)) {
+			take = int64(len(p))
+		}
+		w.s.write(p[:take])
+		p = p[take:]
+		if w.s.n == w.index.ChunkSize {
+			w.flush()
+		}
+	}
+	return n, nil
+}
+
+// flush records the current chunk
+func (w *IndexWriter) flush() {
+	w.index.Entries = append(w.index.Entries, IndexEntry{Offset: w.index.Size, Length: w.s.n, Checksum: w.s.checksum()})
+	w.index.Size += w.s.n
+	w.s = stream{}
+}
+
+// Index returns the index of everything written, including the last partial chunk. Further writes are not
+// allowed afterwards.
+func (w *IndexWriter) Index() *Index {
+	if w.s.n > 0 {
+		w.flush()
+	}
+	return &w.index
+}
+
+// BuildIndex reads r until EOF and returns the index of its data in chunks of chunkSize bytes.
+func BuildIndex(r io.Reader, chunkSize int64, opts ...Option) (*Index, error) {
+	o := newOptions(opts)
+	w := NewIndexWriter(chunkSize)
+	if _, err := readChunks(context.Background(), r, make([]byte, o.chunkSizeFor(-1)), func(p []byte) { w.Write(p) }); err != nil {
+		return nil, err
+	}
+	return w.Index(), nil
+}
+
+// countingWriter counts the bytes written and keeps the first error, so a sequence of writes can be checked
+// once at the end.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// VerifyRange reads and verifies only the chunks of the index overlapping the n bytes at off, so random access
+// readers can verify just what they read. It returns an error wrapping a *MismatchError naming the first
+// chunk not matching the index, or io.ErrUnexpectedEOF if r is shorter than the index says. Inconsistent
+// indexes, which ReadIndex refuses, give an error too.
+func VerifyRange(r io.ReaderAt, index *Index, off, n int64) error {
+	if err := index.validate(); err != nil {
+		return err
+	}
+	if off < 0 || n < 0 || off > index.Size || n > index.Size-off {
+		return fmt.Errorf("fletcher4: range of %v bytes at offset %v is outside the %v bytes indexed", n, off, index.Size)
+	}
+	if n == 0 {
+		return nil
+	}
+	first := off / index.ChunkSize
+	last := (off + n - 1) / index.ChunkSize
+
+	// Chunks are read through a buffer of bounded size, however large they are
+	buf := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(buf)
+	for _, e := range index.Entries[first : last+1] {
+		var s stream
+		read, err := s.readFrom(context.Background(), io.NewSectionReader(r, e.Offset, e.Length), *buf)
+		if err == nil && read < e.Length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if actual := s.checksum(); actual != e.Checksum {
+			return fmt.Errorf("fletcher4: chunk of %v bytes at offset %v: %w", e.Length, e.Offset,
+				&MismatchError{Expected: e.Checksum, Actual: actual})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// Test that the index has the checksum of every chunk, and survives serialization
+func TestIndex(t *testing.T) {
+	data := randomBytes(10*1024 + 7)
+	index, err := BuildIndex(iotest.HalfReader(bytes.NewReader(data)), 1024, WithChunkSize(999))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Size != int64(len(data)) || len(index.Entries) != 11 {
+		t.Fatalf("Index of %v bytes has size %v and %v entries", len(data), index.Size, len(index.Entries))
+	}
+	for i, e := range index.Entries {
+		if e.Offset != int64(i*1024) {
+			t.Errorf("Entry %v has offset %v", i, e.Offset)
+		}
+		if exp := paddedChecksum(data[e.Offset : e.Offset+e.Length]); e.Checksum != exp {
+			t.Errorf("Entry %v:\nexpected\t%x,\ngot\t\t%x", i, exp, e.Checksum)
+		}
+	}
+	if exp := paddedChecksum(data); index.Checksum() != exp {
+		t.Errorf("Index checksum:\nexpected\t%x,\ngot\t\t%x", exp, index.Checksum())
+	}
+
+	var buf bytes.Buffer
+	n, err := index.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %v bytes, wrote %v", n, buf.Len())
+	}
+	read, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, index) {
+		t.Error("Index changed by WriteTo and ReadIndex")
+	}
+}
+
+// Test that inconsistent indexes are refused when read
+func TestReadIndexInvalid(t *testing.T) {
+	sum := Checksum{}.String()
+	for _, index := range []string{
+		"fletcher4-index 1 0 10\n",
+		"fletcher4-index 1 -4 0\n",
+		"fletcher4-index 1 6 6\n0 6 " + sum + "\n",
+		"fletcher4-index 1 8589934592 0\n",
+		// Gaps, overlaps, chunks longer or shorter than the chunk size, and a size the chunks do not add up to
+		"fletcher4-index 1 8 16\n0 8 " + sum + "\n9 7 " + sum + "\n",
+		"fletcher4-index 1 8 16\n0 8 " + sum + "\n4 8 " + sum + "\n",
+		"fletcher4-index 1 8 20\n0 20 " + sum + "\n",
+		"fletcher4-index 1 8 12\n0 4 " + sum + "\n4 8 " + sum + "\n",
+		"fletcher4-index 1 8 10\n0 8 " + sum + "\n",
+		"fletcher4-index 1 8 8\n0 8 " + sum + "\n8 0 " + sum + "\n",
+	} {
+		if _, err := ReadIndex(bytes.NewReader([]byte(index))); err == nil {
+			t.Errorf("Index %q was accepted", index)
+		}
+	}
+	if x, err := ReadIndex(bytes.NewReader([]byte("fletcher4-index 1 8 0\n"))); err != nil || len(x.Entries) != 0 {
+		t.Errorf("Index of an empty file returned %+v, %v", x, err)
+	}
+}
+
+// Test that VerifyRange only checks the chunks overlapping the range
+func TestVerifyRange(t *testing.T) {
+	data := randomBytes(10*1024 + 7)
+	index, err := BuildIndex(bytes.NewReader(data), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte{}, data...)
+	corrupt[5*1024+10] ^= 1
+	r := bytes.NewReader(corrupt)
+
+	// Empty ranges succeed anywhere in the index, even inside the corrupt chunk
+	for _, rng := range [][2]int64{{0, 5 * 1024}, {6 * 1024, 4*1024 + 7}, {0, 0}, {5*1024 + 10, 0}, {int64(len(data)), 0}} {
+		if err := VerifyRange(r, index, rng[0], rng[1]); err != nil {
+			t.Errorf("Range %v of intact chunks failed: %v", rng, err)
+		}
+	}
+	for _, rng := range [][2]int64{{5*1024 + 1023, 1}, {1000, 5000}, {0, int64(len(data))}} {
+		if err := VerifyRange(r, index, rng[0], rng[1]); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Range %v over corrupt chunk returned error %v", rng, err)
+		}
+	}
+	for _, rng := range [][2]int64{
+		{10 * 1024, 100}, {int64(len(data)) + 1, 0}, {-1, 1}, {0, -1},
+		{1, math.MaxInt64}, {math.MaxInt64, 1}, {math.MaxInt64, math.MaxInt64}, {math.MinInt64, math.MaxInt64},
+	} {
+		if err := VerifyRange(r, index, rng[0], rng[1]); err == nil || errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Range %v outside the index returned %v", rng, err)
+		}
+	}
+	if err := VerifyRange(bytes.NewReader(data[:9000]), index, 8000, 1500); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Range past the end of the data returned %v", err)
+	}
+
+	// Indexes built by hand are checked like those read
+	for _, bad := range []*Index{
+		{ChunkSize: 0, Size: 10, Entries: []IndexEntry{{0, 10, Checksum{}}}},
+		{ChunkSize: 8, Size: 16, Entries: []IndexEntry{{0, 16, Checksum{}}}},
+		{ChunkSize: 1 << 60, Size: 16, Entries: []IndexEntry{{0, 16, Checksum{}}}},
+	} {
+		if err := VerifyRange(r, bad, 0, 1); err == nil {
+			t.Errorf("VerifyRange with index %+v succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pool runs tasks on a bounded number of goroutines and delivers their results in order, for the file
+// hashing of the library.
+package pool // import go.solidsystem.no/fletcher4/internal/pool
+
+import (
+	"context"
+	"runtime"
+)
+
+// RunOrdered runs the tasks produce submits on up to workers goroutines, and returns the channel their results
+// are sent on in the order they were submitted. If workers is zero or negative, GOMAXPROCS is used.
+//
+// produce is called on a goroutine of its own. submit blocks while the pool is full, with no more than two results
+// per worker waiting to be received, and returns false once ctx is done, after which produce should return. The
+// channel is closed once produce has returned and every result is sent. If ctx is done, the results not yet
+// received are left out.
+func RunOrdered[T any](ctx context.Context, workers int, produce func(submit func(task func() T) bool)) <-chan T {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := make(chan T)
+	// One channel per task, delivered in order
+	pending := make(chan chan T, 2*workers)
+	running := make(chan struct{}, workers)
+	go func() {
+		defer close(results)
+		for ch := range pending {
+			res := <-ch
+			select {
+			case results <- res:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	go func() {
+		defer close(pending)
+		produce(func(task func() T) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			select {
+			case running <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			ch := make(chan T, 1)
+			select {
+			case pending <- ch:
+			case <-ctx.Done():
+				<-running
+				return false
+			}
+			go func() {
+				ch <- task()
+				<-running
+			}()
+			return true
+		})
+	}()
+	return results
+}
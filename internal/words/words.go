@@ -0,0 +1,55 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package words splits data of any length in the whole 4 byte words fletcher4 hashes, for the streams of the
+// library and the contexts of libfletcher4.
+package words // import go.solidsystem.no/fletcher4/internal/words
+
+// Size is the size of a word.
+const Size = 4
+
+// Partial is a word kept back until the data completing it arrives. It is plain data, libfletcher4 stores it in
+// the contexts of its callers.
+type Partial struct {
+	Bytes [Size]byte
+	// Number of bytes of Bytes in use
+	N uint32
+}
+
+// Write calls fn with the whole words of p, completing the partial word first, and keeps back the trailing bytes
+// of p not making a whole word. fn is not called with empty data.
+func (w *Partial) Write(p []byte, fn func(words []byte)) {
+	if w.N > 0 {
+		c := copy(w.Bytes[w.N:], p)
+		w.N += uint32(c)
+		p = p[c:]
+		if w.N < Size {
+			return
+		}
+		fn(w.Bytes[:])
+		w.N = 0
+	}
+	whole := len(p) &^ (Size - 1)
+	if whole > 0 {
+		fn(p[:whole])
+	}
+	w.N = uint32(copy(w.Bytes[:], p[whole:]))
+}
+
+// Padded returns the partial word padded with zero bytes, and false if there is none.
+func (w *Partial) Padded() ([Size]byte, bool) {
+	var last [Size]byte
+	copy(last[:], w.Bytes[:w.N])
+	return last, w.N > 0
+}
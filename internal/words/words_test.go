@@ -0,0 +1,50 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package words
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test that writes of any length pass on all data in whole words, and keep back the rest
+func TestPartialWrite(t *testing.T) {
+	data := make([]byte, 103)
+	for i := range data {
+		data[i] = byte(i + 1)
+	}
+	for _, step := range []int{1, 2, 3, 5, 8, 100} {
+		var w Partial
+		var got []byte
+		for p := data; len(p) > 0; p = p[min(len(p), step):] {
+			w.Write(p[:min(len(p), step)], func(words []byte) {
+				if len(words) == 0 || len(words)%Size != 0 {
+					t.Errorf("Step %v: called with %v bytes", step, len(words))
+				}
+				got = append(got, words...)
+			})
+		}
+		if !bytes.Equal(got, data[:100]) || w.N != 3 {
+			t.Errorf("Step %v: passed on %v bytes, kept back %v", step, len(got), w.N)
+		}
+		if last, ok := w.Padded(); !ok || last != [Size]byte{101, 102, 103, 0} {
+			t.Errorf("Step %v: padded word is %v, %v", step, last, ok)
+		}
+	}
+	var w Partial
+	if _, ok := w.Padded(); ok {
+		t.Error("Empty partial word was returned")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+// Kernels processing several words in parallel keep L independent lanes, where lane j sees the words
+// j, L+j, 2L+j, ... Each lane runs the normal fletcher4 recurrence over its own words, starting from zero.
+//
+// If a lane has seen m words, a word in it at serial position s counted from the end (s = 1 for the last word)
+// has the weight t = m-k+1 inside the lane, and s = L*t - j. The serial sums are polynomials in s, so they can
+// be rewritten as integer combinations of the lane sums t, C(t+1,2) and C(t+2,3). That gives:
+//
+//	A = sum a_j
+//	B = sum L*b_j - j*a_j
+//	C = sum L²*c_j + (L - L² - 2jL)/2*b_j + j(j-1)/2*a_j
+//	D = sum L³*d_j + (L²(1-j) - L³)*c_j + (L³ - 3L²(1-j) + 3Lj² - 6Lj + 2L)/6*b_j - j(j-1)(j-2)/6*a_j
+//
+// All arithmetic is modulo 2^64, which is fine as the formulas only use integer coefficients.
+
+// finiLanes merges the lane sums of a parallel kernel into the checksum of the words it processed.
+// All slices must have the same length, the number of lanes.
+func finiLanes(a, b, c, d []uint64) digest {
+	var res digest
+	l := int64(len(a))
+	for lane := range a {
+		j := int64(lane)
+		res[0] += a[lane]
+		res[1] += uint64(l)*b[lane] - uint64(j)*a[lane]
+		res[2] += uint64(l*l)*c[lane] + uint64((l-l*l-2*j*l)/2)*b[lane] + uint64(j*(j-1)/2)*a[lane]
+		res[3] += uint64(l*l*l)*d[lane] + uint64(l*l*(1-j)-l*l*l)*c[lane] +
+			uint64((l*l*l-3*l*l*(1-j)+3*l*j*j-6*l*j+2*l)/6)*b[lane] - uint64(j*(j-1)*(j-2)/6)*a[lane]
+	}
+	return res
+}
+
+// combine returns the checksum of the concatenation of two buffers, where x is the running checksum of the
+// first and y the checksum of the second one computed from zero, n bytes long.
+func combine(x digest, n uint64, y digest) digest {
+	k := n / BlockSize
+	// Number of words choose 1, 2 and 3 with repetition, i.e k, C(k+1,2) and C(k+2,3).
+	// The divisions are done on the factors before multiplying so the values are exact modulo 2^64.
+	c1 := k
+	c2 := binomial2(k)
+	f := [3]uint64{k, k + 1, k + 2}
+	f[(3-k%3)%3] /= 3
+	for i := range f {
+		if f[i]%2 == 0 {
+			f[i] /= 2
+			break
+		}
+	}
+	c3 := f[0] * f[1] * f[2]
+
+	return digest{
+		x[0] + y[0],
+		x[1] + y[1] + c1*x[0],
+		x[2] + y[2] + c1*x[1] + c2*x[0],
+		x[3] + y[3] + c1*x[2] + c2*x[1] + c3*x[0],
+	}
+}
+
+// binomial2 returns k(k+1)/2 modulo 2^64.
+func binomial2(k uint64) uint64 {
+	if k%2 == 0 {
+		return k / 2 * (k + 1)
+	}
+	return (k + 1) / 2 * k
+}
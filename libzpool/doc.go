@@ -0,0 +1,23 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package libzpool computes fletcher4 checksums with the OpenZFS implementation from libzpool through cgo. It is
+// only meant for differential testing, to demonstrate that the checksums of this module agree bit for bit with
+// those of ZFS itself, for every kernel of either.
+//
+// The package is only built with the libzpool build tag and cgo enabled, and needs libzpool from OpenZFS, e.g.
+// from the libzfslinux-dev package on Debian and Ubuntu:
+//
+//	go test -tags libzpool ./libzpool
+package libzpool // import go.solidsystem.no/fletcher4/libzpool
@@ -0,0 +1,124 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && libzpool
+
+package libzpool
+
+/*
+#cgo LDFLAGS: -lzpool
+#include <stddef.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+// Declared here rather than including zfs_fletcher.h, which needs the whole libspl include tree
+typedef struct zio_cksum {
+	uint64_t zc_word[4];
+} zio_cksum_t;
+
+extern void fletcher_4_init(void);
+extern void fletcher_4_native(const void *buf, uint64_t size, const void *ctx_template, zio_cksum_t *zcp);
+extern void fletcher_4_byteswap(const void *buf, uint64_t size, const void *ctx_template, zio_cksum_t *zcp);
+extern int fletcher_4_incremental_native(void *buf, size_t size, void *data);
+extern int fletcher_4_impl_set(const char *val);
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Names of the fletcher_4 kernels of OpenZFS, as accepted by its fletcher_4_impl module parameter
+var kernels = []string{"scalar", "superscalar", "superscalar4", "sse2", "ssse3", "avx2", "avx512f", "avx512bw",
+	"aarch64_neon", "altivec"}
+
+var initOnce sync.Once
+
+func initFletcher4() {
+	initOnce.Do(func() { C.fletcher_4_init() })
+}
+
+// Checksum returns the checksum of p computed by fletcher_4_native. The length of p must be a multiple of
+// fletcher4.BlockSize.
+func Checksum(p []byte) fletcher4.Checksum {
+	checkLength(p)
+	initFletcher4()
+	var zc C.zio_cksum_t
+	C.fletcher_4_native(unsafe.Pointer(unsafe.SliceData(p)), C.uint64_t(len(p)), nil, &zc)
+	return toChecksum(&zc)
+}
+
+// Byteswap returns the checksum of p read as words of the other byte order, computed by fletcher_4_byteswap.
+// The length of p must be a multiple of fletcher4.BlockSize.
+func Byteswap(p []byte) fletcher4.Checksum {
+	checkLength(p)
+	initFletcher4()
+	var zc C.zio_cksum_t
+	C.fletcher_4_byteswap(unsafe.Pointer(unsafe.SliceData(p)), C.uint64_t(len(p)), nil, &zc)
+	return toChecksum(&zc)
+}
+
+// Incremental adds p to the running checksum sum with fletcher_4_incremental_native, which OpenZFS uses for send
+// streams. The length of p must be a multiple of fletcher4.BlockSize.
+func Incremental(sum fletcher4.Checksum, p []byte) fletcher4.Checksum {
+	checkLength(p)
+	initFletcher4()
+	var zc C.zio_cksum_t
+	for i, w := range sum {
+		zc.zc_word[i] = C.uint64_t(w)
+	}
+	C.fletcher_4_incremental_native(unsafe.Pointer(unsafe.SliceData(p)), C.size_t(len(p)), unsafe.Pointer(&zc))
+	return toChecksum(&zc)
+}
+
+// SetImplementation selects the OpenZFS kernel used by Checksum and Incremental, e.g. "avx2", or "fastest".
+func SetImplementation(name string) error {
+	initFletcher4()
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	if C.fletcher_4_impl_set(cname) != 0 {
+		return fmt.Errorf("libzpool: implementation %q is not supported", name)
+	}
+	return nil
+}
+
+// Implementations returns the names of the OpenZFS kernels supported by this cpu.
+func Implementations() []string {
+	var names []string
+	for _, name := range kernels {
+		if SetImplementation(name) == nil {
+			names = append(names, name)
+		}
+	}
+	SetImplementation("fastest")
+	return names
+}
+
+func checkLength(p []byte) {
+	if len(p)%fletcher4.BlockSize != 0 {
+		panic(fmt.Sprintf("Data given to libzpool must be a multiple of %v bytes.", fletcher4.BlockSize))
+	}
+}
+
+func toChecksum(zc *C.zio_cksum_t) fletcher4.Checksum {
+	var sum fletcher4.Checksum
+	for i := range sum {
+		sum[i] = uint64(zc.zc_word[i])
+	}
+	return sum
+}
@@ -0,0 +1,94 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && libzpool
+
+package libzpool
+
+import (
+	"math/rand"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/corpus"
+)
+
+// goChecksum returns the checksum of p computed by this module
+func goChecksum(p []byte) fletcher4.Checksum {
+	h := fletcher4.New()
+	h.Write(p)
+	return h.Sum64x4()
+}
+
+// Test that every kernel of this module agrees with every kernel of OpenZFS
+func TestDifferential(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 1<<20)
+	rnd.Read(data)
+	sizes := []int{0, 4, 60, 64, 68, 4096, 128 << 10, len(data) - 4}
+
+	defer fletcher4.SetImplementation(fletcher4.Implementation())
+	for _, zimpl := range Implementations() {
+		if err := SetImplementation(zimpl); err != nil {
+			t.Fatal(err)
+		}
+		for _, impl := range fletcher4.Implementations() {
+			if err := fletcher4.SetImplementation(impl); err != nil {
+				t.Fatal(err)
+			}
+			for _, size := range sizes {
+				if exp, got := Checksum(data[:size]), goChecksum(data[:size]); got != exp {
+					t.Errorf("%v bytes with %v against OpenZFS %v:\nexpected\t%x,\ngot\t\t%x", size, impl, zimpl, exp, got)
+				}
+			}
+		}
+	}
+}
+
+// Test that every kernel of OpenZFS computes the native and byte swapped checksums of the corpus
+func TestCorpus(t *testing.T) {
+	vectors := corpus.Vectors()
+	inputs := make([][]byte, len(vectors))
+	for i, v := range vectors {
+		inputs[i] = v.Input()
+	}
+	defer SetImplementation("fastest")
+	for _, zimpl := range Implementations() {
+		if err := SetImplementation(zimpl); err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range vectors {
+			if sum := Checksum(inputs[i]); sum != v.Checksum {
+				t.Errorf("%v with OpenZFS %v: expected %v, got %v", v.Name, zimpl, v.Checksum, sum)
+			}
+			if sum := Byteswap(inputs[i]); sum != v.Byteswap {
+				t.Errorf("%v byte swapped with OpenZFS %v: expected %v, got %v", v.Name, zimpl, v.Byteswap, sum)
+			}
+		}
+	}
+}
+
+// Test that incremental checksums agree, as used for send streams
+func TestIncremental(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(2)).Read(data)
+	var sum fletcher4.Checksum
+	for off := 0; off < len(data); off += 312 {
+		end := min(off+312, len(data))
+		sum = Incremental(sum, data[off:end])
+	}
+	if exp := goChecksum(data); sum != exp {
+		t.Errorf("Incremental:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifest generates and verifies manifests, listing the size, modification time and fletcher4
+// checksum of every file in a tree.
+//
+// A manifest file is text. The first line is the header "fletcher4-manifest 2" with the version of the format,
+// followed by lines naming the checksum algorithm, when and where the manifest was created, and the number of
+// files. Then comes one line per file, sorted by path, and a last line with the checksum of everything before it:
+//
+//	fletcher4-manifest 2
+//	algorithm fletcher4
+//	created 2023-10-01T12:00:00Z
+//	host "backup1"
+//	files 2
+//	<checksum> <size> <modification time> <path>
+//	<checksum> <size> <modification time> <path>
+//	checksum <checksum of the lines above>
+//
+// Checksums are formatted like fletcher4.Checksum.String, times in RFC 3339 format with nanoseconds in UTC, and
+// the path is slash separated, relative to the root of the tree, and quoted like strconv.Quote does so any file
+// name can be represented. The created and host lines are optional, and Read ignores lines of other keys before
+// the files line. The count of files and the checksum make manifests truncated or corrupted fail to read, rather
+// than verify only part of a tree.
+//
+// Manifests of version 1 have no header lines but the first, and no checksum line. Read still accepts them.
+package manifest // import go.solidsystem.no/fletcher4/manifest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// First line of a manifest file, and of those of version 1
+const (
+	header   = "fletcher4-manifest 2"
+	headerV1 = "fletcher4-manifest 1"
+)
+
+// Name of the checksum algorithm in the header
+const algorithm = "fletcher4"
+
+// Entry describes one file of a manifest.
+type Entry struct {
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Checksum fletcher4.Checksum
+}
+
+// Manifest lists the files of a tree, sorted by path.
+type Manifest struct {
+	// When and on which host the manifest was generated, zero if unknown
+	Created time.Time
+	Host    string
+	Entries []Entry
+}
+
+// newManifest returns an empty manifest created now on this host.
+func newManifest() *Manifest {
+	host, _ := os.Hostname()
+	// Without the monotonic clock reading, as read back
+	return &Manifest{Created: time.Now().UTC().Round(0), Host: host}
+}
+
+// New returns the manifest of entries created now on this host, sorted by path, for files found other than by
+// walking an fs.FS, e.g. the members of an archive.
+func New(entries []Entry) *Manifest {
+	m := newManifest()
+	m.Entries = entries
+	m.sort()
+	return m
+}
+
+// Generate returns the manifest of all regular files in fsys. Use fs.Sub to generate the manifest of a subtree.
+func Generate(fsys fs.FS, opts ...fletcher4.Option) (*Manifest, error) {
+	m := newManifest()
+	err := fletcher4.WalkFS(fsys, ".", func(sum fletcher4.FileSum, err error) error {
+		if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, Entry{Path: sum.Path, Size: sum.Size, ModTime: sum.ModTime, Checksum: sum.Checksum})
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.sort()
+	return m, nil
+}
+
+func (m *Manifest) sort() {
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+}
+
+// WriteTo writes the manifest in the manifest file format.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	h := fletcher4.NewHashingWriter(bw)
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(h, format, args...)
+		}
+	}
+	printf("%v\nalgorithm %v\n", header, algorithm)
+	if !m.Created.IsZero() {
+		printf("created %v\n", m.Created.UTC().Format(time.RFC3339Nano))
+	}
+	if m.Host != "" {
+		printf("host %v\n", strconv.Quote(m.Host))
+	}
+	printf("files %v\n", len(m.Entries))
+	for _, e := range m.Entries {
+		printf("%v %v %v %v\n", e.Checksum, e.Size, e.ModTime.UTC().Format(time.RFC3339Nano), strconv.Quote(e.Path))
+	}
+	if err != nil {
+		return h.Count(), err
+	}
+	n, err := fmt.Fprintf(bw, "checksum %v\n", h.Checksum())
+	total := h.Count() + int64(n)
+	if err != nil {
+		return total, err
+	}
+	return total, bw.Flush()
+}
+
+// Read reads a manifest in the manifest file format, of either version. Manifests of version 2 cut short fail
+// with an error matching io.ErrUnexpectedEOF, and those whose checksum does not match with one matching
+// fletcher4.ErrChecksumMismatch.
+func Read(r io.Reader) (*Manifest, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	h := fletcher4.NewHashingWriter(io.Discard)
+	line := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		line++
+		return scanner.Text(), true
+	}
+	truncated := func(format string, args ...any) error {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("manifest: truncated "+format+": %w", append(args, io.ErrUnexpectedEOF)...)
+	}
+
+	switch text, ok := next(); {
+	case ok && text == headerV1:
+		return readV1(scanner)
+	case ok && text == header:
+		io.WriteString(h, text+"\n")
+	default:
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("manifest: missing header %q", header)
+	}
+
+	m := &Manifest{}
+	files := -1
+	for known := false; files < 0; {
+		text, ok := next()
+		if !ok {
+			return nil, truncated("in header")
+		}
+		io.WriteString(h, text+"\n")
+		var err error
+		switch key, value, _ := strings.Cut(text, " "); key {
+		case "algorithm":
+			if value != algorithm {
+				return nil, fmt.Errorf("manifest: unsupported algorithm %q", value)
+			}
+			known = true
+		case "created":
+			m.Created, err = time.Parse(time.RFC3339Nano, value)
+		case "host":
+			m.Host, err = strconv.Unquote(value)
+		case "files":
+			if !known {
+				return nil, fmt.Errorf("manifest: line %v: no algorithm given", line)
+			}
+			if files, err = strconv.Atoi(value); err == nil && files < 0 {
+				err = fmt.Errorf("negative count %v", files)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("manifest: line %v: invalid %v: %w", line, text, err)
+		}
+	}
+
+	for len(m.Entries) < files {
+		text, ok := next()
+		if !ok {
+			return nil, truncated("after %v of %v files", len(m.Entries), files)
+		}
+		io.WriteString(h, text+"\n")
+		e, err := parseEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: line %v: %w", line, err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+
+	text, ok := next()
+	if !ok {
+		return nil, truncated("before checksum")
+	}
+	hex, found := strings.CutPrefix(text, "checksum ")
+	if !found {
+		return nil, fmt.Errorf("manifest: line %v: expected checksum, got %q", line, text)
+	}
+	expected, err := fletcher4.ParseChecksum(hex)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: line %v: %w", line, err)
+	}
+	if actual := h.Checksum(); actual != expected {
+		return nil, fmt.Errorf("manifest: corrupt: %w", &fletcher4.MismatchError{Expected: expected, Actual: actual})
+	}
+	if _, ok := next(); ok {
+		return nil, fmt.Errorf("manifest: line %v: data after checksum", line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readV1 reads the entries of a manifest of version 1 following its header.
+func readV1(scanner *bufio.Scanner) (*Manifest, error) {
+	m := &Manifest{}
+	for line := 2; scanner.Scan(); line++ {
+		e, err := parseEntry(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("manifest: line %v: %w", line, err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseEntry(line string) (Entry, error) {
+	var e Entry
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return e, fmt.Errorf("expected 4 fields, got %v", len(fields))
+	}
+	var err error
+	if e.Checksum, err = fletcher4.ParseChecksum(fields[0]); err != nil {
+		return e, err
+	}
+	if e.Size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return e, fmt.Errorf("invalid size: %w", err)
+	}
+	if e.ModTime, err = time.Parse(time.RFC3339Nano, fields[2]); err != nil {
+		return e, fmt.Errorf("invalid modification time: %w", err)
+	}
+	if e.Path, err = strconv.Unquote(fields[3]); err != nil {
+		return e, fmt.Errorf("invalid path %v: %w", fields[3], err)
+	}
+	return e, nil
+}
+
+// Problem is the kind of mismatch found between a manifest and a tree.
+type Problem int
+
+const (
+	// The file is listed in the manifest but does not exist
+	Missing Problem = iota + 1
+	// The file exists but is not listed in the manifest
+	Extra
+	// The file has a different size than listed
+	SizeChanged
+	// The file has the listed size, but a different checksum
+	ChecksumChanged
+	// The file could not be read
+	Unreadable
+)
+
+func (p Problem) String() string {
+	switch p {
+	case Missing:
+		return "missing"
+	case Extra:
+		return "extra"
+	case SizeChanged:
+		return "size changed"
+	case ChecksumChanged:
+		return "checksum changed"
+	case Unreadable:
+		return "unreadable"
+	}
+	return fmt.Sprintf("Problem(%d)", int(p))
+}
+
+// Mismatch describes a file not matching the manifest.
+type Mismatch struct {
+	Path    string
+	Problem Problem
+	// The entry of the manifest, nil for Extra files
+	Expected *Entry
+	// The file as found, nil for Missing or Unreadable files
+	Actual *Entry
+	// The error reading an Unreadable file
+	Err error
+}
+
+func (m Mismatch) String() string {
+	switch m.Problem {
+	case SizeChanged:
+		return fmt.Sprintf("%v: size changed from %v to %v", m.Path, m.Expected.Size, m.Actual.Size)
+	case ChecksumChanged:
+		return fmt.Sprintf("%v: checksum changed from %v to %v", m.Path, m.Expected.Checksum, m.Actual.Checksum)
+	case Unreadable:
+		return fmt.Sprintf("%v: unreadable: %v", m.Path, m.Err)
+	}
+	return fmt.Sprintf("%v: %v", m.Path, m.Problem)
+}
+
+// Result is the outcome of verifying a tree against a manifest.
+type Result struct {
+	// Number of files matching the manifest
+	Verified int
+	// Files not matching the manifest, sorted by path
+	Mismatches []Mismatch
+}
+
+// OK reports whether all files matched the manifest.
+func (r *Result) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Verify hashes all regular files in fsys and compares them to the manifest. Files not matching are reported
+// in the result, the error is only set if fsys could not be walked at all.
+func Verify(fsys fs.FS, m *Manifest, opts ...fletcher4.Option) (*Result, error) {
+	expected := make(map[string]*Entry, len(m.Entries))
+	for i := range m.Entries {
+		expected[m.Entries[i].Path] = &m.Entries[i]
+	}
+
+	res := &Result{}
+	err := fletcher4.WalkFS(fsys, ".", func(sum fletcher4.FileSum, err error) error {
+		if err != nil && sum.Path == "." {
+			return err
+		}
+		exp := expected[sum.Path]
+		delete(expected, sum.Path)
+		if err != nil {
+			res.Mismatches = append(res.Mismatches, Mismatch{Path: sum.Path, Problem: Unreadable, Expected: exp, Err: err})
+			return nil
+		}
+		actual := &Entry{Path: sum.Path, Size: sum.Size, ModTime: sum.ModTime, Checksum: sum.Checksum}
+		res.check(exp, actual)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, exp := range expected {
+		res.Mismatches = append(res.Mismatches, Mismatch{Path: exp.Path, Problem: Missing, Expected: exp})
+	}
+	sort.Slice(res.Mismatches, func(i, j int) bool { return res.Mismatches[i].Path < res.Mismatches[j].Path })
+	return res, nil
+}
+
+// check compares a file found to its manifest entry, which is nil if it has none.
+func (r *Result) check(exp, actual *Entry) {
+	switch {
+	case exp == nil:
+		r.Mismatches = append(r.Mismatches, Mismatch{Path: actual.Path, Problem: Extra, Actual: actual})
+	case exp.Size != actual.Size:
+		r.Mismatches = append(r.Mismatches, Mismatch{Path: actual.Path, Problem: SizeChanged, Expected: exp, Actual: actual})
+	case exp.Checksum != actual.Checksum:
+		r.Mismatches = append(r.Mismatches, Mismatch{Path: actual.Path, Problem: ChecksumChanged, Expected: exp, Actual: actual})
+	default:
+		r.Verified++
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+var mtime = time.Date(2023, 10, 1, 12, 0, 0, 123456789, time.UTC)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a.txt":               {Data: []byte("first file"), ModTime: mtime},
+		"dir/b.txt":           {Data: []byte("second file"), ModTime: mtime},
+		"dir/odd name\n.txt":  {Data: []byte("third file"), ModTime: mtime},
+		"dir/sub/c.bin":       {Data: []byte{1, 2, 3, 4, 5}, ModTime: mtime},
+		"dir/sub/empty.bin":   {Data: nil, ModTime: mtime},
+		"dir/sub/unchanged.x": {Data: []byte("unchanged"), ModTime: mtime},
+	}
+}
+
+// Test that a generated manifest survives writing and reading
+func TestGenerateWriteRead(t *testing.T) {
+	m, err := Generate(testFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 6 {
+		t.Fatalf("Manifest has %v entries, expected 6", len(m.Entries))
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, m) {
+		t.Errorf("Manifest changed by writing and reading:\n%+v\n%+v", m, read)
+	}
+	if m.Created.IsZero() {
+		t.Error("Generated manifest has no creation time")
+	}
+}
+
+// Test that truncated and corrupted manifests fail to read, and that version 1 manifests are still read
+func TestReadDamaged(t *testing.T) {
+	m, err := Generate(testFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	data := buf.Bytes()
+	// Only the newline ending the checksum line may be missing
+	for n := 0; n < len(data)-1; n++ {
+		if _, err := Read(bytes.NewReader(data[:n])); err == nil {
+			t.Fatalf("Read of manifest truncated to %v of %v bytes succeeded", n, len(data))
+		} else if n > 0 && data[n-1] == '\n' && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Read of manifest truncated after a line returned %v", err)
+		}
+	}
+
+	// A flipped bit in the path of an entry still parses, but does not match the checksum
+	corrupt := bytes.Clone(data)
+	corrupt[bytes.Index(corrupt, []byte("a.txt"))] ^= 2
+	if _, err := Read(bytes.NewReader(corrupt)); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Read of corrupt manifest returned %v", err)
+	}
+	if _, err := Read(bytes.NewReader(append(bytes.Clone(data), "more\n"...))); err == nil {
+		t.Error("Read of manifest with data after the checksum succeeded")
+	}
+
+	v1 := headerV1 + "\n" + string(data[bytes.Index(data, []byte("files "))+len("files 6\n"):bytes.LastIndex(data, []byte("checksum "))])
+	read, err := Read(bytes.NewBufferString(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Entries, m.Entries) || !read.Created.IsZero() {
+		t.Errorf("Version 1 manifest read as %+v", read)
+	}
+}
+
+// Test that Verify reports every kind of mismatch
+func TestVerify(t *testing.T) {
+	fsys := testFS()
+	m, err := Generate(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Verify(fsys, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Verified != 6 {
+		t.Fatalf("Verify of unchanged tree returned %+v", res)
+	}
+
+	fsys["a.txt"] = &fstest.MapFile{Data: []byte("First file"), ModTime: mtime}
+	fsys["dir/b.txt"] = &fstest.MapFile{Data: []byte("second file, longer"), ModTime: mtime}
+	delete(fsys, "dir/sub/c.bin")
+	fsys["new.txt"] = &fstest.MapFile{Data: []byte("new"), ModTime: mtime}
+	res, err = Verify(fsys, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := map[string]Problem{}
+	for _, mismatch := range res.Mismatches {
+		problems[mismatch.Path] = mismatch.Problem
+	}
+	exp := map[string]Problem{
+		"a.txt":         ChecksumChanged,
+		"dir/b.txt":     SizeChanged,
+		"dir/sub/c.bin": Missing,
+		"new.txt":       Extra,
+	}
+	if !reflect.DeepEqual(problems, exp) {
+		t.Errorf("Verify found %v, expected %v", problems, exp)
+	}
+	if res.Verified != 3 {
+		t.Errorf("Verify verified %v files, expected 3", res.Verified)
+	}
+}
+
+// Test that malformed manifests are rejected
+func TestReadInvalid(t *testing.T) {
+	for _, in := range []string{"", "wrong header\n", header + "\nnot an entry\n"} {
+		if _, err := Read(bytes.NewBufferString(in)); err == nil {
+			t.Errorf("Read(%q) did not fail", in)
+		}
+	}
+}
+
+// Test that Refresh only rehashes files with changed metadata
+func TestRefresh(t *testing.T) {
+	fsys := testFS()
+	m, err := Generate(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := mtime.Add(time.Hour)
+	fsys["a.txt"] = &fstest.MapFile{Data: []byte("First file"), ModTime: later}
+	// Changed contents but same metadata, trusted by Refresh
+	fsys["dir/sub/unchanged.x"] = &fstest.MapFile{Data: []byte("Unchanged"), ModTime: mtime}
+	delete(fsys, "dir/sub/c.bin")
+	fsys["new.txt"] = &fstest.MapFile{Data: []byte("new"), ModTime: mtime}
+
+	updated, res, err := Refresh(fsys, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &RefreshResult{Unchanged: 4, Added: []string{"new.txt"}, Removed: []string{"dir/sub/c.bin"}, Updated: []string{"a.txt"}}
+	if !reflect.DeepEqual(res, exp) {
+		t.Errorf("Refresh returned %+v, expected %+v", res, exp)
+	}
+
+	// The refreshed manifest equals a new one, except for the trusted file
+	fsys["dir/sub/unchanged.x"] = &fstest.MapFile{Data: []byte("unchanged"), ModTime: mtime}
+	generated, err := Generate(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.Entries, generated.Entries) {
+		t.Errorf("Refreshed manifest differs from a generated one:\n%+v\n%+v", updated, generated)
+	}
+	if updated.Created.Before(m.Created) || updated.Host != m.Host {
+		t.Errorf("Refreshed manifest created %v on %q, the original %v on %q", updated.Created, updated.Host, m.Created, m.Host)
+	}
+}
+
+// Test that New sorts the entries and records where the manifest was made
+func TestNew(t *testing.T) {
+	m := New([]Entry{{Path: "b"}, {Path: "a"}})
+	if m.Entries[0].Path != "a" || m.Entries[1].Path != "b" || m.Created.IsZero() {
+		t.Errorf("New returned %+v", m)
+	}
+}
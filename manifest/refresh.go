@@ -0,0 +1,93 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"io/fs"
+	"sort"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// RefreshResult tells what Refresh changed, paths are sorted.
+type RefreshResult struct {
+	// Files with unchanged size and modification time, kept without rehashing
+	Unchanged int
+	// Files new in the tree
+	Added []string
+	// Files no longer in the tree
+	Removed []string
+	// Files with a changed size or modification time, which were rehashed
+	Updated []string
+}
+
+// Refresh returns an updated copy of the manifest m for the tree fsys. Only files that are new, or whose size or
+// modification time differ from the manifest, are hashed. The entries of all other files are trusted and kept
+// as they are, so corruption not touching the metadata goes unnoticed. Run Verify regularly to catch that.
+func Refresh(fsys fs.FS, m *Manifest, opts ...fletcher4.Option) (*Manifest, *RefreshResult, error) {
+	old := make(map[string]*Entry, len(m.Entries))
+	for i := range m.Entries {
+		old[m.Entries[i].Path] = &m.Entries[i]
+	}
+
+	updated := newManifest()
+	res := &RefreshResult{}
+	// Indexes of the entries to hash once the walk is done
+	var rehash []int
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		exp, found := old[path]
+		delete(old, path)
+		if found && exp.Size == fi.Size() && exp.ModTime.Equal(fi.ModTime()) {
+			updated.Entries = append(updated.Entries, *exp)
+			res.Unchanged++
+			return nil
+		}
+		if found {
+			res.Updated = append(res.Updated, path)
+		} else {
+			res.Added = append(res.Added, path)
+		}
+		rehash = append(rehash, len(updated.Entries))
+		updated.Entries = append(updated.Entries, Entry{Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for path := range old {
+		res.Removed = append(res.Removed, path)
+	}
+
+	for _, i := range rehash {
+		sum, err := fletcher4.SumFSFile(fsys, updated.Entries[i].Path, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		updated.Entries[i] = Entry{Path: sum.Path, Size: sum.Size, ModTime: sum.ModTime, Checksum: sum.Checksum}
+	}
+
+	updated.sort()
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Updated)
+	return updated, res, nil
+}
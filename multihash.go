@@ -0,0 +1,78 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"hash"
+	"io"
+)
+
+// MultiHasher feeds everything written to it into the fletcher4 checksum and any number of other hashes in
+// the same pass, e.g. a weak and a strong hash for deduplication without reading the data twice.
+// Writes may have any length, a trailing partial word is padded with zero bytes in the checksum.
+type MultiHasher struct {
+	s      stream
+	hashes []hash.Hash
+}
+
+// NewMultiHasher returns a MultiHasher computing the fletcher4 checksum as well as the given hashes.
+func NewMultiHasher(hashes ...hash.Hash) *MultiHasher {
+	return &MultiHasher{hashes: hashes}
+}
+
+// Write adds p to the checksum and all the hashes. It never returns an error.
+func (m *MultiHasher) Write(p []byte) (int, error) {
+	m.s.write(p)
+	for _, h := range m.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// ReadFrom writes everything read from r until EOF through an internal buffer of DefaultChunkSize bytes.
+func (m *MultiHasher) ReadFrom(r io.Reader) (int64, error) {
+	buf := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(buf)
+
+	return readChunks(context.Background(), r, *buf, func(p []byte) { m.Write(p) })
+}
+
+// Checksum returns the fletcher4 checksum of everything written so far.
+func (m *MultiHasher) Checksum() Checksum {
+	return m.s.checksum()
+}
+
+// Sums returns the result of Sum(nil) of every other hash, in the order they were given to NewMultiHasher.
+func (m *MultiHasher) Sums() [][]byte {
+	sums := make([][]byte, len(m.hashes))
+	for i, h := range m.hashes {
+		sums[i] = h.Sum(nil)
+	}
+	return sums
+}
+
+// Count returns the number of bytes written so far.
+func (m *MultiHasher) Count() int64 {
+	return m.s.n
+}
+
+// Reset resets the checksum and all the hashes to their initial state.
+func (m *MultiHasher) Reset() {
+	m.s = stream{}
+	for _, h := range m.hashes {
+		h.Reset()
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+// Test that MultiHasher computes the checksum and the other hashes in one pass
+func TestMultiHasher(t *testing.T) {
+	data := randomBytes(100001)
+	m := NewMultiHasher(sha256.New(), md5.New())
+	if _, err := io.Copy(m, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if exp := paddedChecksum(data); m.Checksum() != exp {
+		t.Errorf("MultiHasher checksum:\nexpected\t%x,\ngot\t\t%x", exp, m.Checksum())
+	}
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data)
+	sums := m.Sums()
+	if len(sums) != 2 || !bytes.Equal(sums[0], sha[:]) || !bytes.Equal(sums[1], md[:]) {
+		t.Errorf("MultiHasher returned wrong hashes %x", sums)
+	}
+	if m.Count() != int64(len(data)) {
+		t.Errorf("MultiHasher counted %v bytes, expected %v", m.Count(), len(data))
+	}
+
+	m.Reset()
+	if m.Checksum() != (Checksum{}) || m.Count() != 0 {
+		t.Error("MultiHasher not reset")
+	}
+}
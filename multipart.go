@@ -0,0 +1,86 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Part is the checksum of one part of an object uploaded in parts, like the parts of an S3 multipart upload.
+// Parts are hashed independently, possibly on different machines, and combined into the checksum of the whole
+// object with CombineParts.
+type Part struct {
+	// Number of the part, ordering the parts in the object
+	Number int
+	Size   int64
+	// Checksum of the data of the part alone
+	Checksum Checksum
+}
+
+// SumPart reads the part with the given number from r until EOF and returns its checksum.
+func SumPart(number int, r io.Reader, opts ...Option) (Part, error) {
+	sum, size, err := SumReader(r, opts...)
+	if err != nil {
+		return Part{}, fmt.Errorf("fletcher4: part %v: %w", number, err)
+	}
+	return Part{Number: number, Size: size, Checksum: sum}, nil
+}
+
+// CombineParts returns the checksum and size of the object made of parts, ordered by their numbers, which must
+// be unique. Numbers may have gaps, as upload part numbers may. All parts but the last must be a whole number of
+// words long, as a trailing partial word is padded in the checksum of a part but continues into the next part
+// in the object. S3 parts, a multiple of a MiB apart from the last, always are.
+func CombineParts(parts []Part) (Checksum, int64, error) {
+	sorted := append([]Part(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+
+	var sum Checksum
+	var size int64
+	for i, p := range sorted {
+		if i > 0 && p.Number == sorted[i-1].Number {
+			return Checksum{}, 0, fmt.Errorf("fletcher4: part %v given twice", p.Number)
+		}
+		if p.Size < 0 {
+			return Checksum{}, 0, fmt.Errorf("fletcher4: part %v has negative size %v", p.Number, p.Size)
+		}
+		if i < len(sorted)-1 && p.Size%BlockSize != 0 {
+			return Checksum{}, 0, fmt.Errorf("fletcher4: part %v of %v bytes is not the last, but not a multiple of %v bytes",
+				p.Number, p.Size, BlockSize)
+		}
+		padded := (p.Size + BlockSize - 1) &^ (BlockSize - 1)
+		sum = Combine(sum, p.Checksum, padded)
+		size += p.Size
+	}
+	return sum, size, nil
+}
+
+// VerifyParts verifies that parts make up an object with the checksum and size expected, e.g. an upload
+// completed by another machine than the one knowing the checksum of the original. It returns an error wrapping a
+// *MismatchError if the checksum does not match.
+func VerifyParts(parts []Part, expected Checksum, size int64) error {
+	sum, n, err := CombineParts(parts)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("fletcher4: parts make up %v bytes, expected %v", n, size)
+	}
+	if sum != expected {
+		return fmt.Errorf("fletcher4: parts of %v bytes: %w", n, &MismatchError{Expected: expected, Actual: sum})
+	}
+	return nil
+}
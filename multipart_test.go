@@ -0,0 +1,62 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Test that parts hashed independently, in any order, combine into the checksum of the whole object
+func TestCombineParts(t *testing.T) {
+	data := randomBytes(10007)
+	bounds := []int{0, 4096, 8192, 8196, len(data)}
+	var parts []Part
+	for i := len(bounds) - 2; i >= 0; i-- {
+		p, err := SumPart(i+1, bytes.NewReader(data[bounds[i]:bounds[i+1]]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, p)
+	}
+	sum, size, err := CombineParts(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := paddedChecksum(data); sum != exp || size != int64(len(data)) {
+		t.Errorf("Combined parts have %v, %v, expected %v", sum, size, exp)
+	}
+	if err := VerifyParts(parts, paddedChecksum(data), int64(len(data))); err != nil {
+		t.Errorf("Verifying parts returned %v", err)
+	}
+	if err := VerifyParts(parts[1:], paddedChecksum(data), int64(len(data))); err == nil {
+		t.Error("Verifying incomplete parts succeeded")
+	}
+	parts[0].Checksum[0]++
+	if err := VerifyParts(parts, paddedChecksum(data), int64(len(data))); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Verifying corrupt parts returned %v", err)
+	}
+
+	if _, _, err := CombineParts([]Part{{Number: 1, Size: 4}, {Number: 1, Size: 4}}); err == nil {
+		t.Error("Combining duplicate parts succeeded")
+	}
+	if _, _, err := CombineParts([]Part{{Number: 1, Size: 5}, {Number: 2, Size: 4}}); err == nil {
+		t.Error("Combining unaligned part before the last succeeded")
+	}
+	if sum, size, err := CombineParts(nil); err != nil || sum != (Checksum{}) || size != 0 {
+		t.Errorf("Combining no parts returned %v, %v, %v", sum, size, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Combine returns the checksum of two buffers concatenated, given the checksum of each of them and the length
+// in bytes of the second one. This makes it possible to hash parts of a buffer independently, e.g. in parallel
+// or on different machines, and merge the results afterwards. secondLen must be a non-negative multiple of
+// BlockSize.
+func Combine(first, second Checksum, secondLen int64) Checksum {
+	if secondLen < 0 || secondLen%BlockSize != 0 {
+		panic(fmt.Sprintf("Length given to Combine must be a non-negative multiple of %v bytes.", BlockSize))
+	}
+	return Checksum(combine(digest(first), uint64(secondLen), digest(second)))
+}
+
+// Chunks smaller than this are not worth handing to another goroutine
+const minParallelChunk = 256 << 10
+
+// ChecksumParallel returns the checksum of p, splitting it in chunks hashed on up to workers goroutines and
+// combining the results. If workers is zero or negative, GOMAXPROCS is used. The length of p must be a multiple
+// of BlockSize.
+func ChecksumParallel(p []byte, workers int) Checksum {
+	if len(p)%BlockSize != 0 {
+		panic(fmt.Sprintf("Buffer given to ChecksumParallel must be a multiple of %v bytes.", BlockSize))
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if max := len(p) / minParallelChunk; workers > max {
+		workers = max
+	}
+	if workers <= 1 {
+		return Checksum(update(digest{}, p))
+	}
+
+	// Round the chunk size up to a whole number of words, the last chunk gets the rest
+	chunk := (len(p)/workers + BlockSize - 1) &^ (BlockSize - 1)
+	sums := make([]digest, workers)
+	var wg sync.WaitGroup
+	for i := range sums {
+		start := i * chunk
+		end := start + chunk
+		if i == workers-1 {
+			end = len(p)
+		}
+		wg.Add(1)
+		go func(i int, part []byte) {
+			defer wg.Done()
+			sums[i] = update(digest{}, part)
+		}(i, p[start:end])
+	}
+	wg.Wait()
+
+	res := sums[0]
+	for i := 1; i < workers; i++ {
+		n := chunk
+		if i == workers-1 {
+			n = len(p) - i*chunk
+		}
+		res = combine(res, uint64(n), sums[i])
+	}
+	return Checksum(res)
+}
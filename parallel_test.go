@@ -0,0 +1,53 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"math"
+	"testing"
+)
+
+// Test that Combine of two halves gives the checksum of the whole buffer, and refuses invalid lengths
+func TestCombineExported(t *testing.T) {
+	data := randomBytes(4096)
+	exp := Checksum(updateScalar(digest{}, data))
+	first := Checksum(updateScalar(digest{}, data[:1000]))
+	second := Checksum(updateScalar(digest{}, data[1000:]))
+	if got := Combine(first, second, int64(len(data)-1000)); got != exp {
+		t.Errorf("Combine:\nexpected\t%x,\ngot\t\t%x", exp, got)
+	}
+
+	for _, n := range []int64{-4, 3, math.MinInt64} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Combine with length %v did not panic", n)
+				}
+			}()
+			Combine(first, second, n)
+		}()
+	}
+}
+
+// Test that ChecksumParallel gives the same result for any number of workers
+func TestChecksumParallel(t *testing.T) {
+	data := randomBytes(5*minParallelChunk + 3*BlockSize)
+	exp := Checksum(updateScalar(digest{}, data))
+	for _, workers := range []int{0, 1, 2, 3, 5, 8, 100} {
+		if got := ChecksumParallel(data, workers); got != exp {
+			t.Errorf("ChecksumParallel with %v workers:\nexpected\t%x,\ngot\t\t%x", workers, exp, got)
+		}
+	}
+}
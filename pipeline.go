@@ -0,0 +1,111 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// pipelineChunk is a chunk of data read by SumReaderPipelined, on its way to be hashed and combined.
+type pipelineChunk struct {
+	buf []byte
+	// Number of bytes read into buf, and their checksum once done is closed
+	n    int
+	sum  digest
+	done chan struct{}
+}
+
+// SumReaderPipelined is like SumReaderContext, but overlaps reading with hashing, so neither the source nor the
+// cpu waits for the other. The calling goroutine reads chunks of the configured chunk size into a fixed set of
+// two buffers per worker, and up to workers goroutines hash them while the next ones are read. Reads block while
+// every buffer waits to be hashed, bounding the memory used however slow hashing is compared to the source. The
+// checksums of the chunks are combined in order. If workers is zero or negative, GOMAXPROCS is used.
+//
+// If reading fails, the chunks read until then are still hashed, and their checksum and count returned with the
+// error.
+func SumReaderPipelined(ctx context.Context, r io.Reader, workers int, opts ...Option) (Checksum, int64, error) {
+	o := newOptions(opts)
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	m := o.newMeter(-1)
+	defer m.finish()
+	r = m.reader(ctx, r)
+
+	// Buffers not in the pipeline, and the chunks in it in the order they were read
+	free := make(chan []byte, 2*workers)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, o.chunkSize)
+	}
+	work := make(chan *pipelineChunk, cap(free))
+	ordered := make(chan *pipelineChunk, cap(free))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				c.sum = update(digest{}, c.buf[:c.n])
+				close(c.done)
+			}
+		}()
+	}
+	var res digest
+	combined := make(chan struct{})
+	go func() {
+		defer close(combined)
+		for c := range ordered {
+			<-c.done
+			res = combine(res, uint64(c.n), c.sum)
+			free <- c.buf
+		}
+	}()
+
+	var total int64
+	var err error
+	for err == nil {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		buf := <-free
+		var n int
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if n == 0 {
+			free <- buf
+			continue
+		}
+		total += int64(n)
+		// Only the last chunk can be short, its trailing partial word is padded with zero bytes
+		padded := (n + BlockSize - 1) &^ (BlockSize - 1)
+		clear(buf[n:padded])
+		c := &pipelineChunk{buf: buf, n: padded, done: make(chan struct{})}
+		work <- c
+		ordered <- c
+	}
+	close(work)
+	close(ordered)
+	wg.Wait()
+	<-combined
+	if err == io.EOF {
+		err = nil
+	}
+	return Checksum(res), total, err
+}
@@ -0,0 +1,70 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// Test that SumReaderPipelined matches SumReader for any size, chunk size, number of workers and read size
+func TestSumReaderPipelined(t *testing.T) {
+	data := randomBytes(300007)
+	for _, size := range []int{0, 3, 4096, 65536, 65539, len(data)} {
+		exp := paddedChecksum(data[:size])
+		for _, workers := range []int{0, 1, 3} {
+			for _, chunk := range []int{5, 4096, 0} {
+				sum, n, err := SumReaderPipelined(context.Background(), iotest.HalfReader(bytes.NewReader(data[:size])),
+					workers, WithChunkSize(chunk))
+				if err != nil || n != int64(size) || sum != exp {
+					t.Errorf("SumReaderPipelined of %v bytes with %v workers in chunks of %v returned %x, %v, %v, expected %x",
+						size, workers, chunk, sum, n, err, exp)
+				}
+			}
+		}
+	}
+}
+
+// Test that SumReaderPipelined returns read errors, with the checksum of the data read before them
+func TestSumReaderPipelinedError(t *testing.T) {
+	errTest := errors.New("test error")
+	data := randomBytes(10000)
+	r := io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errTest))
+	sum, n, err := SumReaderPipelined(context.Background(), r, 2, WithChunkSize(1024))
+	if !errors.Is(err, errTest) {
+		t.Errorf("SumReaderPipelined returned error %v, expected %v", err, errTest)
+	}
+	if exp := paddedChecksum(data); n != int64(len(data)) || sum != exp {
+		t.Errorf("SumReaderPipelined returned %x of %v bytes before the error, expected %x of %v", sum, n, exp, len(data))
+	}
+}
+
+// Test that SumReaderPipelined stops reading when the context is cancelled
+func TestSumReaderPipelinedCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelReader{r: bytes.NewReader(randomBytes(1 << 20)), reads: 3, cancel: cancel}
+	_, n, err := SumReaderPipelined(ctx, r, 2, WithChunkSize(1024))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SumReaderPipelined returned error %v, expected %v", err, context.Canceled)
+	}
+	if n != 3*1024 {
+		t.Errorf("SumReaderPipelined read %v bytes after cancel, expected %v", n, 3*1024)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io/fs"
+
+	"go.solidsystem.no/fletcher4/internal/pool"
+)
+
+// FileResult is the outcome of hashing one file with SumFiles or WalkFSParallel.
+type FileResult struct {
+	FileSum
+	// Error opening or reading the file, in which case FileSum holds as much as is known
+	Err error
+}
+
+// SumFiles hashes the files of fsys whose paths are received from paths, on up to workers goroutines, and sends
+// the results on the returned channel in the order the paths were received. If workers is zero or negative,
+// GOMAXPROCS is used. A file that cannot be hashed gets a result with the error, and the others are hashed still.
+//
+// Memory stays bounded: no more than two results per worker wait to be received, and paths are not read while
+// they do. The channel is closed once paths is closed and every result sent. If ctx is done, paths is not read
+// anymore, files not yet started are left out, and the channel is closed without the results not yet received.
+// Senders on paths should give up when ctx is done as well.
+func SumFiles(ctx context.Context, fsys fs.FS, paths <-chan string, workers int, opts ...Option) <-chan FileResult {
+	o := newOptions(opts)
+	return pool.RunOrdered(ctx, workers, func(submit func(task func() FileResult) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case path, ok := <-paths:
+				if !ok || !submit(func() FileResult { return newFileResult(sumFSFile(fsys, path, o)) }) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// WalkFSParallel walks the file tree of fsys rooted at root like WalkFS, but hashes the regular files on up to
+// workers goroutines, and sends the results on the returned channel in lexical order, as SumFiles does. Entries
+// that cannot be walked get a result with the error, the walk then goes on with the rest of the tree.
+func WalkFSParallel(ctx context.Context, fsys fs.FS, root string, workers int, opts ...Option) <-chan FileResult {
+	o := newOptions(opts)
+	return pool.RunOrdered(ctx, workers, func(submit func(task func() FileResult) bool) {
+		fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			var ok bool
+			switch {
+			case err != nil:
+				res := FileResult{FileSum{Path: path}, err}
+				ok = submit(func() FileResult { return res })
+			case !d.Type().IsRegular():
+				return nil
+			default:
+				ok = submit(func() FileResult { return newFileResult(sumFSFile(fsys, path, o)) })
+			}
+			if !ok {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	})
+}
+
+func newFileResult(sum FileSum, err error) FileResult {
+	return FileResult{sum, err}
+}
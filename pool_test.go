@@ -0,0 +1,92 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// Test that WalkFSParallel gives the same checksums as SumFS, in the same order, with any number of workers
+func TestWalkFSParallel(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 50; i++ {
+		fsys[fmt.Sprintf("dir%v/file%02v", i%3, i)] = &fstest.MapFile{Data: randomBytes(i * 997)}
+	}
+	exp, err := SumFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 4} {
+		var got []FileSum
+		for res := range WalkFSParallel(context.Background(), fsys, ".", workers) {
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			got = append(got, res.FileSum)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			t.Errorf("WalkFSParallel with %v workers returned %v, expected %v", workers, got, exp)
+		}
+	}
+	if res := <-WalkFSParallel(context.Background(), fsys, "missing", 2); !errors.Is(res.Err, fs.ErrNotExist) {
+		t.Errorf("Walking a missing root returned %+v", res)
+	}
+}
+
+// Test that SumFiles reports files that cannot be hashed in order, and hashes the others
+func TestSumFiles(t *testing.T) {
+	fsys := fstest.MapFS{"a": {Data: []byte("abcd")}, "b": {Data: []byte("efgh")}}
+	paths := make(chan string)
+	go func() {
+		for _, path := range []string{"a", "missing", "b"} {
+			paths <- path
+		}
+		close(paths)
+	}()
+	var results []FileResult
+	for res := range SumFiles(context.Background(), fsys, paths, 2) {
+		results = append(results, res)
+	}
+	if len(results) != 3 || results[0].Path != "a" || results[0].Checksum != paddedChecksum([]byte("abcd")) ||
+		results[1].Path != "missing" || !errors.Is(results[1].Err, fs.ErrNotExist) ||
+		results[2].Path != "b" || results[2].Err != nil || results[2].Size != 4 {
+		t.Errorf("SumFiles returned %+v", results)
+	}
+}
+
+// Test that the results channel is closed when the context is cancelled, even if nothing is received
+func TestSumFilesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string)
+	go func() {
+		for {
+			select {
+			case paths <- "a":
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results := SumFiles(ctx, fstest.MapFS{"a": {Data: []byte("abcd")}}, paths, 2)
+	<-results
+	cancel()
+	for range results {
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress is reported by the reader, file and copy helpers given WithProgress while they hash.
+type Progress struct {
+	// Bytes hashed so far
+	Done int64
+	// Bytes to hash in total, or -1 if unknown, e.g. for readers
+	Total int64
+	// Time since hashing started
+	Elapsed time.Duration
+	// Average throughput since hashing started, in bytes per second
+	Rate float64
+}
+
+// Minimum time between two progress reports
+const progressInterval = 100 * time.Millisecond
+
+// WithProgress makes the helpers call fn with their progress at most every 100ms while they hash, and once more
+// when they are done, so long verifications can drive progress bars and telemetry. fn is called from the
+// hashing goroutine and should return quickly, calls are never concurrent. WalkFS and SumFS report the
+// progress of each file in turn.
+func WithProgress(fn func(Progress)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// meter tracks the bytes hashed by one helper, reports its progress and throttles its reads. A nil meter, as
+// returned when neither progress nor a rate limit is requested, does nothing.
+type meter struct {
+	mu      sync.Mutex
+	fn      func(Progress)
+	limiter Limiter
+	start   time.Time
+	last    time.Time
+	done    int64
+	total   int64
+}
+
+// newMeter returns a meter for hashing total bytes, -1 if unknown, or nil if neither progress nor a rate limit
+// is requested.
+func (o *options) newMeter(total int64) *meter {
+	if o.progress == nil && o.limiter == nil {
+		return nil
+	}
+	now := time.Now()
+	return &meter{fn: o.progress, limiter: o.limiter, start: now, last: now, total: total}
+}
+
+// add records n more bytes hashed without reading them, e.g. holes. It is safe to call from several goroutines
+// at once.
+func (m *meter) add(n int64) {
+	if m == nil || m.fn == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done += n
+	if now := time.Now(); now.Sub(m.last) >= progressInterval {
+		m.last = now
+		m.report(now)
+	}
+}
+
+// read records n more bytes read and hashed, and waits until the rate limit allows reading on.
+func (m *meter) read(ctx context.Context, n int) error {
+	if m == nil {
+		return nil
+	}
+	m.add(int64(n))
+	if m.limiter == nil || n == 0 {
+		return nil
+	}
+	return m.limiter.WaitN(ctx, n)
+}
+
+// finish reports the final progress.
+func (m *meter) finish() {
+	if m == nil || m.fn == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.report(time.Now())
+}
+
+func (m *meter) report(now time.Time) {
+	p := Progress{Done: m.done, Total: m.total, Elapsed: now.Sub(m.start)}
+	if p.Elapsed > 0 {
+		p.Rate = float64(p.Done) / p.Elapsed.Seconds()
+	}
+	m.fn(p)
+}
+
+// reader returns r counting and throttling the reads from it, or r itself for a nil meter.
+func (m *meter) reader(ctx context.Context, r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return &meteredReader{ctx: ctx, r: r, m: m}
+}
+
+type meteredReader struct {
+	ctx context.Context
+	r   io.Reader
+	m   *meter
+}
+
+func (r *meteredReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if werr := r.m.read(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"testing"
+)
+
+// recordProgress returns an option recording every progress report
+func recordProgress(reports *[]Progress) Option {
+	return WithProgress(func(p Progress) {
+		*reports = append(*reports, p)
+	})
+}
+
+// checkProgress checks that progress never goes backwards and ends with everything done
+func checkProgress(t *testing.T, name string, reports []Progress, done, total int64) {
+	t.Helper()
+	if len(reports) == 0 {
+		t.Fatalf("%v reported no progress", name)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Done < reports[i-1].Done || reports[i].Elapsed < reports[i-1].Elapsed {
+			t.Errorf("%v progress went backwards from %+v to %+v", name, reports[i-1], reports[i])
+		}
+	}
+	if last := reports[len(reports)-1]; last.Done != done || last.Total != total {
+		t.Errorf("%v ended with %v of %v bytes done, expected %v of %v", name, last.Done, last.Total, done, total)
+	}
+}
+
+// Test that the reader helpers report their progress
+func TestProgressReader(t *testing.T) {
+	data := randomBytes(3*minParallelChunk + 5)
+	var reports []Progress
+	if _, _, err := SumReader(bytes.NewReader(data), recordProgress(&reports)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, "SumReader", reports, int64(len(data)), -1)
+
+	reports = nil
+	if _, err := SumReaderAt(bytes.NewReader(data), 0, int64(len(data)), 3, recordProgress(&reports)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, "SumReaderAt", reports, int64(len(data)), int64(len(data)))
+
+	reports = nil
+	if _, _, err := CopyN(&bytes.Buffer{}, bytes.NewReader(data), 1000, recordProgress(&reports)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, "CopyN", reports, 1000, 1000)
+}
+
+// Test that SumFile reports its progress for read and memory mapped files
+func TestProgressFile(t *testing.T) {
+	for _, size := range []int{0, 13, mmapThreshold + 5} {
+		var reports []Progress
+		if _, _, err := SumFile(writeTestFile(t, randomBytes(size)), recordProgress(&reports)); err != nil {
+			t.Fatal(err)
+		}
+		checkProgress(t, "SumFile", reports, int64(size), int64(size))
+	}
+}
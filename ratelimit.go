@@ -0,0 +1,75 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter throttles the reads of the reader, file and directory helpers. It is satisfied by *rate.Limiter of
+// golang.org/x/time/rate, with tokens counting bytes. Its burst must be at least the chunk size, the largest
+// amount ever waited for at once.
+type Limiter interface {
+	// WaitN blocks until n more bytes may be read, or returns the error of ctx if it is done first.
+	WaitN(ctx context.Context, n int) error
+}
+
+// WithLimiter throttles reads through l, e.g. a limiter shared by all background jobs of a process.
+func WithLimiter(l Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// WithRateLimit limits reads to bytesPerSecond on average, so background scrubs do not starve foreground
+// workloads. The budget is shared by everything hashed with the returned option, e.g. all files of a WalkFS.
+// Zero or negative rates are unlimited.
+func WithRateLimit(bytesPerSecond int64) Option {
+	if bytesPerSecond <= 0 {
+		return func(o *options) {}
+	}
+	return WithLimiter(&rateLimiter{rate: float64(bytesPerSecond)})
+}
+
+// rateLimiter schedules each read after the previous ones have used up their share of the rate. Time not used
+// while idle is not saved up, so there are no bursts after pauses.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	// When the bytes allowed so far have been spent
+	next time.Time
+}
+
+func (l *rateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	wait := l.next.Sub(now)
+	l.mu.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
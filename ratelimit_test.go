@@ -0,0 +1,68 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingLimiter records the bytes waited for
+type countingLimiter struct {
+	n int
+}
+
+func (l *countingLimiter) WaitN(ctx context.Context, n int) error {
+	l.n += n
+	return nil
+}
+
+// Test that every byte read by the file and reader helpers passes the limiter
+func TestLimiter(t *testing.T) {
+	data := randomBytes(mmapThreshold + 5)
+	l := &countingLimiter{}
+	if _, _, err := SumFile(writeTestFile(t, data), WithLimiter(l)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SumReader(bytes.NewReader(data), WithLimiter(l)); err != nil {
+		t.Fatal(err)
+	}
+	if l.n != 2*len(data) {
+		t.Errorf("Limiter saw %v bytes, expected %v", l.n, 2*len(data))
+	}
+}
+
+// Test that WithRateLimit keeps reads to the rate, and stops waiting when the context is done
+func TestRateLimit(t *testing.T) {
+	const rate = 4 << 20
+	data := randomBytes(1 << 20)
+	start := time.Now()
+	if _, _, err := SumReader(bytes.NewReader(data), WithRateLimit(rate), WithChunkSize(64<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Reading %v bytes at %v bytes per second took %v", len(data), rate, elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := SumReaderContext(ctx, bytes.NewReader(data), WithRateLimit(1<<10))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Rate limited read with expired context returned %v", err)
+	}
+}
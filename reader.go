@@ -0,0 +1,100 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// SumReader reads r until EOF and returns the checksum of the data together with the number of bytes read.
+// The data does not need to be a multiple of BlockSize long, a trailing partial word is padded with zero bytes.
+// Reads of any size are handled, the data are kept aligned to whole words internally.
+func SumReader(r io.Reader, opts ...Option) (Checksum, int64, error) {
+	return SumReaderContext(context.Background(), r, opts...)
+}
+
+// SumReaderContext is like SumReader, but checks ctx between each chunk read and stops with the error of ctx
+// as soon as it is done. The checksum and count of the data read until then are returned as well.
+func SumReaderContext(ctx context.Context, r io.Reader, opts ...Option) (Checksum, int64, error) {
+	o := newOptions(opts)
+	m := o.newMeter(-1)
+	defer m.finish()
+	var s stream
+	n, err := s.readFrom(ctx, m.reader(ctx, r), make([]byte, o.chunkSizeFor(-1)))
+	return s.checksum(), n, err
+}
+
+// SumReaderAt returns the checksum of the n bytes of r starting at off. The range is split in parts read and
+// hashed on up to workers goroutines, whose checksums are then combined, so fast devices are not limited by a
+// single sequential reader. If workers is zero or negative, GOMAXPROCS is used. A trailing partial word is
+// padded with zero bytes, and io.ErrUnexpectedEOF returned if r ends before off+n. A negative off or n is an
+// error.
+func SumReaderAt(r io.ReaderAt, off, n int64, workers int, opts ...Option) (Checksum, error) {
+	if off < 0 || n < 0 {
+		return Checksum{}, fmt.Errorf("fletcher4: invalid range of %v bytes at offset %v", n, off)
+	}
+	o := newOptions(opts)
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if max := n / minParallelChunk; int64(workers) > max {
+		workers = int(max)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// All parts but the last are a whole number of words, so they can be combined
+	part := (n/int64(workers) + BlockSize - 1) &^ (BlockSize - 1)
+	m := o.newMeter(n)
+	defer m.finish()
+	parts := make([]stream, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range parts {
+		start := int64(i) * part
+		length := part
+		if i == workers-1 {
+			length = n - start
+		}
+		wg.Add(1)
+		go func(i int, start, length int64) {
+			defer wg.Done()
+			section := m.reader(context.Background(), io.NewSectionReader(r, off+start, length))
+			read, err := parts[i].readFrom(context.Background(), section, make([]byte, o.chunkSizeFor(length)))
+			if err == nil && read < length {
+				err = io.ErrUnexpectedEOF
+			}
+			errs[i] = err
+		}(i, start, length)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return Checksum{}, err
+		}
+	}
+	res := parts[0].checksum()
+	for i := 1; i < workers; i++ {
+		padded := (parts[i].n + BlockSize - 1) &^ (BlockSize - 1)
+		res = Checksum(combine(digest(res), uint64(padded), digest(parts[i].checksum())))
+	}
+	return res, nil
+}
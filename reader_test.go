@@ -0,0 +1,111 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+// Test that SumReader hashes the whole reader, also with a tiny chunk size
+func TestSumReader(t *testing.T) {
+	data := randomBytes(100003)
+	exp := paddedChecksum(data)
+	for _, opts := range [][]Option{nil, {WithChunkSize(5)}} {
+		sum, n, err := SumReader(bytes.NewReader(data), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(data)) {
+			t.Errorf("SumReader read %v bytes, expected %v", n, len(data))
+		}
+		if sum != exp {
+			t.Errorf("SumReader:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+		}
+	}
+}
+
+// Test that SumReader returns read errors
+func TestSumReaderError(t *testing.T) {
+	errTest := errors.New("test error")
+	if _, _, err := SumReader(iotest.ErrReader(errTest)); !errors.Is(err, errTest) {
+		t.Errorf("SumReader returned error %v, expected %v", err, errTest)
+	}
+}
+
+// cancelReader cancels a context after a number of reads
+type cancelReader struct {
+	r      io.Reader
+	reads  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	c.reads--
+	if c.reads == 0 {
+		c.cancel()
+	}
+	return c.r.Read(p)
+}
+
+// Test that SumReaderContext stops reading when the context is cancelled
+func TestSumReaderContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelReader{r: bytes.NewReader(randomBytes(1 << 20)), reads: 3, cancel: cancel}
+	_, n, err := SumReaderContext(ctx, r, WithChunkSize(1024))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SumReaderContext returned error %v, expected %v", err, context.Canceled)
+	}
+	if n != 3*1024 {
+		t.Errorf("SumReaderContext read %v bytes after cancel, expected %v", n, 3*1024)
+	}
+}
+
+// Test that SumReaderAt gives the checksum of the range for any number of workers
+func TestSumReaderAt(t *testing.T) {
+	data := randomBytes(3*minParallelChunk + 1001)
+	r := bytes.NewReader(data)
+	for _, workers := range []int{0, 1, 2, 3, 7} {
+		for _, off := range []int64{0, 12, 1001} {
+			exp := paddedChecksum(data[off:])
+			sum, err := SumReaderAt(r, off, int64(len(data))-off, workers, WithChunkSize(4096))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum != exp {
+				t.Errorf("SumReaderAt offset %v, %v workers:\nexpected\t%x,\ngot\t\t%x", off, workers, exp, sum)
+			}
+		}
+	}
+}
+
+// Test that SumReaderAt fails if the reader ends before the range, or the range is negative
+func TestSumReaderAtShort(t *testing.T) {
+	r := bytes.NewReader(randomBytes(1000))
+	if _, err := SumReaderAt(r, 0, 2000, 1); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("SumReaderAt past the end returned error %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+	for _, rng := range [][2]int64{{0, -1}, {-1, 10}, {10, math.MinInt64}} {
+		if _, err := SumReaderAt(r, rng[0], rng[1], 1); err == nil {
+			t.Errorf("SumReaderAt of range %v succeeded", rng)
+		}
+	}
+}
@@ -0,0 +1,780 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: fletcher4.proto
+
+package remote
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Problem is the kind of mismatch found between a manifest and a tree.
+type Problem int32
+
+const (
+	Problem_PROBLEM_UNSPECIFIED Problem = 0
+	// The file is listed in the manifest but does not exist
+	Problem_PROBLEM_MISSING Problem = 1
+	// The file exists but is not listed in the manifest
+	Problem_PROBLEM_EXTRA Problem = 2
+	// The file has a different size than listed
+	Problem_PROBLEM_SIZE_CHANGED Problem = 3
+	// The file has the listed size, but a different checksum
+	Problem_PROBLEM_CHECKSUM_CHANGED Problem = 4
+	// The file could not be read
+	Problem_PROBLEM_UNREADABLE Problem = 5
+)
+
+// Enum value maps for Problem.
+var (
+	Problem_name = map[int32]string{
+		0: "PROBLEM_UNSPECIFIED",
+		1: "PROBLEM_MISSING",
+		2: "PROBLEM_EXTRA",
+		3: "PROBLEM_SIZE_CHANGED",
+		4: "PROBLEM_CHECKSUM_CHANGED",
+		5: "PROBLEM_UNREADABLE",
+	}
+	Problem_value = map[string]int32{
+		"PROBLEM_UNSPECIFIED":      0,
+		"PROBLEM_MISSING":          1,
+		"PROBLEM_EXTRA":            2,
+		"PROBLEM_SIZE_CHANGED":     3,
+		"PROBLEM_CHECKSUM_CHANGED": 4,
+		"PROBLEM_UNREADABLE":       5,
+	}
+)
+
+func (x Problem) Enum() *Problem {
+	p := new(Problem)
+	*p = x
+	return p
+}
+
+func (x Problem) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Problem) Descriptor() protoreflect.EnumDescriptor {
+	return file_fletcher4_proto_enumTypes[0].Descriptor()
+}
+
+func (Problem) Type() protoreflect.EnumType {
+	return &file_fletcher4_proto_enumTypes[0]
+}
+
+func (x Problem) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Problem.Descriptor instead.
+func (Problem) EnumDescriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{0}
+}
+
+// Checksum holds the 4 words of a fletcher4 checksum.
+type Checksum struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	A uint64 `protobuf:"fixed64,1,opt,name=a,proto3" json:"a,omitempty"`
+	B uint64 `protobuf:"fixed64,2,opt,name=b,proto3" json:"b,omitempty"`
+	C uint64 `protobuf:"fixed64,3,opt,name=c,proto3" json:"c,omitempty"`
+	D uint64 `protobuf:"fixed64,4,opt,name=d,proto3" json:"d,omitempty"`
+}
+
+func (x *Checksum) Reset() {
+	*x = Checksum{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Checksum) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Checksum) ProtoMessage() {}
+
+func (x *Checksum) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Checksum.ProtoReflect.Descriptor instead.
+func (*Checksum) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Checksum) GetA() uint64 {
+	if x != nil {
+		return x.A
+	}
+	return 0
+}
+
+func (x *Checksum) GetB() uint64 {
+	if x != nil {
+		return x.B
+	}
+	return 0
+}
+
+func (x *Checksum) GetC() uint64 {
+	if x != nil {
+		return x.C
+	}
+	return 0
+}
+
+func (x *Checksum) GetD() uint64 {
+	if x != nil {
+		return x.D
+	}
+	return 0
+}
+
+type SumRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Next bytes of the data, a trailing partial word of the whole data is padded with zero bytes
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *SumRequest) Reset() {
+	*x = SumRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SumRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SumRequest) ProtoMessage() {}
+
+func (x *SumRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SumRequest.ProtoReflect.Descriptor instead.
+func (*SumRequest) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SumRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type SumResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Checksum *Checksum `protobuf:"bytes,1,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// Number of bytes hashed
+	Size int64 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (x *SumResponse) Reset() {
+	*x = SumResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SumResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SumResponse) ProtoMessage() {}
+
+func (x *SumResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SumResponse.ProtoReflect.Descriptor instead.
+func (*SumResponse) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SumResponse) GetChecksum() *Checksum {
+	if x != nil {
+		return x.Checksum
+	}
+	return nil
+}
+
+func (x *SumResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type VerifyManifestRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Slash separated path of the tree below the root the server serves, "." for the root itself
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Manifest of the tree, in the text form of the manifest package
+	Manifest []byte `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+}
+
+func (x *VerifyManifestRequest) Reset() {
+	*x = VerifyManifestRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VerifyManifestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyManifestRequest) ProtoMessage() {}
+
+func (x *VerifyManifestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyManifestRequest.ProtoReflect.Descriptor instead.
+func (*VerifyManifestRequest) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *VerifyManifestRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *VerifyManifestRequest) GetManifest() []byte {
+	if x != nil {
+		return x.Manifest
+	}
+	return nil
+}
+
+// Entry describes one file of a manifest, or the file found.
+type Entry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size int64  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	// Modification time in nanoseconds since the Unix epoch, 0 if unknown
+	ModTimeUnixNano int64     `protobuf:"varint,3,opt,name=mod_time_unix_nano,json=modTimeUnixNano,proto3" json:"mod_time_unix_nano,omitempty"`
+	Checksum        *Checksum `protobuf:"bytes,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+}
+
+func (x *Entry) Reset() {
+	*x = Entry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Entry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Entry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Entry) GetModTimeUnixNano() int64 {
+	if x != nil {
+		return x.ModTimeUnixNano
+	}
+	return 0
+}
+
+func (x *Entry) GetChecksum() *Checksum {
+	if x != nil {
+		return x.Checksum
+	}
+	return nil
+}
+
+// Mismatch describes a file not matching the manifest.
+type Mismatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path    string  `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Problem Problem `protobuf:"varint,2,opt,name=problem,proto3,enum=fletcher4.remote.v1.Problem" json:"problem,omitempty"`
+	// The entry of the manifest, unset for extra files
+	Expected *Entry `protobuf:"bytes,3,opt,name=expected,proto3" json:"expected,omitempty"`
+	// The file as found, unset for missing or unreadable files
+	Actual *Entry `protobuf:"bytes,4,opt,name=actual,proto3" json:"actual,omitempty"`
+	// The error reading an unreadable file
+	Error string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *Mismatch) Reset() {
+	*x = Mismatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Mismatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Mismatch) ProtoMessage() {}
+
+func (x *Mismatch) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Mismatch.ProtoReflect.Descriptor instead.
+func (*Mismatch) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Mismatch) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Mismatch) GetProblem() Problem {
+	if x != nil {
+		return x.Problem
+	}
+	return Problem_PROBLEM_UNSPECIFIED
+}
+
+func (x *Mismatch) GetExpected() *Entry {
+	if x != nil {
+		return x.Expected
+	}
+	return nil
+}
+
+func (x *Mismatch) GetActual() *Entry {
+	if x != nil {
+		return x.Actual
+	}
+	return nil
+}
+
+func (x *Mismatch) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type VerifyManifestResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Number of files matching the manifest
+	Verified int64 `protobuf:"varint,1,opt,name=verified,proto3" json:"verified,omitempty"`
+	// Files not matching the manifest, sorted by path
+	Mismatches []*Mismatch `protobuf:"bytes,2,rep,name=mismatches,proto3" json:"mismatches,omitempty"`
+}
+
+func (x *VerifyManifestResponse) Reset() {
+	*x = VerifyManifestResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_fletcher4_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VerifyManifestResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyManifestResponse) ProtoMessage() {}
+
+func (x *VerifyManifestResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fletcher4_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyManifestResponse.ProtoReflect.Descriptor instead.
+func (*VerifyManifestResponse) Descriptor() ([]byte, []int) {
+	return file_fletcher4_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *VerifyManifestResponse) GetVerified() int64 {
+	if x != nil {
+		return x.Verified
+	}
+	return 0
+}
+
+func (x *VerifyManifestResponse) GetMismatches() []*Mismatch {
+	if x != nil {
+		return x.Mismatches
+	}
+	return nil
+}
+
+var File_fletcher4_proto protoreflect.FileDescriptor
+
+var file_fletcher4_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x13, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d,
+	0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x22, 0x42, 0x0a, 0x08, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73,
+	0x75, 0x6d, 0x12, 0x0c, 0x0a, 0x01, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x06, 0x52, 0x01, 0x61,
+	0x12, 0x0c, 0x0a, 0x01, 0x62, 0x18, 0x02, 0x20, 0x01, 0x28, 0x06, 0x52, 0x01, 0x62, 0x12, 0x0c,
+	0x0a, 0x01, 0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x06, 0x52, 0x01, 0x63, 0x12, 0x0c, 0x0a, 0x01,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x06, 0x52, 0x01, 0x64, 0x22, 0x20, 0x0a, 0x0a, 0x53, 0x75,
+	0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x5c, 0x0a, 0x0b,
+	0x53, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x08, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e,
+	0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x52, 0x08, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x47, 0x0a, 0x15, 0x56, 0x65,
+	0x72, 0x69, 0x66, 0x79, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x61, 0x6e, 0x69, 0x66,
+	0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6d, 0x61, 0x6e, 0x69, 0x66,
+	0x65, 0x73, 0x74, 0x22, 0x97, 0x01, 0x0a, 0x05, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x12, 0x0a,
+	0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74,
+	0x68, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x2b, 0x0a, 0x12, 0x6d, 0x6f, 0x64, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0f, 0x6d, 0x6f, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61,
+	0x6e, 0x6f, 0x12, 0x39, 0x0a, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34,
+	0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x73, 0x75, 0x6d, 0x52, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x22, 0xd8, 0x01,
+	0x0a, 0x08, 0x4d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x36,
+	0x0a, 0x07, 0x70, 0x72, 0x6f, 0x62, 0x6c, 0x65, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x1c, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f,
+	0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x62, 0x6c, 0x65, 0x6d, 0x52, 0x07, 0x70,
+	0x72, 0x6f, 0x62, 0x6c, 0x65, 0x6d, 0x12, 0x36, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63,
+	0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x32,
+	0x0a, 0x06, 0x61, 0x63, 0x74, 0x75, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74,
+	0x65, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x61, 0x63, 0x74, 0x75,
+	0x61, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x73, 0x0a, 0x16, 0x56, 0x65, 0x72, 0x69,
+	0x66, 0x79, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x12, 0x3d,
+	0x0a, 0x0a, 0x6d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72,
+	0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63,
+	0x68, 0x52, 0x0a, 0x6d, 0x69, 0x73, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x2a, 0x9a, 0x01,
+	0x0a, 0x07, 0x50, 0x72, 0x6f, 0x62, 0x6c, 0x65, 0x6d, 0x12, 0x17, 0x0a, 0x13, 0x50, 0x52, 0x4f,
+	0x42, 0x4c, 0x45, 0x4d, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
+	0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x50, 0x52, 0x4f, 0x42, 0x4c, 0x45, 0x4d, 0x5f, 0x4d, 0x49,
+	0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x50, 0x52, 0x4f, 0x42, 0x4c,
+	0x45, 0x4d, 0x5f, 0x45, 0x58, 0x54, 0x52, 0x41, 0x10, 0x02, 0x12, 0x18, 0x0a, 0x14, 0x50, 0x52,
+	0x4f, 0x42, 0x4c, 0x45, 0x4d, 0x5f, 0x53, 0x49, 0x5a, 0x45, 0x5f, 0x43, 0x48, 0x41, 0x4e, 0x47,
+	0x45, 0x44, 0x10, 0x03, 0x12, 0x1c, 0x0a, 0x18, 0x50, 0x52, 0x4f, 0x42, 0x4c, 0x45, 0x4d, 0x5f,
+	0x43, 0x48, 0x45, 0x43, 0x4b, 0x53, 0x55, 0x4d, 0x5f, 0x43, 0x48, 0x41, 0x4e, 0x47, 0x45, 0x44,
+	0x10, 0x04, 0x12, 0x16, 0x0a, 0x12, 0x50, 0x52, 0x4f, 0x42, 0x4c, 0x45, 0x4d, 0x5f, 0x55, 0x4e,
+	0x52, 0x45, 0x41, 0x44, 0x41, 0x42, 0x4c, 0x45, 0x10, 0x05, 0x32, 0xc8, 0x01, 0x0a, 0x0f, 0x43,
+	0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x4a,
+	0x0a, 0x03, 0x53, 0x75, 0x6d, 0x12, 0x1f, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72,
+	0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x75, 0x6d, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63, 0x68, 0x65,
+	0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x75, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x12, 0x69, 0x0a, 0x0e, 0x56, 0x65,
+	0x72, 0x69, 0x66, 0x79, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x12, 0x2a, 0x2e, 0x66,
+	0x6c, 0x65, 0x74, 0x63, 0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x66, 0x6c, 0x65, 0x74, 0x63,
+	0x68, 0x65, 0x72, 0x34, 0x2e, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x56,
+	0x65, 0x72, 0x69, 0x66, 0x79, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x24, 0x5a, 0x22, 0x67, 0x6f, 0x2e, 0x73, 0x6f, 0x6c, 0x69,
+	0x64, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x2e, 0x6e, 0x6f, 0x2f, 0x66, 0x6c, 0x65, 0x74, 0x63,
+	0x68, 0x65, 0x72, 0x34, 0x2f, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_fletcher4_proto_rawDescOnce sync.Once
+	file_fletcher4_proto_rawDescData = file_fletcher4_proto_rawDesc
+)
+
+func file_fletcher4_proto_rawDescGZIP() []byte {
+	file_fletcher4_proto_rawDescOnce.Do(func() {
+		file_fletcher4_proto_rawDescData = protoimpl.X.CompressGZIP(file_fletcher4_proto_rawDescData)
+	})
+	return file_fletcher4_proto_rawDescData
+}
+
+var file_fletcher4_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_fletcher4_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_fletcher4_proto_goTypes = []any{
+	(Problem)(0),                   // 0: fletcher4.remote.v1.Problem
+	(*Checksum)(nil),               // 1: fletcher4.remote.v1.Checksum
+	(*SumRequest)(nil),             // 2: fletcher4.remote.v1.SumRequest
+	(*SumResponse)(nil),            // 3: fletcher4.remote.v1.SumResponse
+	(*VerifyManifestRequest)(nil),  // 4: fletcher4.remote.v1.VerifyManifestRequest
+	(*Entry)(nil),                  // 5: fletcher4.remote.v1.Entry
+	(*Mismatch)(nil),               // 6: fletcher4.remote.v1.Mismatch
+	(*VerifyManifestResponse)(nil), // 7: fletcher4.remote.v1.VerifyManifestResponse
+}
+var file_fletcher4_proto_depIdxs = []int32{
+	1, // 0: fletcher4.remote.v1.SumResponse.checksum:type_name -> fletcher4.remote.v1.Checksum
+	1, // 1: fletcher4.remote.v1.Entry.checksum:type_name -> fletcher4.remote.v1.Checksum
+	0, // 2: fletcher4.remote.v1.Mismatch.problem:type_name -> fletcher4.remote.v1.Problem
+	5, // 3: fletcher4.remote.v1.Mismatch.expected:type_name -> fletcher4.remote.v1.Entry
+	5, // 4: fletcher4.remote.v1.Mismatch.actual:type_name -> fletcher4.remote.v1.Entry
+	6, // 5: fletcher4.remote.v1.VerifyManifestResponse.mismatches:type_name -> fletcher4.remote.v1.Mismatch
+	2, // 6: fletcher4.remote.v1.ChecksumService.Sum:input_type -> fletcher4.remote.v1.SumRequest
+	4, // 7: fletcher4.remote.v1.ChecksumService.VerifyManifest:input_type -> fletcher4.remote.v1.VerifyManifestRequest
+	3, // 8: fletcher4.remote.v1.ChecksumService.Sum:output_type -> fletcher4.remote.v1.SumResponse
+	7, // 9: fletcher4.remote.v1.ChecksumService.VerifyManifest:output_type -> fletcher4.remote.v1.VerifyManifestResponse
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_fletcher4_proto_init() }
+func file_fletcher4_proto_init() {
+	if File_fletcher4_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_fletcher4_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Checksum); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*SumRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*SumResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*VerifyManifestRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*Entry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*Mismatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_fletcher4_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*VerifyManifestResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_fletcher4_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fletcher4_proto_goTypes,
+		DependencyIndexes: file_fletcher4_proto_depIdxs,
+		EnumInfos:         file_fletcher4_proto_enumTypes,
+		MessageInfos:      file_fletcher4_proto_msgTypes,
+	}.Build()
+	File_fletcher4_proto = out.File
+	file_fletcher4_proto_rawDesc = nil
+	file_fletcher4_proto_goTypes = nil
+	file_fletcher4_proto_depIdxs = nil
+}
@@ -0,0 +1,207 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: fletcher4.proto
+
+package remote
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	ChecksumService_Sum_FullMethodName            = "/fletcher4.remote.v1.ChecksumService/Sum"
+	ChecksumService_VerifyManifest_FullMethodName = "/fletcher4.remote.v1.ChecksumService/VerifyManifest"
+)
+
+// ChecksumServiceClient is the client API for ChecksumService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ChecksumService computes fletcher4 checksums on the host it runs on, e.g. next to the storage, for clients in
+// languages without a good implementation of their own.
+type ChecksumServiceClient interface {
+	// Sum returns the checksum of the bytes streamed in, in any number of requests of any size.
+	Sum(ctx context.Context, opts ...grpc.CallOption) (ChecksumService_SumClient, error)
+	// VerifyManifest verifies a directory tree on the server against a manifest.
+	VerifyManifest(ctx context.Context, in *VerifyManifestRequest, opts ...grpc.CallOption) (*VerifyManifestResponse, error)
+}
+
+type checksumServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChecksumServiceClient(cc grpc.ClientConnInterface) ChecksumServiceClient {
+	return &checksumServiceClient{cc}
+}
+
+func (c *checksumServiceClient) Sum(ctx context.Context, opts ...grpc.CallOption) (ChecksumService_SumClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChecksumService_ServiceDesc.Streams[0], ChecksumService_Sum_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &checksumServiceSumClient{ClientStream: stream}
+	return x, nil
+}
+
+type ChecksumService_SumClient interface {
+	Send(*SumRequest) error
+	CloseAndRecv() (*SumResponse, error)
+	grpc.ClientStream
+}
+
+type checksumServiceSumClient struct {
+	grpc.ClientStream
+}
+
+func (x *checksumServiceSumClient) Send(m *SumRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *checksumServiceSumClient) CloseAndRecv() (*SumResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(SumResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *checksumServiceClient) VerifyManifest(ctx context.Context, in *VerifyManifestRequest, opts ...grpc.CallOption) (*VerifyManifestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyManifestResponse)
+	err := c.cc.Invoke(ctx, ChecksumService_VerifyManifest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChecksumServiceServer is the server API for ChecksumService service.
+// All implementations must embed UnimplementedChecksumServiceServer
+// for forward compatibility
+//
+// ChecksumService computes fletcher4 checksums on the host it runs on, e.g. next to the storage, for clients in
+// languages without a good implementation of their own.
+type ChecksumServiceServer interface {
+	// Sum returns the checksum of the bytes streamed in, in any number of requests of any size.
+	Sum(ChecksumService_SumServer) error
+	// VerifyManifest verifies a directory tree on the server against a manifest.
+	VerifyManifest(context.Context, *VerifyManifestRequest) (*VerifyManifestResponse, error)
+	mustEmbedUnimplementedChecksumServiceServer()
+}
+
+// UnimplementedChecksumServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedChecksumServiceServer struct {
+}
+
+func (UnimplementedChecksumServiceServer) Sum(ChecksumService_SumServer) error {
+	return status.Errorf(codes.Unimplemented, "method Sum not implemented")
+}
+func (UnimplementedChecksumServiceServer) VerifyManifest(context.Context, *VerifyManifestRequest) (*VerifyManifestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyManifest not implemented")
+}
+func (UnimplementedChecksumServiceServer) mustEmbedUnimplementedChecksumServiceServer() {}
+
+// UnsafeChecksumServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChecksumServiceServer will
+// result in compilation errors.
+type UnsafeChecksumServiceServer interface {
+	mustEmbedUnimplementedChecksumServiceServer()
+}
+
+func RegisterChecksumServiceServer(s grpc.ServiceRegistrar, srv ChecksumServiceServer) {
+	s.RegisterService(&ChecksumService_ServiceDesc, srv)
+}
+
+func _ChecksumService_Sum_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChecksumServiceServer).Sum(&checksumServiceSumServer{ServerStream: stream})
+}
+
+type ChecksumService_SumServer interface {
+	SendAndClose(*SumResponse) error
+	Recv() (*SumRequest, error)
+	grpc.ServerStream
+}
+
+type checksumServiceSumServer struct {
+	grpc.ServerStream
+}
+
+func (x *checksumServiceSumServer) SendAndClose(m *SumResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *checksumServiceSumServer) Recv() (*SumRequest, error) {
+	m := new(SumRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ChecksumService_VerifyManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyManifestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChecksumServiceServer).VerifyManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChecksumService_VerifyManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChecksumServiceServer).VerifyManifest(ctx, req.(*VerifyManifestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ChecksumService_ServiceDesc is the grpc.ServiceDesc for ChecksumService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChecksumService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fletcher4.remote.v1.ChecksumService",
+	HandlerType: (*ChecksumServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "VerifyManifest",
+			Handler:    _ChecksumService_VerifyManifest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sum",
+			Handler:       _ChecksumService_Sum_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "fletcher4.proto",
+}
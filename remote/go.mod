@@ -0,0 +1,16 @@
+module go.solidsystem.no/fletcher4/remote
+
+go 1.21.1
+
+require (
+	go.solidsystem.no/fletcher4 v0.1.0
+	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
+)
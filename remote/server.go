@@ -0,0 +1,173 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote serves fletcher4 checksums over gRPC, so checksumming can be offloaded to hosts next to the
+// storage, and used from languages without a good implementation of their own. The service is defined in
+// fletcher4.proto: Sum returns the checksum of the bytes streamed to it, and VerifyManifest verifies a tree on
+// the server against a manifest of the manifest package.
+//
+//	s := grpc.NewServer()
+//	remote.RegisterChecksumServiceServer(s, remote.NewServer(os.DirFS("/srv/archive")))
+//	s.Serve(lis)
+package remote // import go.solidsystem.no/fletcher4/remote
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fletcher4.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/manifest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Size of the requests SumReader sends
+const sumChunk = 64 << 10
+
+// Server implements ChecksumService.
+type Server struct {
+	UnimplementedChecksumServiceServer
+	fsys fs.FS
+	opts []fletcher4.Option
+}
+
+// NewServer returns a server verifying manifests against the trees in fsys, or refusing to if fsys is nil. The
+// options are used hashing the files, e.g. to limit the rate of reads.
+func NewServer(fsys fs.FS, opts ...fletcher4.Option) *Server {
+	return &Server{fsys: fsys, opts: opts}
+}
+
+// Sum returns the checksum of the data of all requests received.
+func (s *Server) Sum(stream ChecksumService_SumServer) error {
+	h := fletcher4.NewHashingWriter(io.Discard)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		h.Write(req.Data)
+	}
+	return stream.SendAndClose(&SumResponse{Checksum: NewChecksum(h.Checksum()), Size: h.Count()})
+}
+
+// VerifyManifest verifies the tree at the path of the request against its manifest.
+func (s *Server) VerifyManifest(ctx context.Context, req *VerifyManifestRequest) (*VerifyManifestResponse, error) {
+	if s.fsys == nil {
+		return nil, status.Error(codes.Unimplemented, "remote: the server does not verify manifests")
+	}
+	m, err := manifest.Read(bytes.NewReader(req.Manifest))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	path := req.Path
+	if path == "" {
+		path = "."
+	}
+	if !fs.ValidPath(path) {
+		return nil, status.Errorf(codes.InvalidArgument, "remote: invalid path %q", req.Path)
+	}
+	sub, err := fs.Sub(s.fsys, path)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res, err := manifest.Verify(sub, m, s.opts...)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &VerifyManifestResponse{Verified: int64(res.Verified)}
+	for _, mm := range res.Mismatches {
+		// The problems are numbered like those of the manifest package
+		msg := &Mismatch{Path: mm.Path, Problem: Problem(mm.Problem), Expected: newEntry(mm.Expected), Actual: newEntry(mm.Actual)}
+		if mm.Err != nil {
+			msg.Error = mm.Err.Error()
+		}
+		resp.Mismatches = append(resp.Mismatches, msg)
+	}
+	return resp, nil
+}
+
+// NewChecksum returns the message of sum.
+func NewChecksum(sum fletcher4.Checksum) *Checksum {
+	return &Checksum{A: sum[0], B: sum[1], C: sum[2], D: sum[3]}
+}
+
+// Fletcher4 returns the checksum of the message.
+func (x *Checksum) Fletcher4() fletcher4.Checksum {
+	return fletcher4.Checksum{x.GetA(), x.GetB(), x.GetC(), x.GetD()}
+}
+
+// newEntry returns the message of the manifest entry e, nil if e is.
+func newEntry(e *manifest.Entry) *Entry {
+	if e == nil {
+		return nil
+	}
+	msg := &Entry{Path: e.Path, Size: e.Size, Checksum: NewChecksum(e.Checksum)}
+	if !e.ModTime.IsZero() {
+		msg.ModTimeUnixNano = e.ModTime.UnixNano()
+	}
+	return msg
+}
+
+// ModTime returns the modification time of the entry, the zero time if unknown.
+func (x *Entry) ModTime() time.Time {
+	if x.GetModTimeUnixNano() == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, x.GetModTimeUnixNano())
+}
+
+// SumReader streams r to the Sum method of the service and returns the checksum and size of its data.
+func SumReader(ctx context.Context, client ChecksumServiceClient, r io.Reader) (fletcher4.Checksum, int64, error) {
+	stream, err := client.Sum(ctx)
+	if err != nil {
+		return fletcher4.Checksum{}, 0, err
+	}
+	buf := make([]byte, sumChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&SumRequest{Data: buf[:n]}); err != nil {
+				// The error of the call is returned by CloseAndRecv
+				if err == io.EOF {
+					break
+				}
+				return fletcher4.Checksum{}, 0, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fletcher4.Checksum{}, 0, err
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return fletcher4.Checksum{}, 0, err
+	}
+	return resp.Checksum.Fletcher4(), resp.Size, nil
+}
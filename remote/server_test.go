@@ -0,0 +1,112 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.solidsystem.no/fletcher4"
+	"go.solidsystem.no/fletcher4/manifest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves srv over an in-memory connection and returns a client of it.
+func dial(t *testing.T, srv *Server) ChecksumServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterChecksumServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewChecksumServiceClient(conn)
+}
+
+// Test that data streamed in any number of requests gets its checksum
+func TestSum(t *testing.T) {
+	client := dial(t, NewServer(nil))
+	for _, data := range []string{"", "odd", strings.Repeat("streamed data ", 20000)} {
+		sum, size, err := SumReader(context.Background(), client, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := fletcher4.Sum([]byte(data)); sum != exp || size != int64(len(data)) {
+			t.Errorf("Sum of %v bytes is %v, %v, expected %v", len(data), sum, size, exp)
+		}
+	}
+}
+
+// Test verifying a tree on the server against a manifest
+func TestVerifyManifest(t *testing.T) {
+	fsys := fstest.MapFS{"tree/a": {Data: []byte("hello")}, "tree/b": {Data: []byte("world")}}
+	m, err := manifest.Generate(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fsys["tree/a"] = &fstest.MapFile{Data: []byte("jello")}
+	delete(fsys, "tree/b")
+	fsys["tree/c"] = &fstest.MapFile{Data: []byte("extra")}
+	client := dial(t, NewServer(fsys))
+
+	// The manifest was generated of the root, so its paths start with tree/
+	resp, err := client.VerifyManifest(context.Background(), &VerifyManifestRequest{Manifest: buf.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Verified != 0 || len(resp.Mismatches) != 3 {
+		t.Fatalf("Verification returned %v", resp)
+	}
+	for i, exp := range []Problem{Problem_PROBLEM_CHECKSUM_CHANGED, Problem_PROBLEM_MISSING, Problem_PROBLEM_EXTRA} {
+		if mm := resp.Mismatches[i]; mm.Problem != exp {
+			t.Errorf("Mismatch %v of %v is %v, expected %v", i, mm.Path, mm.Problem, exp)
+		}
+	}
+	if exp := fletcher4.Sum([]byte("hello")); resp.Mismatches[0].Expected.Checksum.Fletcher4() != exp {
+		t.Errorf("Expected checksum is %v", resp.Mismatches[0].Expected.Checksum.Fletcher4())
+	}
+
+	for _, test := range []struct {
+		req  *VerifyManifestRequest
+		code codes.Code
+	}{
+		{&VerifyManifestRequest{Manifest: []byte("garbage")}, codes.InvalidArgument},
+		{&VerifyManifestRequest{Path: "../etc", Manifest: buf.Bytes()}, codes.InvalidArgument},
+		{&VerifyManifestRequest{Path: "missing", Manifest: buf.Bytes()}, codes.NotFound},
+	} {
+		if _, err := client.VerifyManifest(context.Background(), test.req); status.Code(err) != test.code {
+			t.Errorf("Verifying %q returned %v, expected %v", test.req.Path, err, test.code)
+		}
+	}
+	if _, err := dial(t, NewServer(nil)).VerifyManifest(context.Background(), &VerifyManifestRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("Server without tree returned %v", err)
+	}
+}
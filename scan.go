@@ -0,0 +1,72 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// BlockSum is the checksum of one block of an image.
+type BlockSum struct {
+	Offset   int64
+	Length   int
+	Checksum Checksum
+}
+
+// ScanBlocks reads r until EOF, e.g. a raw disk or pool image, and calls fn with the checksum of every block of
+// blockSize bytes in order, as the blocks stream past. Comparing the scans of two mirror copies, or of an image
+// against the checksums a file system recorded for it, finds the damaged blocks. The last block may be
+// shorter, and is padded with zero bytes to a whole word. blockSize must be a positive multiple of BlockSize.
+//
+// Reads are made in chunks of whole blocks about the chunk size option large, and may be throttled and
+// reported with WithRateLimit and WithProgress. Scanning stops with the error of ctx once it is done, or the
+// first error returned by fn.
+func ScanBlocks(ctx context.Context, r io.Reader, blockSize int, fn func(BlockSum) error, opts ...Option) error {
+	if blockSize <= 0 || blockSize%BlockSize != 0 {
+		panic(fmt.Sprintf("Block size given to ScanBlocks must be a positive multiple of %v bytes.", BlockSize))
+	}
+	o := newOptions(opts)
+	m := o.newMeter(-1)
+	defer m.finish()
+	r = m.reader(ctx, r)
+
+	chunk := (o.chunkSize + blockSize - 1) / blockSize * blockSize
+	buf := make([]byte, chunk)
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(r, buf)
+		for p := buf[:n]; len(p) > 0; {
+			length := min(len(p), blockSize)
+			var s stream
+			s.write(p[:length])
+			if ferr := fn(BlockSum{Offset: offset, Length: length, Checksum: s.checksum()}); ferr != nil {
+				return ferr
+			}
+			offset += int64(length)
+			p = p[length:]
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
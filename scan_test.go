@@ -0,0 +1,66 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// Test that every block is reported in order, across chunk boundaries and with a short last block
+func TestScanBlocks(t *testing.T) {
+	const blockSize = 512
+	data := randomBytes(10*blockSize + 13)
+	var sums []BlockSum
+	err := ScanBlocks(context.Background(), bytes.NewReader(data), blockSize, func(s BlockSum) error {
+		sums = append(sums, s)
+		return nil
+	}, WithChunkSize(3*blockSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 11 {
+		t.Fatalf("Scanned %v blocks, expected 11", len(sums))
+	}
+	for i, s := range sums {
+		end := min((i+1)*blockSize, len(data))
+		exp := BlockSum{Offset: int64(i * blockSize), Length: end - i*blockSize, Checksum: paddedChecksum(data[i*blockSize : end])}
+		if s != exp {
+			t.Errorf("Block %v is %+v, expected %+v", i, s, exp)
+		}
+	}
+}
+
+// Test that scanning stops at the first error of the callback and when the context is done
+func TestScanBlocksStop(t *testing.T) {
+	data := randomBytes(4096)
+	errStop := errors.New("stop")
+	calls := 0
+	err := ScanBlocks(context.Background(), bytes.NewReader(data), 512, func(s BlockSum) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("Scan returned %v after %v calls", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ScanBlocks(ctx, bytes.NewReader(data), 512, func(s BlockSum) error { return nil }); err != context.Canceled {
+		t.Errorf("Scan with canceled context returned %v", err)
+	}
+}
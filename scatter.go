@@ -0,0 +1,80 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import "fmt"
+
+// Scatter is data held in non contiguous segments, like a scatter ABD of ZFS, and hashed by SumScatter without
+// copying it into one buffer first.
+type Scatter interface {
+	// Len returns the length of the data
+	Len() int64
+	// Segments calls fn with the segments of the data in order of their offsets, and stops at the first error
+	// returned by fn. Gaps between segments, and after the last one up to Len, hold zero bytes.
+	Segments(fn func(off int64, p []byte) error) error
+}
+
+// ScatterSegment is one segment of a ScatterList.
+type ScatterSegment struct {
+	// Offset of the segment in the data
+	Offset int64
+	Data   []byte
+}
+
+// ScatterList is a Scatter of segments sorted by offset, e.g. the pages of a buffer or the iovecs of a vectored
+// read. Its length is the end of the last segment.
+type ScatterList []ScatterSegment
+
+// Len returns the end of the last segment.
+func (l ScatterList) Len() int64 {
+	if len(l) == 0 {
+		return 0
+	}
+	last := l[len(l)-1]
+	return last.Offset + int64(len(last.Data))
+}
+
+// Segments calls fn with every segment of the list in turn.
+func (l ScatterList) Segments(fn func(off int64, p []byte) error) error {
+	for _, seg := range l {
+		if err := fn(seg.Offset, seg.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SumScatter returns the checksum of the data of s, as if its segments were copied into one buffer of s.Len()
+// bytes with zeros in the gaps. Segments may be of any length, words straddling two of them are put together
+// on the fly, and gaps are added without hashing their zero bytes. An error is returned if the segments
+// overlap, are out of order or extend past the length, or if iterating them fails.
+func SumScatter(s Scatter) (Checksum, error) {
+	var st stream
+	size := s.Len()
+	err := s.Segments(func(off int64, p []byte) error {
+		if off < st.n || off+int64(len(p)) > size {
+			return fmt.Errorf("fletcher4: scatter segment of %v bytes at offset %v is out of order or past the length %v",
+				len(p), off, size)
+		}
+		st.zeros(off - st.n)
+		st.write(p)
+		return nil
+	})
+	if err != nil {
+		return Checksum{}, err
+	}
+	st.zeros(size - st.n)
+	return st.checksum(), nil
+}
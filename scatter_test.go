@@ -0,0 +1,89 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test that the checksum of scattered segments is that of the data they make up, with zeros in the gaps
+func TestSumScatter(t *testing.T) {
+	data := randomBytes(10000)
+	list := ScatterList{
+		{Offset: 0, Data: data[0:3]},
+		{Offset: 3, Data: data[3:4097]},
+		{Offset: 5000, Data: data[5000:5001]},
+		{Offset: 6001, Data: data[6001:10000]},
+	}
+	linear := make([]byte, len(data))
+	for _, seg := range list {
+		copy(linear[seg.Offset:], seg.Data)
+	}
+	sum, err := SumScatter(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := paddedChecksum(linear); sum != exp {
+		t.Errorf("SumScatter:\nexpected\t%x,\ngot\t\t%x", exp, sum)
+	}
+
+	if sum, err := SumScatter(ScatterList{}); err != nil || sum != (Checksum{}) {
+		t.Errorf("SumScatter of no segments returned %v, %v", sum, err)
+	}
+
+	overlapping := ScatterList{{Offset: 0, Data: data[:100]}, {Offset: 50, Data: data[50:200]}}
+	if _, err := SumScatter(overlapping); err == nil {
+		t.Error("SumScatter of overlapping segments succeeded")
+	}
+}
+
+// sized is a Scatter longer than its segments, failing after a number of them
+type sized struct {
+	ScatterList
+	size int64
+	fail int
+}
+
+func (s sized) Len() int64 { return s.size }
+
+func (s sized) Segments(fn func(off int64, p []byte) error) error {
+	for i, seg := range s.ScatterList {
+		if i == s.fail {
+			return errFailingScatter
+		}
+		if err := fn(seg.Offset, seg.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errFailingScatter = errors.New("failing scatter")
+
+// Test that the zero bytes after the last segment are hashed, and that errors iterating are returned
+func TestSumScatterSized(t *testing.T) {
+	data := randomBytes(1000)
+	s := sized{ScatterList: ScatterList{{Offset: 10, Data: data[10:500]}}, size: 1000, fail: -1}
+	linear := make([]byte, 1000)
+	copy(linear[10:], data[10:500])
+	if sum, err := SumScatter(s); err != nil || sum != paddedChecksum(linear) {
+		t.Errorf("SumScatter of sized scatter returned %x, %v", sum, err)
+	}
+	s.fail = 0
+	if _, err := SumScatter(s); err != errFailingScatter {
+		t.Errorf("SumScatter of failing scatter returned %v", err)
+	}
+}
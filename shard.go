@@ -0,0 +1,35 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+// Shard returns the shard in [0, n) data with checksum c is routed to. All four words are mixed into a key, so
+// similar data spreads evenly instead of clustering like the low bits of the first word would, and the key is
+// mapped to a shard by jump consistent hashing: when n grows to n+1, only the data moving to the new shard
+// changes shards, about 1/(n+1) of it. The result depends on nothing but c and n, so it is the same across
+// processes and releases. n must be positive.
+func Shard(c Checksum, n int) int {
+	if n <= 0 {
+		panic("Number of shards given to Shard must be positive.")
+	}
+	key := mix64(c[0] ^ mix64(c[1]^mix64(c[2]^mix64(c[3]))))
+	// Lamping and Veach, "A Fast, Minimal Memory, Consistent Hash Algorithm"
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,53 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Test that similar blocks spread evenly over the shards, and that adding a shard only moves blocks to it
+func TestShard(t *testing.T) {
+	const blocks, n = 20000, 10
+	block := make([]byte, 512)
+	counts := make([]int, n+1)
+	for i := 0; i < blocks; i++ {
+		binary.LittleEndian.PutUint32(block, uint32(i))
+		c := Sum(block)
+		s := Shard(c, n)
+		if s < 0 || s >= n {
+			t.Fatalf("Shard returned %v of %v", s, n)
+		}
+		counts[s]++
+		if grown := Shard(c, n+1); grown != s && grown != n {
+			t.Errorf("Adding a shard moved a block from %v to %v", s, grown)
+		}
+		if grown := Shard(c, n+1); grown == n {
+			counts[n]++
+		}
+	}
+	for s, count := range counts[:n] {
+		if count < blocks/n*9/10 || count > blocks/n*11/10 {
+			t.Errorf("Shard %v got %v of %v blocks", s, count, blocks)
+		}
+	}
+	if moved := counts[n]; moved < blocks/(n+1)*8/10 || moved > blocks/(n+1)*12/10 {
+		t.Errorf("Adding a shard moved %v of %v blocks", moved, blocks)
+	}
+	if s := Shard(Checksum{}, 1); s != 0 {
+		t.Errorf("Shard of one returned %v", s)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || freebsd
+
+package fletcher4
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dataSegments returns the regions of the first size bytes of f holding data, everything between them being
+// holes reading as zeros. Returns errNoSparse if the file system cannot report holes. The file offset of f is
+// left unchanged.
+func dataSegments(f *os.File, size int64) (segs []segment, err error) {
+	fd := int(f.Fd())
+	pos, err := unix.Seek(fd, 0, io.SeekCurrent)
+	if err != nil {
+		return nil, errNoSparse
+	}
+	defer func() {
+		if _, serr := unix.Seek(fd, pos, io.SeekStart); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+
+	for off := int64(0); off < size; {
+		data, err := unix.Seek(fd, off, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// Only a hole is left
+			break
+		}
+		if err != nil {
+			return nil, errNoSparse
+		}
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, errNoSparse
+		}
+		if data >= size {
+			break
+		}
+		if hole > size {
+			hole = size
+		}
+		segs = append(segs, segment{off: data, n: hole - data})
+		off = hole
+	}
+	return segs, nil
+}
@@ -0,0 +1,155 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.solidsystem.no/fletcher4/internal/words"
+)
+
+// DefaultChunkSize is the size of the buffer readers and files are hashed through, unless set with
+// WithChunkSize. Small enough to stay in the L2 cache of common cpus between reading and hashing, and large
+// enough to amortize the cost of each read.
+const DefaultChunkSize = 256 << 10
+
+// Buffers of DefaultChunkSize bytes, for hashing where no options are given
+var chunkPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, DefaultChunkSize)
+		return &buf
+	},
+}
+
+// Option configures the reader, file, copy and frame helpers. Options not applying to a helper are ignored by it.
+type Option func(*options)
+
+type options struct {
+	chunkSize    int
+	maxFrameSize int
+	cumulative   bool
+	direct       bool
+	progress     func(Progress)
+	limiter      Limiter
+}
+
+// WithChunkSize sets the size of the buffer data is read into before it is hashed. Chunks resident in the cache
+// suit streaming, while larger ones reduce the number of reads in DRAM bound bulk scans. The size is rounded up
+// to a multiple of BlockSize, zero or negative values select DefaultChunkSize.
+func WithChunkSize(n int) Option {
+	return func(o *options) {
+		o.chunkSize = n
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{chunkSize: DefaultChunkSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.chunkSize <= 0 {
+		o.chunkSize = DefaultChunkSize
+	}
+	if o.maxFrameSize <= 0 {
+		o.maxFrameSize = DefaultMaxFrameSize
+	}
+	o.chunkSize = (o.chunkSize + BlockSize - 1) &^ (BlockSize - 1)
+	return o
+}
+
+// chunkSizeFor returns the chunk size to use for a source of size bytes, or the configured one if the size is
+// negative, meaning unknown. Sources smaller than a chunk get a buffer just big enough, plus room to see EOF.
+func (o *options) chunkSizeFor(size int64) int {
+	if size >= 0 && size < int64(o.chunkSize) {
+		return int(size+BlockSize) &^ (BlockSize - 1)
+	}
+	return o.chunkSize
+}
+
+// stream feeds data of any length into a digest. The digest only accepts whole words, so a trailing partial
+// word is kept back until more data arrives. When the checksum is computed, it is padded with zero bytes.
+type stream struct {
+	dig     digest
+	partial words.Partial
+	// Number of bytes written
+	n int64
+}
+
+func (s *stream) write(p []byte) {
+	s.n += int64(len(p))
+	s.partial.Write(p, s.add)
+}
+
+// add adds p, a whole number of words long, to the digest.
+func (s *stream) add(p []byte) {
+	s.dig = update(s.dig, p)
+}
+
+// zeros adds n zero bytes to the stream without reading them. A run of zero words leaves the first sum unchanged,
+// so it folds into the others in closed form, the same way combine appends a buffer with an all zero checksum.
+func (s *stream) zeros(n int64) {
+	var zero [BlockSize]byte
+	if s.partial.N > 0 {
+		take := int64(BlockSize - s.partial.N)
+		if take > n {
+			take = n
+		}
+		s.write(zero[:take])
+		n -= take
+	}
+	whole := n &^ (BlockSize - 1)
+	s.dig = combine(s.dig, uint64(whole), digest{})
+	s.n += whole
+	s.write(zero[:n-whole])
+}
+
+// checksum returns the checksum of everything written so far, without changing the state of the stream.
+func (s *stream) checksum() Checksum {
+	last, ok := s.partial.Padded()
+	if !ok {
+		return Checksum(s.dig)
+	}
+	return Checksum(updateScalar(s.dig, last[:]))
+}
+
+// readFrom hashes everything read from r until EOF, reading into buf. It returns the number of bytes read and
+// the first error other than EOF. ctx is checked before each read, and its error returned if it is done.
+func (s *stream) readFrom(ctx context.Context, r io.Reader, buf []byte) (int64, error) {
+	return readChunks(ctx, r, buf, s.write)
+}
+
+// readChunks reads r into buf until EOF, and calls fn with the data of each read. It returns the number of bytes
+// read and the first error other than EOF. ctx is checked before each read, and its error returned if it is done.
+func readChunks(ctx context.Context, r io.Reader, buf []byte, fn func(p []byte)) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			fn(buf[:n])
+			total += int64(n)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
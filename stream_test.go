@@ -0,0 +1,105 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/iotest"
+)
+
+// paddedChecksum returns the checksum of p padded with zero bytes to a whole number of words
+func paddedChecksum(p []byte) Checksum {
+	padded := make([]byte, (len(p)+BlockSize-1)&^(BlockSize-1))
+	copy(padded, p)
+	return Checksum(updateScalar(digest{}, padded))
+}
+
+// Test that writes of any length give the checksum of the data padded to whole words
+func TestStreamUnalignedWrites(t *testing.T) {
+	data := randomBytes(1027)
+	exp := paddedChecksum(data)
+	for _, step := range []int{1, 2, 3, 5, 7, 64, 1000} {
+		var s stream
+		for i := 0; i < len(data); i += step {
+			end := i + step
+			if end > len(data) {
+				end = len(data)
+			}
+			s.write(data[i:end])
+		}
+		if got := s.checksum(); got != exp {
+			t.Errorf("Writes of %v bytes:\nexpected\t%x,\ngot\t\t%x", step, exp, got)
+		}
+		if s.n != int64(len(data)) {
+			t.Errorf("Writes of %v bytes counted %v bytes, expected %v", step, s.n, len(data))
+		}
+	}
+}
+
+// Test that adding zero runs in closed form matches writing the zero bytes, at any alignment
+func TestStreamZeros(t *testing.T) {
+	for _, prefix := range []int{0, 1, 3, 4, 13} {
+		for _, zeros := range []int{0, 1, 2, 3, 4, 5, 1000, 1003} {
+			data := append(randomBytes(prefix), make([]byte, zeros)...)
+			data = append(data, 0xab)
+			var s stream
+			s.write(data[:prefix])
+			s.zeros(int64(zeros))
+			s.write(data[prefix+zeros:])
+			if exp, got := paddedChecksum(data), s.checksum(); got != exp {
+				t.Errorf("%v zeros after %v bytes:\nexpected\t%x,\ngot\t\t%x", zeros, prefix, exp, got)
+			}
+			if s.n != int64(len(data)) {
+				t.Errorf("%v zeros after %v bytes counted %v bytes, expected %v", zeros, prefix, s.n, len(data))
+			}
+		}
+	}
+}
+
+// Test that reading through small and odd sized reads gives the same checksum
+func TestStreamReadFrom(t *testing.T) {
+	data := randomBytes(10001)
+	exp := paddedChecksum(data)
+	var s stream
+	n, err := s.readFrom(context.Background(), iotest.OneByteReader(bytes.NewReader(data)), make([]byte, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("Read %v bytes, expected %v", n, len(data))
+	}
+	if got := s.checksum(); got != exp {
+		t.Errorf("ReadFrom:\nexpected\t%x,\ngot\t\t%x", exp, got)
+	}
+}
+
+// Test that the chunk size is rounded up to whole words, and reduced for small sources
+func TestChunkSize(t *testing.T) {
+	o := newOptions([]Option{WithChunkSize(1001)})
+	if o.chunkSize != 1004 {
+		t.Errorf("Chunk size 1001 was rounded to %v, expected 1004", o.chunkSize)
+	}
+	if got := o.chunkSizeFor(10); got != 12 {
+		t.Errorf("Chunk size for 10 byte source was %v, expected 12", got)
+	}
+	if got := o.chunkSizeFor(-1); got != 1004 {
+		t.Errorf("Chunk size for source of unknown size was %v, expected 1004", got)
+	}
+	if o := newOptions(nil); o.chunkSize != DefaultChunkSize {
+		t.Errorf("Default chunk size was %v, expected %v", o.chunkSize, DefaultChunkSize)
+	}
+}
@@ -0,0 +1,30 @@
+// Code generated by command: go run main.go -out ../fletcher4_amd64.s -stubs ../stub_amd64.go -pkg fletcher4. DO NOT EDIT.
+
+//go:build !purego
+
+package fletcher4
+
+// fletcherSSE2 processes all of p, which must be a multiple of 8 bytes long, and stores the lane sums starting from zero in lanes.
+//
+//go:noescape
+func fletcherSSE2(lanes *[4][2]uint64, p []byte)
+
+// fletcherAVX2 processes all of p, which must be a multiple of 16 bytes long, and stores the lane sums starting from zero in lanes.
+//
+//go:noescape
+func fletcherAVX2(lanes *[4][4]uint64, p []byte)
+
+// fletcherAVX512F processes all of p, which must be a multiple of 32 bytes long, and stores the lane sums starting from zero in lanes.
+//
+//go:noescape
+func fletcherAVX512F(lanes *[4][8]uint64, p []byte)
+
+// fletcherMultiAVX2 processes the first n bytes of every buffer in bufs, n being a multiple of 16, and stores their checksums starting from zero in sums.
+//
+//go:noescape
+func fletcherMultiAVX2(sums *[4][4]uint64, bufs *[4][]byte, n int)
+
+// fletcherMultiAVX512F processes the first n bytes of every buffer in bufs, n being a multiple of 16, and stores their checksums starting from zero in sums.
+//
+//go:noescape
+func fletcherMultiAVX512F(sums *[4][8]uint64, bufs *[8][]byte, n int)
@@ -0,0 +1,56 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import "encoding/binary"
+
+// Add p to the running checksum d using 4 independent lanes, like fletcher_4_superscalar4 in OpenZFS.
+// The serial dependency between the sums only exists within each lane, so an out of order cpu can work
+// on all 4 lanes at once.
+func updateSuperscalar4(dig digest, p []byte) digest {
+	n := len(p) &^ (4*BlockSize - 1)
+	if n > 0 {
+		var a0, a1, a2, a3, b0, b1, b2, b3, c0, c1, c2, c3, d0, d1, d2, d3 uint64
+		for q := p[:n]; len(q) >= 4*BlockSize; q = q[4*BlockSize:] {
+			// One 8 byte load feeds two lanes
+			lo := binary.LittleEndian.Uint64(q[0:8])
+			hi := binary.LittleEndian.Uint64(q[8:16])
+			a0 += lo & 0xffffffff
+			a1 += lo >> 32
+			a2 += hi & 0xffffffff
+			a3 += hi >> 32
+			b0 += a0
+			b1 += a1
+			b2 += a2
+			b3 += a3
+			c0 += b0
+			c1 += b1
+			c2 += b2
+			c3 += b3
+			d0 += c0
+			d1 += c1
+			d2 += c2
+			d3 += c3
+		}
+		lanes := finiLanes(
+			[]uint64{a0, a1, a2, a3},
+			[]uint64{b0, b1, b2, b3},
+			[]uint64{c0, c1, c2, c3},
+			[]uint64{d0, d1, d2, d3},
+		)
+		dig = combine(dig, uint64(n), lanes)
+	}
+	return updateScalar(dig, p[n:])
+}
@@ -0,0 +1,139 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Size of the buffer a VerifiedReader reads the underlying reader through
+const verifiedReaderBuffer = 32 << 10
+
+// VerifiedReader reads a payload followed by a trailer of Size bytes holding the checksum of the payload,
+// serialized as Sum does. Only the payload is returned by Read. When the underlying reader reaches EOF the
+// trailer is verified, and a *MismatchError returned instead of io.EOF if it does not match.
+//
+// The last Size bytes read are always held back, as they might be the trailer. Data returned by Read is not
+// verified until EOF is reached, so consumers must not act on it until then.
+type VerifiedReader struct {
+	r   io.Reader
+	s   stream
+	buf []byte
+	// Data read from r but not returned is buf[off:end]
+	off, end int
+	eof      bool
+	// Final error, returned by all reads after EOF
+	err error
+}
+
+// NewVerifiedReader returns a VerifiedReader reading a payload and its checksum trailer from r.
+func NewVerifiedReader(r io.Reader) *VerifiedReader {
+	return &VerifiedReader{r: r, buf: make([]byte, verifiedReaderBuffer)}
+}
+
+func (v *VerifiedReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for v.end-v.off <= Size && !v.eof {
+		// At most Size bytes are kept, move them to the front to make room
+		v.end = copy(v.buf, v.buf[v.off:v.end])
+		v.off = 0
+		n, err := v.r.Read(v.buf[v.end:])
+		v.end += n
+		if err == io.EOF {
+			v.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if payload := v.end - v.off - Size; payload > 0 {
+		n := copy(p, v.buf[v.off:v.off+payload])
+		v.s.write(p[:n])
+		v.off += n
+		return n, nil
+	}
+
+	v.err = v.verify()
+	return 0, v.err
+}
+
+// verify checks the trailer once EOF is reached, returning io.EOF if it matches.
+func (v *VerifiedReader) verify() error {
+	if v.end-v.off < Size {
+		return fmt.Errorf("fletcher4: data ended before the %v byte checksum trailer: %w", Size, io.ErrUnexpectedEOF)
+	}
+	var expected Checksum
+	if err := expected.UnmarshalBinary(v.buf[v.off:v.end]); err != nil {
+		return err
+	}
+	if actual := v.s.checksum(); actual != expected {
+		return &MismatchError{Expected: expected, Actual: actual}
+	}
+	return io.EOF
+}
+
+// Returned by writes to a closed TrailerWriter
+var errTrailerWriterClosed = errors.New("fletcher4: write to closed TrailerWriter")
+
+// TrailerWriter forwards writes to an underlying writer, and appends the checksum of everything written as a
+// trailer of Size bytes when closed. This produces the format read by VerifiedReader.
+type TrailerWriter struct {
+	w      io.WriteCloser
+	s      stream
+	closed bool
+}
+
+// NewTrailerWriter returns a TrailerWriter forwarding to w.
+func NewTrailerWriter(w io.WriteCloser) *TrailerWriter {
+	return &TrailerWriter{w: w}
+}
+
+// Write writes p to the underlying writer, and adds the bytes it accepted to the checksum.
+func (t *TrailerWriter) Write(p []byte) (int, error) {
+	if t.closed {
+		return 0, errTrailerWriterClosed
+	}
+	n, err := t.w.Write(p)
+	t.s.write(p[:n])
+	return n, err
+}
+
+// Close writes the checksum trailer and closes the underlying writer. The underlying writer is closed even if
+// writing the trailer fails.
+func (t *TrailerWriter) Close() error {
+	if t.closed {
+		return errTrailerWriterClosed
+	}
+	t.closed = true
+	trailer, _ := t.s.checksum().MarshalBinary()
+	_, err := t.w.Write(trailer)
+	if cerr := t.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Checksum returns the checksum of everything written so far, which is the trailer once closed.
+func (t *TrailerWriter) Checksum() Checksum {
+	return t.s.checksum()
+}
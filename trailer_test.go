@@ -0,0 +1,93 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// withTrailer returns payload followed by its checksum
+func withTrailer(payload []byte) []byte {
+	sum, _ := paddedChecksum(payload).MarshalBinary()
+	return append(append([]byte{}, payload...), sum...)
+}
+
+// Test that VerifiedReader returns the payload without the trailer when it matches
+func TestVerifiedReader(t *testing.T) {
+	for _, size := range []int{0, 1, 31, 32, 33, 100003} {
+		payload := randomBytes(size)
+		got, err := io.ReadAll(NewVerifiedReader(iotest.HalfReader(bytes.NewReader(withTrailer(payload)))))
+		if err != nil {
+			t.Fatalf("VerifiedReader of %v bytes failed: %v", size, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("VerifiedReader of %v bytes returned %v bytes, not the payload", size, len(got))
+		}
+	}
+}
+
+// Test that VerifiedReader detects corrupt and truncated data
+func TestVerifiedReaderMismatch(t *testing.T) {
+	blob := withTrailer(randomBytes(1000))
+	blob[500] ^= 1
+	_, err := io.ReadAll(NewVerifiedReader(bytes.NewReader(blob)))
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifiedReader of corrupt data returned error %v", err)
+	}
+
+	_, err = io.ReadAll(NewVerifiedReader(bytes.NewReader(blob[:20])))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("VerifiedReader of truncated data returned error %v", err)
+	}
+}
+
+// nopWriteCloser adds a Close counting calls to a writer
+type nopWriteCloser struct {
+	io.Writer
+	closes int
+}
+
+func (n *nopWriteCloser) Close() error {
+	n.closes++
+	return nil
+}
+
+// Test that TrailerWriter produces data VerifiedReader accepts
+func TestTrailerWriter(t *testing.T) {
+	payload := randomBytes(12345)
+	var out bytes.Buffer
+	wc := &nopWriteCloser{Writer: &out}
+	w := NewTrailerWriter(wc)
+	if _, err := io.Copy(w, bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if wc.closes != 1 {
+		t.Errorf("Underlying writer closed %v times, expected once", wc.closes)
+	}
+	if !bytes.Equal(out.Bytes(), withTrailer(payload)) {
+		t.Error("TrailerWriter output is not the payload followed by its checksum")
+	}
+	if _, err := w.Write(payload); err == nil {
+		t.Error("Write after Close did not fail")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// FileSum is the checksum of one file, as found by WalkFS.
+type FileSum struct {
+	// Slash separated path within the walked file system
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Checksum Checksum
+}
+
+// WalkFS walks the file tree of fsys rooted at root in lexical order, like fs.WalkDir, and calls fn with the
+// checksum of every regular file. Other entries such as directories and symlinks are skipped. This works the
+// same for os.DirFS, embed.FS, zip readers and any other fs.FS.
+//
+// If a file cannot be hashed, fn is called with the error and as much of the FileSum as is known. Walking stops
+// if fn returns an error, which WalkFS then returns.
+func WalkFS(fsys fs.FS, root string, fn func(sum FileSum, err error) error, opts ...Option) error {
+	o := newOptions(opts)
+	return fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(FileSum{Path: path}, err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		sum, err := sumFSFile(fsys, path, o)
+		return fn(sum, err)
+	})
+}
+
+// SumFS returns the checksums of all regular files in fsys below root, see WalkFS. It stops at the first error.
+func SumFS(fsys fs.FS, root string, opts ...Option) ([]FileSum, error) {
+	var sums []FileSum
+	err := WalkFS(fsys, root, func(sum FileSum, err error) error {
+		if err != nil {
+			return err
+		}
+		sums = append(sums, sum)
+		return nil
+	}, opts...)
+	return sums, err
+}
+
+// SumFSFile returns the checksum of the single file at path in fsys, along with its size and modification time.
+func SumFSFile(fsys fs.FS, path string, opts ...Option) (FileSum, error) {
+	return sumFSFile(fsys, path, newOptions(opts))
+}
+
+// sumFSFile hashes the file at path in fsys. Files of the operating system are hashed like SumFile does.
+func sumFSFile(fsys fs.FS, path string, o options) (FileSum, error) {
+	res := FileSum{Path: path}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return res, err
+	}
+	res.ModTime = fi.ModTime()
+
+	if osFile, ok := f.(*os.File); ok {
+		res.Checksum, res.Size, err = sumOpenFile(osFile, o)
+		return res, err
+	}
+	m := o.newMeter(fi.Size())
+	defer m.finish()
+	var s stream
+	res.Size, err = s.readFrom(context.Background(), m.reader(context.Background(), f), make([]byte, o.chunkSizeFor(fi.Size())))
+	res.Checksum = s.checksum()
+	return res, err
+}
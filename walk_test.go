@@ -0,0 +1,72 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test that SumFS hashes every regular file of an in memory file system in lexical order
+func TestSumFS(t *testing.T) {
+	mtime := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"b.txt":     {Data: []byte("hello world"), ModTime: mtime},
+		"a/one.bin": {Data: randomBytes(1000), ModTime: mtime},
+		"a/two.bin": {Data: randomBytes(0), ModTime: mtime},
+	}
+	sums, err := SumFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"a/one.bin", "a/two.bin", "b.txt"}
+	if len(sums) != len(paths) {
+		t.Fatalf("SumFS returned %v files, expected %v", len(sums), len(paths))
+	}
+	for i, sum := range sums {
+		file := fsys[paths[i]]
+		if sum.Path != paths[i] || sum.Size != int64(len(file.Data)) || !sum.ModTime.Equal(mtime) {
+			t.Errorf("SumFS entry %v is %+v", i, sum)
+		}
+		if exp := paddedChecksum(file.Data); sum.Checksum != exp {
+			t.Errorf("SumFS %v:\nexpected\t%x,\ngot\t\t%x", sum.Path, exp, sum.Checksum)
+		}
+	}
+}
+
+// Test that SumFS hashes files of the operating system, skipping symlinks
+func TestSumFSDir(t *testing.T) {
+	dir := t.TempDir()
+	data := randomBytes(mmapThreshold + 1)
+	if err := os.WriteFile(filepath.Join(dir, "big"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("big", filepath.Join(dir, "link")); err != nil {
+		t.Skip("Symlinks not supported:", err)
+	}
+	sums, err := SumFS(os.DirFS(dir), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 1 || sums[0].Path != "big" {
+		t.Fatalf("SumFS returned %+v, expected only big", sums)
+	}
+	if exp := paddedChecksum(data); sums[0].Checksum != exp {
+		t.Errorf("SumFS big:\nexpected\t%x,\ngot\t\t%x", exp, sums[0].Checksum)
+	}
+}
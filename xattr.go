@@ -0,0 +1,151 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XattrName is the extended attribute StoreXattr keeps the checksum of a file in. Its value is the checksum
+// formatted like Checksum.String, a space and the modification time of the file when hashed, in nanoseconds
+// since the Unix epoch.
+const XattrName = "user.fletcher4"
+
+// ErrNoXattr is returned when a file has no stored checksum.
+var ErrNoXattr = errors.New("fletcher4: no checksum stored in extended attribute")
+
+// ErrXattrUnsupported is returned by the extended attribute helpers on platforms without support for them.
+var ErrXattrUnsupported = errors.New("fletcher4: extended attributes not supported on this platform")
+
+// StoredSum is the checksum kept in the extended attribute of a file, and the modification time the file had
+// when it was hashed.
+type StoredSum struct {
+	Checksum Checksum
+	ModTime  time.Time
+}
+
+func (s StoredSum) format() []byte {
+	return []byte(fmt.Sprintf("%v %v", s.Checksum, s.ModTime.UnixNano()))
+}
+
+func parseStoredSum(value []byte) (StoredSum, error) {
+	var s StoredSum
+	sum, ns, ok := strings.Cut(string(value), " ")
+	if !ok {
+		return s, fmt.Errorf("fletcher4: invalid extended attribute value %q", value)
+	}
+	var err error
+	if s.Checksum, err = ParseChecksum(sum); err != nil {
+		return s, err
+	}
+	nanos, err := strconv.ParseInt(ns, 10, 64)
+	if err != nil {
+		return s, fmt.Errorf("fletcher4: invalid modification time in extended attribute value %q", value)
+	}
+	s.ModTime = time.Unix(0, nanos)
+	return s, nil
+}
+
+// StoreXattr hashes the file at path and stores the checksum together with its modification time in the
+// XattrName extended attribute of the file, so it can later be verified against itself with VerifyXattr.
+// The modification time is read before hashing, so a file changed meanwhile is seen as modified, not corrupt.
+func StoreXattr(path string, opts ...Option) (StoredSum, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return StoredSum{}, err
+	}
+	sum, _, err := SumFile(path, opts...)
+	if err != nil {
+		return StoredSum{}, err
+	}
+	stored := StoredSum{Checksum: sum, ModTime: fi.ModTime()}
+	if err := setxattr(path, XattrName, stored.format()); err != nil {
+		return StoredSum{}, fmt.Errorf("fletcher4: storing checksum of %v: %w", path, err)
+	}
+	return stored, nil
+}
+
+// ReadXattr returns the checksum stored in the extended attribute of the file at path, or ErrNoXattr if there
+// is none.
+func ReadXattr(path string) (StoredSum, error) {
+	value, err := getxattr(path, XattrName)
+	if err != nil {
+		return StoredSum{}, err
+	}
+	return parseStoredSum(value)
+}
+
+// XattrStatus is the outcome of verifying a file against its stored checksum.
+type XattrStatus int
+
+const (
+	// The file matches its stored checksum
+	XattrOK XattrStatus = iota
+	// The file was modified after its checksum was stored, so the checksum is outdated
+	XattrModified
+	// The file has the stored modification time but a different checksum, its data has silently changed
+	XattrCorrupt
+)
+
+func (s XattrStatus) String() string {
+	switch s {
+	case XattrOK:
+		return "ok"
+	case XattrModified:
+		return "modified"
+	case XattrCorrupt:
+		return "corrupt"
+	}
+	return fmt.Sprintf("XattrStatus(%d)", int(s))
+}
+
+// XattrResult is the outcome of VerifyXattr.
+type XattrResult struct {
+	Status XattrStatus
+	Stored StoredSum
+	// Checksum and modification time of the file now
+	Actual StoredSum
+}
+
+// VerifyXattr hashes the file at path and compares it to the checksum stored in its extended attribute.
+// It returns ErrNoXattr if the file has no stored checksum.
+func VerifyXattr(path string, opts ...Option) (*XattrResult, error) {
+	stored, err := ReadXattr(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	sum, _, err := SumFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &XattrResult{Stored: stored, Actual: StoredSum{Checksum: sum, ModTime: fi.ModTime()}}
+	switch {
+	case !stored.ModTime.Equal(fi.ModTime()):
+		res.Status = XattrModified
+	case stored.Checksum != sum:
+		res.Status = XattrCorrupt
+	}
+	return res, nil
+}
@@ -0,0 +1,50 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || freebsd || netbsd
+
+package fletcher4
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// Large enough for the values stored by StoreXattr
+const xattrBuffer = 256
+
+func getxattr(path, name string) ([]byte, error) {
+	buf := make([]byte, xattrBuffer)
+	n, err := unix.Getxattr(path, name, buf)
+	if errors.Is(err, unix.ENOATTR) {
+		return nil, ErrNoXattr
+	}
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	return buf[:n], nil
+}
+
+func setxattr(path, name string, value []byte) error {
+	return xattrError(unix.Setxattr(path, name, value, 0))
+}
+
+// xattrError reports file systems without extended attributes as ErrXattrUnsupported
+func xattrError(err error) error {
+	if errors.Is(err, unix.ENOTSUP) {
+		return ErrXattrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fletcher4
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// storeTestXattr stores the checksum of the file at path, skipping the test where the file system does not
+// support user extended attributes
+func storeTestXattr(t *testing.T, path string) StoredSum {
+	t.Helper()
+	stored, err := StoreXattr(path)
+	if errors.Is(err, ErrXattrUnsupported) {
+		t.Skipf("Extended attributes not supported: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// Test storing, reading and verifying the checksum of an unchanged file
+func TestXattr(t *testing.T) {
+	data := randomBytes(1000)
+	path := writeTestFile(t, data)
+	stored := storeTestXattr(t, path)
+	if stored.Checksum != paddedChecksum(data) {
+		t.Errorf("StoreXattr stored %v, expected %v", stored.Checksum, paddedChecksum(data))
+	}
+
+	read, err := ReadXattr(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Checksum != stored.Checksum || !read.ModTime.Equal(stored.ModTime) {
+		t.Errorf("ReadXattr returned %+v, expected %+v", read, stored)
+	}
+
+	res, err := VerifyXattr(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != XattrOK {
+		t.Errorf("VerifyXattr of unchanged file returned %v", res.Status)
+	}
+}
+
+// Test that VerifyXattr tells a modified file from one whose data changed without its modification time
+func TestVerifyXattrChanged(t *testing.T) {
+	data := randomBytes(1000)
+	path := writeTestFile(t, data)
+	stored := storeTestXattr(t, path)
+
+	data[10] ^= 1
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), stored.ModTime); err != nil {
+		t.Fatal(err)
+	}
+	res, err := VerifyXattr(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != XattrCorrupt {
+		t.Errorf("VerifyXattr of silently changed file returned %v", res.Status)
+	}
+
+	if err := os.Chtimes(path, time.Now(), stored.ModTime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = VerifyXattr(path); err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != XattrModified {
+		t.Errorf("VerifyXattr of modified file returned %v", res.Status)
+	}
+}
+
+// Test that files without a stored checksum return ErrNoXattr
+func TestReadXattrMissing(t *testing.T) {
+	path := writeTestFile(t, nil)
+	storeTestXattr(t, writeTestFile(t, nil))
+	if _, err := ReadXattr(path); !errors.Is(err, ErrNoXattr) {
+		t.Errorf("ReadXattr of file without checksum returned %v", err)
+	}
+}
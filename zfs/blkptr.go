@@ -0,0 +1,153 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// BlockPointerSize is the size of an on-disk blkptr_t.
+const BlockPointerSize = 128
+
+// ChecksumType is the checksum algorithm of a block, the zio_checksum enum.
+type ChecksumType uint8
+
+// Checksum algorithms, in the order of the zio_checksum enum
+const (
+	ChecksumInherit ChecksumType = iota
+	ChecksumOn
+	ChecksumOff
+	ChecksumLabel
+	ChecksumGangHeader
+	ChecksumZilog
+	ChecksumFletcher2
+	ChecksumFletcher4
+	ChecksumSHA256
+	ChecksumZilog2
+	ChecksumNoParity
+	ChecksumSHA512
+	ChecksumSkein
+	ChecksumEdonR
+	ChecksumBLAKE3
+)
+
+var checksumTypeNames = [...]string{"inherit", "on", "off", "label", "gang_header", "zilog", "fletcher2",
+	"fletcher4", "sha256", "zilog2", "noparity", "sha512", "skein", "edonr", "blake3"}
+
+// String returns the name zfs uses for the algorithm, e.g. "fletcher4".
+func (t ChecksumType) String() string {
+	if int(t) < len(checksumTypeNames) {
+		return checksumTypeNames[t]
+	}
+	return fmt.Sprintf("ChecksumType(%d)", uint8(t))
+}
+
+// ErrUnsupportedChecksum is returned when verifying blocks checksummed with another algorithm than fletcher4.
+var ErrUnsupportedChecksum = errors.New("zfs: block is not checksummed with fletcher4")
+
+// BlockPointer is a decoded blkptr_t, read from its little endian on-disk form.
+type BlockPointer struct {
+	// Data virtual addresses of the copies of the block, two words each
+	DVA [3][2]uint64
+	// blk_prop, holding the sizes, algorithms, type and flags decoded by the methods
+	Prop      uint64
+	PhysBirth uint64
+	Birth     uint64
+	Fill      uint64
+	Checksum  ZioCksum
+}
+
+// DecodeBlockPointer decodes the BlockPointerSize bytes of an on-disk block pointer.
+func DecodeBlockPointer(b []byte) (*BlockPointer, error) {
+	if len(b) != BlockPointerSize {
+		return nil, fmt.Errorf("zfs: block pointer must be %v bytes, got %v", BlockPointerSize, len(b))
+	}
+	u64 := func(i int) uint64 { return binary.LittleEndian.Uint64(b[8*i:]) }
+	bp := &BlockPointer{Prop: u64(6), PhysBirth: u64(9), Birth: u64(10), Fill: u64(11)}
+	for i := range bp.DVA {
+		bp.DVA[i] = [2]uint64{u64(2 * i), u64(2*i + 1)}
+	}
+	bp.Checksum, _ = DecodeZioCksum(b[96:], binary.LittleEndian)
+	return bp, nil
+}
+
+// prop returns bits [shift, shift+bits) of blk_prop.
+func (bp *BlockPointer) prop(shift, bits uint) uint64 {
+	return bp.Prop >> shift & (1<<bits - 1)
+}
+
+// LogicalSize returns the size of the block before compression.
+func (bp *BlockPointer) LogicalSize() int64 { return int64(bp.prop(0, 16)+1) << 9 }
+
+// PhysicalSize returns the size of the block as stored, which the checksum covers.
+func (bp *BlockPointer) PhysicalSize() int64 { return int64(bp.prop(16, 16)+1) << 9 }
+
+// Compression returns the compression algorithm, the zio_compress enum.
+func (bp *BlockPointer) Compression() uint8 { return uint8(bp.prop(32, 7)) }
+
+// Embedded reports whether the data is stored in the block pointer itself, which then holds no checksum.
+func (bp *BlockPointer) Embedded() bool { return bp.prop(39, 1) == 1 }
+
+// ChecksumType returns the checksum algorithm of the block.
+func (bp *BlockPointer) ChecksumType() ChecksumType { return ChecksumType(bp.prop(40, 8)) }
+
+// Type returns the object type of the block, the dmu_object_type enum.
+func (bp *BlockPointer) Type() uint8 { return uint8(bp.prop(48, 8)) }
+
+// Level returns the indirection level of the block, zero for data blocks.
+func (bp *BlockPointer) Level() int { return int(bp.prop(56, 5)) }
+
+// Encrypted reports whether the block is encrypted. Only the first two checksum words are then a checksum, the
+// others hold the MAC.
+func (bp *BlockPointer) Encrypted() bool { return bp.prop(61, 1) == 1 }
+
+// Dedup reports whether the block is deduplicated.
+func (bp *BlockPointer) Dedup() bool { return bp.prop(62, 1) == 1 }
+
+// LittleEndian reports whether the block was written by a little endian host.
+func (bp *BlockPointer) LittleEndian() bool { return bp.prop(63, 1) == 1 }
+
+// Verify computes the fletcher4 checksum of data, the block as read from disk, and compares it to the checksum
+// in the block pointer. Blocks written by a big endian host are checksummed as big endian words, like
+// zio_checksum_error does with fletcher_4_byteswap. It returns an error wrapping a *fletcher4.MismatchError if the
+// checksums differ, and ErrUnsupportedChecksum for blocks it cannot verify.
+func (bp *BlockPointer) Verify(data []byte) error {
+	if bp.Embedded() {
+		return errors.New("zfs: embedded block pointer has no checksum")
+	}
+	if t := bp.ChecksumType(); t != ChecksumFletcher4 {
+		return fmt.Errorf("%w: %v", ErrUnsupportedChecksum, t)
+	}
+	if int64(len(data)) != bp.PhysicalSize() {
+		return fmt.Errorf("zfs: block is %v bytes, block pointer says %v", len(data), bp.PhysicalSize())
+	}
+	actual := fletcher4.Sum(data)
+	if !bp.LittleEndian() {
+		actual = fletcher4.ChecksumByteswap(data)
+	}
+	expected := bp.Checksum.Checksum()
+	if bp.Encrypted() {
+		// The last two words are the MAC
+		actual[2], actual[3] = expected[2], expected[3]
+	}
+	if actual != expected {
+		return fmt.Errorf("zfs: block checksum: %w", &fletcher4.MismatchError{Expected: expected, Actual: actual})
+	}
+	return nil
+}
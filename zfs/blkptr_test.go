@@ -0,0 +1,110 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// encodeBlockPointer returns the on-disk form of a block pointer to data, with the given blk_prop flags
+func encodeBlockPointer(data []byte, sum fletcher4.Checksum, flags uint64) []byte {
+	b := make([]byte, BlockPointerSize)
+	sectors := uint64(len(data)>>9 - 1)
+	prop := flags | sectors | sectors<<16
+	binary.LittleEndian.PutUint64(b[0:], 0x1234)
+	binary.LittleEndian.PutUint64(b[48:], prop)
+	binary.LittleEndian.PutUint64(b[80:], 42)
+	copy(b[96:], marshal(sum))
+	return b
+}
+
+const (
+	fletcher4Prop = uint64(ChecksumFletcher4) << 40
+	littleEndian  = 1 << 63
+)
+
+// Test decoding the fields of a block pointer and verifying its block
+func TestBlockPointer(t *testing.T) {
+	data := testBlock(8192)
+	bp, err := DecodeBlockPointer(encodeBlockPointer(data, fletcher4.Sum(data), fletcher4Prop|littleEndian|3<<56))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bp.DVA[0][0] != 0x1234 || bp.Birth != 42 || bp.Level() != 3 || bp.ChecksumType() != ChecksumFletcher4 ||
+		bp.LogicalSize() != 8192 || bp.PhysicalSize() != 8192 || !bp.LittleEndian() || bp.Embedded() {
+		t.Errorf("Decoded block pointer %+v", bp)
+	}
+	if err := bp.Verify(data); err != nil {
+		t.Error(err)
+	}
+	data[10] ^= 1
+	if err := bp.Verify(data); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Verify of corrupt block returned %v", err)
+	}
+}
+
+// Test that only the checksum words of encrypted blocks are compared
+func TestBlockPointerEncrypted(t *testing.T) {
+	data := testBlock(512)
+	sum := fletcher4.Sum(data)
+	sum[2], sum[3] = 0xdead, 0xbeef
+	bp, err := DecodeBlockPointer(encodeBlockPointer(data, sum, fletcher4Prop|littleEndian|1<<61))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.Verify(data); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that blocks written by a big endian host are verified as big endian words
+func TestBlockPointerByteswapped(t *testing.T) {
+	data := testBlock(512)
+	swapped := bytes.Clone(data)
+	for i := 0; i < len(swapped); i += fletcher4.BlockSize {
+		binary.BigEndian.PutUint32(swapped[i:], binary.LittleEndian.Uint32(data[i:]))
+	}
+	// The checksum the big endian host computed natively over its words
+	bp, err := DecodeBlockPointer(encodeBlockPointer(swapped, fletcher4.Sum(data), fletcher4Prop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.Verify(swapped); err != nil {
+		t.Error(err)
+	}
+	if err := bp.Verify(data); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Verify of little endian words returned %v", err)
+	}
+}
+
+// Test that blocks of other algorithms are refused
+func TestBlockPointerUnsupported(t *testing.T) {
+	data := testBlock(512)
+	bp, err := DecodeBlockPointer(encodeBlockPointer(data, fletcher4.Sum(data), uint64(ChecksumSHA256)<<40|littleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.Verify(data); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("Verify of sha256 block returned %v", err)
+	}
+	if _, err := DecodeBlockPointer(make([]byte, 100)); err == nil {
+		t.Error("Decoding short block pointer succeeded")
+	}
+}
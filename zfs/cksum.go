@@ -0,0 +1,78 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// ZioCksum has the layout of zio_cksum_t, four 64 bit words, so it may be passed to C code through cgo as is.
+// The words of a fletcher4 checksum are the running sums a, b, c and d, the same as fletcher4.Checksum.
+type ZioCksum struct {
+	Word [4]uint64
+}
+
+// FromChecksum returns the zio_cksum_t holding sum.
+func FromChecksum(sum fletcher4.Checksum) ZioCksum {
+	return ZioCksum{Word: sum}
+}
+
+// Checksum returns the fletcher4 checksum held by z.
+func (z ZioCksum) Checksum() fletcher4.Checksum {
+	return fletcher4.Checksum(z.Word)
+}
+
+// IsZero reports whether all words are zero, which ZFS uses for no checksum.
+func (z ZioCksum) IsZero() bool {
+	return z == ZioCksum{}
+}
+
+// Byteswap returns z with the bytes of every word reversed, as read from data of the other byte order.
+func (z ZioCksum) Byteswap() ZioCksum {
+	for i, w := range z.Word {
+		z.Word[i] = bits.ReverseBytes64(w)
+	}
+	return z
+}
+
+// AppendBinary appends the 32 byte serialized form of z in byte order order to b, e.g. binary.LittleEndian for
+// data written by little endian hosts.
+func (z ZioCksum) AppendBinary(b []byte, order binary.AppendByteOrder) []byte {
+	for _, w := range z.Word {
+		b = order.AppendUint64(b, w)
+	}
+	return b
+}
+
+// DecodeZioCksum decodes the 32 byte serialized form of a zio_cksum_t in byte order order.
+func DecodeZioCksum(b []byte, order binary.ByteOrder) (ZioCksum, error) {
+	var z ZioCksum
+	if len(b) != fletcher4.Size {
+		return z, fmt.Errorf("zfs: zio_cksum_t must be %v bytes, got %v", fletcher4.Size, len(b))
+	}
+	for i := range z.Word {
+		z.Word[i] = order.Uint64(b[8*i:])
+	}
+	return z, nil
+}
+
+// String formats z like fletcher4.Checksum.String.
+func (z ZioCksum) String() string {
+	return z.Checksum().String()
+}
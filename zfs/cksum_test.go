@@ -0,0 +1,53 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that ZioCksum has the size of zio_cksum_t and round trips in both byte orders
+func TestZioCksum(t *testing.T) {
+	if size := unsafe.Sizeof(ZioCksum{}); size != 32 {
+		t.Errorf("ZioCksum is %v bytes", size)
+	}
+	z := FromChecksum(fletcher4.Checksum{1, 0x0102030405060708, 3, 4})
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		b := z.AppendBinary(nil, order.(binary.AppendByteOrder))
+		got, err := DecodeZioCksum(b, order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != z {
+			t.Errorf("Decoded %v from %v, expected %v", got, order, z)
+		}
+	}
+
+	big := z.AppendBinary(nil, binary.BigEndian)
+	little, _ := DecodeZioCksum(big, binary.LittleEndian)
+	if little.Byteswap() != z {
+		t.Errorf("Byteswap of %v read in the wrong order gave %v", little, little.Byteswap())
+	}
+	if z.IsZero() || !(ZioCksum{}).IsZero() {
+		t.Error("IsZero is wrong")
+	}
+	if _, err := DecodeZioCksum(big[:31], binary.LittleEndian); err == nil {
+		t.Error("Decoding 31 bytes succeeded")
+	}
+}
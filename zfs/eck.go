@@ -0,0 +1,135 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zfs handles the on-disk structures of ZFS carrying fletcher4 checksums, for tooling working on raw
+// pool images or exchanging checksums with the C implementation.
+package zfs // import go.solidsystem.no/fletcher4/zfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// EmbeddedSize is the size of the zio_eck_t trailer at the end of self checksummed blocks, the magic followed
+// by the checksum.
+const EmbeddedSize = 8 + fletcher4.Size
+
+// EmbeddedMagic is the zec_magic value marking an embedded checksum.
+const EmbeddedMagic = 0x0210da7ab10c7a11
+
+// ErrNoEmbeddedChecksum is returned for blocks without the embedded checksum magic at their end.
+var ErrNoEmbeddedChecksum = errors.New("zfs: block has no embedded checksum")
+
+// Trailer describes the layout of an embedded checksum, so formats other than those of ZFS can be checksummed the
+// same way: the checksum is computed over the whole block with a verifier in place of the checksum field, and
+// verifying needs no copy of the block.
+type Trailer struct {
+	// Offset of the trailer in the block, negative offsets count from the end of the block. It must be a
+	// multiple of fletcher4.BlockSize.
+	Offset int
+	// Magic value stored before the checksum, marking the trailer. A zero magic means the trailer has no magic,
+	// only the checksum.
+	Magic uint64
+	// Byte order of the magic and the checksum words
+	Order binary.ByteOrder
+}
+
+// ZFSTrailer is a zio_eck_t trailer at the end of the block: the ZFS magic followed by the checksum, in little
+// endian order. ZFS places zio_eck_t this way in labels and gang headers, but checksums those with SHA-256, and its
+// only fletcher4 embedded checksum, of ZIL blocks, sits in the zil_chain_t header and covers only the bytes in use.
+// Blocks sealed with it have the ZFS layout but are not blocks ZFS reads.
+var ZFSTrailer = Trailer{Offset: -EmbeddedSize, Magic: EmbeddedMagic, Order: binary.LittleEndian}
+
+// Size returns the size of the trailer, the magic, if any, followed by the checksum.
+func (t Trailer) Size() int {
+	if t.Magic == 0 {
+		return fletcher4.Size
+	}
+	return EmbeddedSize
+}
+
+// Seal stores an embedded checksum in the trailer of block: the magic and verifier are written to the trailer,
+// the whole block is hashed, and the checksum replaces the verifier. The length of block must be a multiple of
+// fletcher4.BlockSize, with room for the trailer.
+func (t Trailer) Seal(block []byte, verifier fletcher4.Checksum) fletcher4.Checksum {
+	magic, field := t.locate(block)
+	if t.Magic != 0 {
+		t.Order.PutUint64(block[magic:], t.Magic)
+	}
+	copy(block[field:], t.marshal(verifier))
+	sum := fletcher4.Sum(block)
+	copy(block[field:], t.marshal(sum))
+	return sum
+}
+
+// Verify checks the embedded checksum in the trailer of block, sealed with verifier, without modifying the
+// block. It returns ErrNoEmbeddedChecksum if the magic is missing, and an error wrapping a
+// *fletcher4.MismatchError if the checksum does not match. The length of block must be a multiple of
+// fletcher4.BlockSize, with room for the trailer.
+func (t Trailer) Verify(block []byte, verifier fletcher4.Checksum) error {
+	magic, field := t.locate(block)
+	if t.Magic != 0 && t.Order.Uint64(block[magic:]) != t.Magic {
+		return ErrNoEmbeddedChecksum
+	}
+	end := field + fletcher4.Size
+	z, _ := DecodeZioCksum(block[field:end], t.Order)
+	stored := z.Checksum()
+
+	// The checksum of the block with the verifier in place of the checksum
+	actual := fletcher4.Combine(fletcher4.Sum(block[:field]), fletcher4.Sum(t.marshal(verifier)), fletcher4.Size)
+	actual = fletcher4.Combine(actual, fletcher4.Sum(block[end:]), int64(len(block)-end))
+	if actual != stored {
+		return fmt.Errorf("zfs: embedded checksum: %w", &fletcher4.MismatchError{Expected: stored, Actual: actual})
+	}
+	return nil
+}
+
+// locate returns the offsets of the magic and the checksum of the trailer in block.
+func (t Trailer) locate(block []byte) (magic, field int) {
+	magic = t.Offset
+	if magic < 0 {
+		magic += len(block)
+	}
+	size := t.Size()
+	if len(block)%fletcher4.BlockSize != 0 || magic%fletcher4.BlockSize != 0 || magic < 0 || magic+size > len(block) {
+		panic(fmt.Sprintf("Blocks with embedded checksums must be a multiple of %v bytes, with room for the %v byte trailer at offset %v.",
+			fletcher4.BlockSize, size, t.Offset))
+	}
+	return magic, magic + size - fletcher4.Size
+}
+
+func (t Trailer) marshal(sum fletcher4.Checksum) []byte {
+	b := make([]byte, fletcher4.Size)
+	for i, w := range sum {
+		t.Order.PutUint64(b[i*8:], w)
+	}
+	return b
+}
+
+// SealEmbedded stores a fletcher4 embedded checksum in the last EmbeddedSize bytes of block, see ZFSTrailer and
+// Trailer.Seal. The verifier ties the block to a location, like the offset of a block on its device, use a zero
+// verifier for blocks not tied to one. The length of block must be a multiple of fletcher4.BlockSize, and at least
+// EmbeddedSize.
+func SealEmbedded(block []byte, verifier fletcher4.Checksum) fletcher4.Checksum {
+	return ZFSTrailer.Seal(block, verifier)
+}
+
+// VerifyEmbedded checks the embedded checksum at the end of block, sealed with verifier, see Trailer.Verify.
+// The length of block must be a multiple of fletcher4.BlockSize, and at least EmbeddedSize.
+func VerifyEmbedded(block []byte, verifier fletcher4.Checksum) error {
+	return ZFSTrailer.Verify(block, verifier)
+}
@@ -0,0 +1,106 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// marshal returns the on-disk form of sum, the words in little endian order.
+func marshal(sum fletcher4.Checksum) []byte {
+	return FromChecksum(sum).AppendBinary(nil, binary.LittleEndian)
+}
+
+func testBlock(n int) []byte {
+	block := make([]byte, n)
+	for i := range block {
+		block[i] = byte(i*13 + i>>8)
+	}
+	return block
+}
+
+// Test that sealed blocks verify, and that the checksum covers the block with the verifier in its place
+func TestEmbedded(t *testing.T) {
+	block := testBlock(4096)
+	verifier := fletcher4.Checksum{256 << 10}
+	sum := SealEmbedded(block, verifier)
+	if err := VerifyEmbedded(block, verifier); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bytes.Clone(block)
+	copy(expected[len(expected)-fletcher4.Size:], marshal(verifier))
+	if exp := fletcher4.Sum(expected); sum != exp {
+		t.Errorf("Embedded checksum is %v, expected %v", sum, exp)
+	}
+	if got := binary.LittleEndian.Uint64(block[len(block)-EmbeddedSize:]); got != EmbeddedMagic {
+		t.Errorf("Embedded magic is %#x", got)
+	}
+}
+
+// Test that corrupt blocks, other verifiers and blocks without magic fail to verify
+func TestVerifyEmbeddedInvalid(t *testing.T) {
+	block := testBlock(512)
+	SealEmbedded(block, fletcher4.Checksum{0})
+
+	if err := VerifyEmbedded(block, fletcher4.Checksum{512}); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Verify with other verifier returned %v", err)
+	}
+	corrupt := bytes.Clone(block)
+	corrupt[100] ^= 1
+	if err := VerifyEmbedded(corrupt, fletcher4.Checksum{0}); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Verify of corrupt block returned %v", err)
+	}
+	if err := VerifyEmbedded(testBlock(512), fletcher4.Checksum{0}); !errors.Is(err, ErrNoEmbeddedChecksum) {
+		t.Errorf("Verify of block without magic returned %v", err)
+	}
+}
+
+// Test trailers of other layouts: a big endian header at the start of the block, and a bare checksum in its middle
+func TestTrailer(t *testing.T) {
+	header := Trailer{Offset: 0, Magic: 0x1122334455667788, Order: binary.BigEndian}
+	bare := Trailer{Offset: 1024, Order: binary.LittleEndian}
+	for _, tr := range []Trailer{header, bare} {
+		block := testBlock(4096)
+		verifier := fletcher4.Checksum{1, 2, 3, 4}
+		sum := tr.Seal(block, verifier)
+		if err := tr.Verify(block, verifier); err != nil {
+			t.Fatalf("Verify of %+v returned %v", tr, err)
+		}
+
+		field := tr.Offset + tr.Size() - fletcher4.Size
+		if z, _ := DecodeZioCksum(block[field:field+fletcher4.Size], tr.Order); z.Checksum() != sum {
+			t.Errorf("Trailer %+v stores %v, expected %v", tr, z.Checksum(), sum)
+		}
+		expected := bytes.Clone(block)
+		copy(expected[field:], tr.marshal(verifier))
+		if exp := fletcher4.Sum(expected); sum != exp {
+			t.Errorf("Trailer %+v checksum is %v, expected %v", tr, sum, exp)
+		}
+
+		block[4000] ^= 1
+		if err := tr.Verify(block, verifier); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+			t.Errorf("Verify of corrupt block with %+v returned %v", tr, err)
+		}
+	}
+	if err := header.Verify(testBlock(512), fletcher4.Checksum{}); !errors.Is(err, ErrNoEmbeddedChecksum) {
+		t.Errorf("Verify of block without magic returned %v", err)
+	}
+}
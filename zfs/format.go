@@ -0,0 +1,66 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format selects how checksums are printed by ZioCksum.Format, matching the output of the ZFS tools so it can be
+// compared with them directly.
+type Format int
+
+const (
+	// The cksum= field of block pointers printed by zdb, zero padded hex words separated by colons
+	FormatZdb Format = iota
+	// Like FormatZdb without zero padding, as printed by older releases
+	FormatZdbUnpadded
+	// Hex words separated by slashes, as printed by zstreamdump
+	FormatZstreamdump
+)
+
+// Format returns z formatted in format f, e.g. "0000000000000400:..." for FormatZdb.
+func (z ZioCksum) Format(f Format) string {
+	w := z.Word
+	switch f {
+	case FormatZdb:
+		return fmt.Sprintf("%016x:%016x:%016x:%016x", w[0], w[1], w[2], w[3])
+	case FormatZdbUnpadded:
+		return fmt.Sprintf("%x:%x:%x:%x", w[0], w[1], w[2], w[3])
+	case FormatZstreamdump:
+		return fmt.Sprintf("%x/%x/%x/%x", w[0], w[1], w[2], w[3])
+	}
+	panic(fmt.Sprintf("Unknown checksum format %d.", int(f)))
+}
+
+// ParseZioCksum parses a checksum in any of the formats, e.g. copied from zdb output. A leading "cksum=" is
+// accepted.
+func ParseZioCksum(s string) (ZioCksum, error) {
+	var z ZioCksum
+	words := strings.FieldsFunc(strings.TrimPrefix(s, "cksum="), func(r rune) bool { return r == ':' || r == '/' })
+	if len(words) != len(z.Word) {
+		return z, fmt.Errorf("zfs: invalid checksum %q, expected 4 hex words", s)
+	}
+	for i, word := range words {
+		w, err := strconv.ParseUint(word, 16, 64)
+		if err != nil {
+			return z, fmt.Errorf("zfs: invalid checksum %q: %w", s, err)
+		}
+		z.Word[i] = w
+	}
+	return z, nil
+}
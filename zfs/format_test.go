@@ -0,0 +1,43 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import "testing"
+
+// Test formatting checksums like the ZFS tools, and parsing them back
+func TestFormat(t *testing.T) {
+	z := ZioCksum{Word: [4]uint64{0x1ff, 0x3fe00, 0x4f4c1f2a, 0xdeadbeef00cafe}}
+	for f, exp := range map[Format]string{
+		FormatZdb:         "00000000000001ff:000000000003fe00:000000004f4c1f2a:00deadbeef00cafe",
+		FormatZdbUnpadded: "1ff:3fe00:4f4c1f2a:deadbeef00cafe",
+		FormatZstreamdump: "1ff/3fe00/4f4c1f2a/deadbeef00cafe",
+	} {
+		got := z.Format(f)
+		if got != exp {
+			t.Errorf("Format %v gave %q, expected %q", f, got, exp)
+		}
+		if parsed, err := ParseZioCksum(got); err != nil || parsed != z {
+			t.Errorf("Parsing %q gave %v, %v", got, parsed, err)
+		}
+	}
+	if parsed, err := ParseZioCksum("cksum=" + z.Format(FormatZdb)); err != nil || parsed != z {
+		t.Errorf("Parsing with cksum= prefix gave %v, %v", parsed, err)
+	}
+	for _, s := range []string{"", "1:2:3", "1:2:3:x", "1:2:3:4:5"} {
+		if _, err := ParseZioCksum(s); err == nil {
+			t.Errorf("Parsing %q succeeded", s)
+		}
+	}
+}
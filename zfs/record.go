@@ -0,0 +1,89 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// DefaultRecordSize is the default recordsize property of ZFS file systems.
+const DefaultRecordSize = 128 << 10
+
+// Smallest block size of ZFS, SPA_MINBLOCKSIZE
+const minBlockSize = 512
+
+// SumRecords returns the checksum of every record of a file holding data, the way ZFS splits and checksums file
+// data when stored without compression or encryption, which change the data checksummed. A file of up to one
+// record is a single block rounded up to 512 bytes, larger files are blocks of recordSize bytes with the last
+// one zero padded. Empty files have no blocks. If recordSize is zero or negative, DefaultRecordSize is used,
+// otherwise it must be a power of two between 512 bytes and 16 MiB.
+func SumRecords(data []byte, recordSize int) []fletcher4.Checksum {
+	recordSize = checkRecordSize(recordSize)
+	var sums []fletcher4.Checksum
+	for len(data) > 0 {
+		n := min(len(data), recordSize)
+		sums = append(sums, sumRecord(data[:n], len(data) <= recordSize && len(sums) == 0, recordSize))
+		data = data[n:]
+	}
+	return sums
+}
+
+// SumRecordsReader is like SumRecords, but reads the data from r until EOF. It also returns the number of bytes
+// read.
+func SumRecordsReader(r io.Reader, recordSize int) ([]fletcher4.Checksum, int64, error) {
+	recordSize = checkRecordSize(recordSize)
+	var sums []fletcher4.Checksum
+	var total int64
+	buf := make([]byte, recordSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		total += int64(n)
+		if n > 0 {
+			// A short first record is all of the file
+			sums = append(sums, sumRecord(buf[:n], n < recordSize && len(sums) == 0, recordSize))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sums, total, nil
+		}
+		if err != nil {
+			return sums, total, err
+		}
+	}
+}
+
+// sumRecord returns the checksum of a record zero padded to its block size, 512 bytes for the only block of a
+// small file and recordSize otherwise.
+func sumRecord(p []byte, only bool, recordSize int) fletcher4.Checksum {
+	size := recordSize
+	if only {
+		size = (len(p) + minBlockSize - 1) &^ (minBlockSize - 1)
+	}
+	whole := (len(p) + fletcher4.BlockSize - 1) &^ (fletcher4.BlockSize - 1)
+	// Appending zero words is combining with the zero checksum
+	return fletcher4.Combine(fletcher4.Sum(p), fletcher4.Checksum{}, int64(size-whole))
+}
+
+func checkRecordSize(recordSize int) int {
+	if recordSize <= 0 {
+		return DefaultRecordSize
+	}
+	if recordSize < minBlockSize || recordSize > 16<<20 || recordSize&(recordSize-1) != 0 {
+		panic(fmt.Sprintf("Record size must be a power of two between %v bytes and 16 MiB.", minBlockSize))
+	}
+	return recordSize
+}
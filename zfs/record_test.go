@@ -0,0 +1,78 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"bytes"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// paddedSum returns the checksum of p zero padded to size bytes
+func paddedSum(p []byte, size int) fletcher4.Checksum {
+	return fletcher4.Sum(append(bytes.Clone(p), make([]byte, size-len(p))...))
+}
+
+// Test that files are split and padded into blocks like ZFS stores them
+func TestSumRecords(t *testing.T) {
+	const record = 4096
+	data := testBlock(3*record + 1001)
+	for _, tc := range []struct {
+		size int
+		exp  []fletcher4.Checksum
+	}{
+		{0, nil},
+		{1, []fletcher4.Checksum{paddedSum(data[:1], 512)}},
+		{1001, []fletcher4.Checksum{paddedSum(data[:1001], 1024)}},
+		{record, []fletcher4.Checksum{fletcher4.Sum(data[:record])}},
+		{len(data), []fletcher4.Checksum{fletcher4.Sum(data[:record]), fletcher4.Sum(data[record : 2*record]),
+			fletcher4.Sum(data[2*record : 3*record]), paddedSum(data[3*record:], record)}},
+	} {
+		got := SumRecords(data[:tc.size], record)
+		if len(got) != len(tc.exp) {
+			t.Fatalf("%v bytes gave %v records, expected %v", tc.size, len(got), len(tc.exp))
+		}
+		for i := range got {
+			if got[i] != tc.exp[i] {
+				t.Errorf("Record %v of %v bytes:\nexpected\t%v,\ngot\t\t%v", i, tc.size, tc.exp[i], got[i])
+			}
+		}
+
+		fromReader, n, err := SumRecordsReader(bytes.NewReader(data[:tc.size]), record)
+		if err != nil || n != int64(tc.size) || len(fromReader) != len(got) {
+			t.Fatalf("SumRecordsReader of %v bytes returned %v records, %v bytes, %v", tc.size, len(fromReader), n, err)
+		}
+		for i := range got {
+			if fromReader[i] != got[i] {
+				t.Errorf("SumRecordsReader record %v of %v bytes differs", i, tc.size)
+			}
+		}
+	}
+}
+
+// Test that zero selects the default record size, and that invalid sizes panic
+func TestRecordSize(t *testing.T) {
+	data := testBlock(DefaultRecordSize + 4)
+	if got := SumRecords(data, 0); len(got) != 2 {
+		t.Errorf("Default record size gave %v records", len(got))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Record size of 1000 bytes did not panic")
+		}
+	}()
+	SumRecords(data, 1000)
+}
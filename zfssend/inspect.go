@@ -0,0 +1,129 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Summary describes one record of a send stream, like a line of zstreamdump -v.
+type Summary struct {
+	// Index of the record in the stream, counting from zero
+	Record int
+	Type   RecordType
+	// Offset of the record from the start of the stream
+	StreamOffset int64
+	// Object the record applies to. For FREEOBJECTS and OBJECT_RANGE the first object.
+	Object uint64
+	// Range of the object the record applies to, for the write, free, spill and redact records. For FREEOBJECTS
+	// and OBJECT_RANGE Length is the number of objects or slots.
+	Offset uint64
+	Length uint64
+	// Name of the snapshot, for BEGIN records
+	Name       string
+	PayloadLen uint64
+	// Checksum stored in the record
+	Checksum fletcher4.Checksum
+	// Whether the record has a checksum to verify, and whether it matches
+	Checked bool
+	Valid   bool
+}
+
+// Summarize returns the summary of rec. err is the error Reader.Next returned with it, a *ChecksumError makes
+// the record invalid.
+func Summarize(rec *Record, err error) Summary {
+	s := Summary{Record: rec.Index, Type: rec.Type, StreamOffset: rec.Offset, PayloadLen: rec.PayloadLen(), Valid: true}
+	if rec.Type != Begin {
+		s.Checksum = rec.Checksum()
+		s.Checked = s.Checksum != (fletcher4.Checksum{})
+	}
+	var cerr *ChecksumError
+	if errors.As(err, &cerr) {
+		s.Valid = false
+	}
+
+	u64 := func(off int) uint64 { return rec.order().Uint64(rec.Header[off:]) }
+	switch rec.Type {
+	case Begin:
+		// drr_toname, after magic, version, creation time, type, flags and the guids
+		name := rec.Header[56:RecordSize]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		s.Name = string(name)
+	case Object, Spill:
+		s.Object = u64(8)
+		if rec.Type == Spill {
+			s.Length = u64(16)
+		}
+	case FreeObjects, ObjectRange:
+		s.Object, s.Length = u64(8), u64(16)
+	case Write:
+		// drr_object, drr_type and padding, drr_offset, drr_logical_size
+		s.Object, s.Offset, s.Length = u64(8), u64(24), u64(32)
+	case Free, WriteByRef, WriteEmbedded, Redact:
+		s.Object, s.Offset, s.Length = u64(8), u64(16), u64(24)
+	}
+	return s
+}
+
+// String formats the summary as one line.
+func (s Summary) String() string {
+	status := "unchecked"
+	if s.Checked {
+		status = "valid"
+	}
+	if !s.Valid {
+		status = "INVALID"
+	}
+	if s.Type == Begin {
+		return fmt.Sprintf("%v record=%v at=%v name=%q payload=%v %v", s.Type, s.Record, s.StreamOffset, s.Name,
+			s.PayloadLen, status)
+	}
+	return fmt.Sprintf("%v record=%v at=%v object=%v offset=%v length=%v payload=%v checksum=%v %v", s.Type, s.Record,
+		s.StreamOffset, s.Object, s.Offset, s.Length, s.PayloadLen, s.Checksum, status)
+}
+
+// Inspect reads the send stream r to its end and calls fn with the summary of every record, including those with
+// invalid checksums. It stops at the first error returned by fn or reading r other than a checksum mismatch.
+func Inspect(r io.Reader, fn func(Summary) error, opts ...Option) error {
+	sr := NewReader(r, opts...)
+	for {
+		rec, err := sr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var cerr *ChecksumError
+		if err != nil && !errors.As(err, &cerr) {
+			return err
+		}
+		if err := fn(Summarize(rec, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// Dump writes one line per record of the send stream r to w, in the format of Summary.String.
+func Dump(w io.Writer, r io.Reader, opts ...Option) error {
+	return Inspect(r, func(s Summary) error {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}, opts...)
+}
@@ -0,0 +1,67 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test that Dump prints one line per record with its fields
+func TestDump(t *testing.T) {
+	var out bytes.Buffer
+	if err := Dump(&out, bytes.NewReader(sendStream(testStream()))); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Dump printed %v lines, expected 5:\n%v", len(lines), out.String())
+	}
+	for i, exp := range []string{
+		`BEGIN record=0 at=0 name="pool/fs@snap" payload=0 unchecked`,
+		"OBJECT record=1 at=312 object=1 offset=0 length=0 payload=8 checksum=",
+		"WRITE record=2 at=632 object=1 offset=8192 length=4096 payload=4096 checksum=",
+		"FREE record=3 at=5040 object=2 offset=0 length=1048576 payload=0 checksum=",
+	} {
+		if !strings.HasPrefix(lines[i], exp) {
+			t.Errorf("Line %v is %q, expected it to start with %q", i, lines[i], exp)
+		}
+	}
+	if !strings.HasSuffix(lines[4], " valid") {
+		t.Errorf("Last line %q is not valid", lines[4])
+	}
+}
+
+// Test that Inspect goes on after a corrupt record, and only flags the record whose checksum covers the damage
+func TestInspectCorrupt(t *testing.T) {
+	stream := sendStream(testStream())
+	stream[3*RecordSize+8+100] ^= 1
+	var summaries []Summary
+	if err := Inspect(bytes.NewReader(stream), func(s Summary) error {
+		summaries = append(summaries, s)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 5 {
+		t.Fatalf("Inspect returned %v summaries, expected 5", len(summaries))
+	}
+	for i, s := range summaries {
+		if s.Valid != (i != 3) {
+			t.Errorf("Record %v valid: %v", i, s.Valid)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"fmt"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Mode is the convention a stream's record checksums follow.
+type Mode int
+
+const (
+	// The checksum of a record covers everything since the last BEGIN record up to the checksum, the previous
+	// records and payloads included, as zfs send computes it
+	Cumulative Mode = iota
+	// The checksum of a record covers only the record with its checksum set to zero, followed by its payload,
+	// as in replication formats checksumming each record on its own
+	PerRecord
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Cumulative:
+		return "cumulative"
+	case PerRecord:
+		return "per record"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithMode sets the convention the record checksums are verified by, Cumulative if not given.
+func WithMode(m Mode) Option {
+	if m != Cumulative && m != PerRecord {
+		panic(fmt.Sprintf("Unknown checksum mode %v.", m))
+	}
+	return func(r *Reader) {
+		r.mode = m
+	}
+}
+
+// verifyRecord verifies the checksum of rec in PerRecord mode, once its payload is read.
+func (r *Reader) verifyRecord(rec *Record) error {
+	r.sum = fletcher4.Checksum{}
+	r.add(rec.Header[:checksumOffset])
+	r.sum = fletcher4.Combine(r.sum, fletcher4.Checksum{}, fletcher4.Size)
+	r.add(rec.Payload)
+	expected := rec.Checksum()
+	if rec.Type == Begin || expected == (fletcher4.Checksum{}) || expected == r.sum {
+		return nil
+	}
+	return &ChecksumError{Record: rec.Index, Type: rec.Type, Offset: rec.Offset, Expected: expected, Actual: r.sum,
+		DamageStart: rec.Offset, DamageEnd: rec.Offset + RecordSize + int64(len(rec.Payload))}
+}
@@ -0,0 +1,71 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// perRecordStream builds a stream of records like sendStream, with the checksum of every record covering only
+// the record and its payload
+func perRecordStream(records []testRecord) []byte {
+	stream := sendStream(records)
+	for off := 0; off < len(stream); {
+		hdr := stream[off : off+RecordSize]
+		rec := Record{Type: RecordType(binary.LittleEndian.Uint32(hdr))}
+		copy(rec.Header[:], hdr)
+		end := off + RecordSize + int(rec.PayloadLen())
+		if rec.Type != Begin {
+			clear(hdr[checksumOffset:])
+			h := fletcher4.NewHashingWriter(io.Discard)
+			h.Write(stream[off:end])
+			h.Checksum().AppendBinary(hdr[:checksumOffset])
+		}
+		off = end
+	}
+	return stream
+}
+
+// Test that per record checksums verify in PerRecord mode only, and that damage is located within the record
+func TestPerRecord(t *testing.T) {
+	stream := perRecordStream(testStream())
+	if err := Verify(bytes.NewReader(stream), WithMode(PerRecord)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(bytes.NewReader(stream)); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Cumulative verification of per record stream returned %v", err)
+	}
+	if err := Verify(bytes.NewReader(sendStream(testStream())), WithMode(PerRecord)); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Per record verification of cumulative stream returned %v", err)
+	}
+
+	// Inside the payload of the WRITE record, which is now the record reporting it
+	corrupt := bytes.Clone(stream)
+	corrupt[3*RecordSize+8+100] ^= 1
+	var cerr *ChecksumError
+	if err := Verify(bytes.NewReader(corrupt), WithMode(PerRecord)); !errors.As(err, &cerr) {
+		t.Fatalf("Verify of corrupt stream returned %v", err)
+	}
+	if start, end := int64(2*RecordSize+8), int64(3*RecordSize+8+4096); cerr.Record != 2 || cerr.Type != Write ||
+		cerr.DamageStart != start || cerr.DamageEnd != end {
+		t.Errorf("Corruption reported in %v record %v, bytes %v-%v", cerr.Type, cerr.Record, cerr.DamageStart, cerr.DamageEnd)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stats describes a stream passed through Pipe.
+type Stats struct {
+	// Number of records and of BEGIN records, i.e. streams of a compound stream
+	Records int
+	Streams int
+	// Bytes passed through
+	Bytes   int64
+	Elapsed time.Duration
+	// Records whose checksum did not match, in stream order
+	Failures []*ChecksumError
+}
+
+// Rate returns the average throughput in bytes per second.
+func (s Stats) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// String returns the statistics in one line, e.g. "1 streams, 1234 records, 5.0 MiB in 1.2s (4.2 MiB/s), 0 failures".
+func (s Stats) String() string {
+	return fmt.Sprintf("%v streams, %v records, %.1f MiB in %v (%.1f MiB/s), %v failures", s.Streams, s.Records,
+		float64(s.Bytes)/(1<<20), s.Elapsed.Round(time.Millisecond), s.Rate()/(1<<20), len(s.Failures))
+}
+
+// Pipe copies the send stream src to dst unchanged, while verifying its checksums, for use in the middle of a
+// zfs send | ... | zfs receive pipeline. Records with invalid checksums are passed on and listed in the
+// statistics, and the first of them returned as the error once the whole stream is copied. If the stream can
+// not be parsed, e.g. as it is truncated or not a send stream, the rest of it is still copied and the parse
+// error returned. Errors reading src or writing dst stop the copy.
+func Pipe(dst io.Writer, src io.Reader, opts ...Option) (Stats, error) {
+	var stats Stats
+	start := time.Now()
+	cw := &countingWriter{w: dst}
+	r := NewReader(io.TeeReader(src, cw), opts...)
+	err := func() error {
+		for {
+			rec, err := r.Next()
+			if err == io.EOF {
+				return r.ended()
+			}
+			var cerr *ChecksumError
+			if errors.As(err, &cerr) {
+				stats.Failures = append(stats.Failures, cerr)
+			} else if err != nil {
+				return err
+			}
+			stats.Records++
+			if rec.Type == Begin {
+				stats.Streams++
+			}
+		}
+	}()
+	if err != nil && cw.err == nil {
+		// Whatever the reader buffered has been copied already, pass on the rest
+		_, cerr := io.Copy(cw, src)
+		if cerr != nil {
+			err = cerr
+		}
+	}
+	if cw.err != nil {
+		err = cw.err
+	}
+	stats.Bytes = cw.n
+	stats.Elapsed = time.Since(start)
+	if err == nil && len(stats.Failures) > 0 {
+		err = stats.Failures[0]
+	}
+	return stats, err
+}
+
+// countingWriter counts the bytes written, and keeps the first write error so it is reported rather than the
+// read error TeeReader turns it into.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
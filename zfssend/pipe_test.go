@@ -0,0 +1,76 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Test that streams are passed through unchanged with their statistics, corrupt or not
+func TestPipe(t *testing.T) {
+	stream := sendStream(testStream())
+	compound := append(bytes.Clone(stream), stream...)
+	var out bytes.Buffer
+	stats, err := Pipe(&out, bytes.NewReader(compound))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), compound) {
+		t.Error("Pipe changed the stream")
+	}
+	if stats.Streams != 2 || stats.Records != 10 || stats.Bytes != int64(len(compound)) || len(stats.Failures) != 0 {
+		t.Errorf("Pipe returned %+v", stats)
+	}
+
+	corrupt := bytes.Clone(stream)
+	corrupt[3*RecordSize+8+100] ^= 1
+	out.Reset()
+	stats, err = Pipe(&out, bytes.NewReader(corrupt))
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) || len(stats.Failures) != 1 || stats.Failures[0] != cerr || stats.Records != 5 {
+		t.Errorf("Pipe of corrupt stream returned %+v, %v", stats, err)
+	}
+	if !bytes.Equal(out.Bytes(), corrupt) {
+		t.Error("Pipe changed the corrupt stream")
+	}
+}
+
+// Test that data which is not a send stream, or is truncated, is still passed through entirely
+func TestPipeInvalid(t *testing.T) {
+	garbage := bytes.Repeat([]byte("not a send stream "), 100000)
+	var out bytes.Buffer
+	if _, err := Pipe(&out, bytes.NewReader(garbage)); err == nil {
+		t.Error("Pipe of garbage succeeded")
+	}
+	if !bytes.Equal(out.Bytes(), garbage) {
+		t.Errorf("Pipe passed %v of %v bytes of garbage", out.Len(), len(garbage))
+	}
+
+	odd := sendStream([]testRecord{{typ: Begin}, {typ: Write, object: 1, payload: []byte("12345")}, {typ: End}})
+	out.Reset()
+	if _, err := Pipe(&out, bytes.NewReader(odd)); err == nil || !bytes.Equal(out.Bytes(), odd) {
+		t.Errorf("Pipe of a payload of 5 bytes returned %v, passing %v of %v bytes", err, out.Len(), len(odd))
+	}
+
+	stream := sendStream(testStream())
+	truncated := stream[:len(stream)-RecordSize]
+	out.Reset()
+	if stats, err := Pipe(&out, bytes.NewReader(truncated)); !errors.Is(err, io.ErrUnexpectedEOF) || stats.Bytes != int64(len(truncated)) {
+		t.Errorf("Pipe of truncated stream returned %+v, %v", stats, err)
+	}
+}
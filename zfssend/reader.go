@@ -0,0 +1,206 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Payloads larger than this are refused rather than allocated, as the length of a record with a zero checksum
+// cannot be trusted. The largest blocks are 16 MiB.
+const maxPayload = 256 << 20
+
+// ChecksumError reports a record whose checksum does not match the data before it. The corruption is between the
+// checksum of the previous record with a valid checksum and the checksum of this record, e.g. in the payload of
+// the previous record, or in PerRecord mode within the record and its payload. Repair tooling can re-fetch just
+// the bytes from DamageStart to DamageEnd.
+type ChecksumError struct {
+	// Index of the record in the stream, counting from zero
+	Record int
+	Type   RecordType
+	// Offset of the record from the start of the stream
+	Offset   int64
+	Expected fletcher4.Checksum
+	Actual   fletcher4.Checksum
+	// Offsets of the damaged region, from the end of the data verified by an earlier record, or its BEGIN record,
+	// to the checksum of this record. In PerRecord mode the record and its payload.
+	DamageStart int64
+	DamageEnd   int64
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("zfssend: checksum mismatch in %v record %v at offset %v, damage in bytes %v-%v: expected %v, got %v",
+		e.Type, e.Record, e.Offset, e.DamageStart, e.DamageEnd, e.Expected, e.Actual)
+}
+
+// Unwrap returns a *fletcher4.MismatchError, so the error matches fletcher4.ErrChecksumMismatch.
+func (e *ChecksumError) Unwrap() error {
+	return &fletcher4.MismatchError{Expected: e.Expected, Actual: e.Actual}
+}
+
+// Reader reads the records of a send stream and verifies their checksums.
+type Reader struct {
+	r      *bufio.Reader
+	sum    fletcher4.Checksum
+	rec    Record
+	offset int64
+	index  int
+	begun  bool
+	// Type of the last record read
+	last RecordType
+	// Offset up to which the stream has been verified by a record checksum
+	verified int64
+	// Whether the stream since the last BEGIN record is byte swapped
+	swapped bool
+	mode    Mode
+}
+
+// NewReader returns a Reader reading a send stream from r. The stream is buffered internally.
+func NewReader(r io.Reader, opts ...Option) *Reader {
+	return Resume(r, State{}, opts...)
+}
+
+// add adds p to the running checksum of the stream, a whole number of words long.
+func (r *Reader) add(p []byte) {
+	var sum fletcher4.Checksum
+	if r.swapped {
+		sum = fletcher4.ChecksumByteswap(p)
+	} else {
+		sum = fletcher4.Sum(p)
+	}
+	r.sum = fletcher4.Combine(r.sum, sum, int64(len(p)))
+}
+
+// Next reads the next record and its payload, and verifies the checksum of the record. It returns io.EOF at
+// the end of the stream. The returned record is only valid until the next call.
+//
+// If the checksum does not match, the record is returned together with a *ChecksumError and reading may go on.
+// The checksum stored in the record is then trusted from there on, so later records are verified independently of
+// the damage before it.
+func (r *Reader) Next() (*Record, error) {
+	rec := &r.rec
+	if _, err := io.ReadFull(r.r, rec.Header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("zfssend: record %v at offset %v: %w", r.index, r.offset, err)
+		}
+		return nil, err
+	}
+	rec.Index = r.index
+	rec.Offset = r.offset
+
+	// Begin is zero in either byte order, its magic tells the order of the stream
+	if binary.LittleEndian.Uint32(rec.Header[:]) == uint32(Begin) {
+		switch magic := binary.LittleEndian.Uint64(rec.Header[8:]); magic {
+		case BackupMagic:
+			r.swapped = false
+		case bits.ReverseBytes64(BackupMagic):
+			r.swapped = true
+		default:
+			return nil, fmt.Errorf("zfssend: BEGIN record %v at offset %v has bad magic %#x", r.index, r.offset, magic)
+		}
+	}
+	rec.Byteswapped = r.swapped
+	rec.Type = RecordType(rec.order().Uint32(rec.Header[:]))
+
+	if rec.Type == Begin {
+		r.sum = fletcher4.Checksum{}
+		r.begun = true
+		r.verified = r.offset
+	} else if !r.begun {
+		return nil, fmt.Errorf("zfssend: stream starts with %v record, not BEGIN", rec.Type)
+	}
+
+	// The checksum covers whole words, so a payload of any other length can only come from a damaged record
+	n := rec.PayloadLen()
+	if n > maxPayload || n%fletcher4.BlockSize != 0 {
+		return nil, fmt.Errorf("zfssend: %v record %v at offset %v has a payload of %v bytes", rec.Type, r.index, r.offset, n)
+	}
+
+	var mismatch error
+	if r.mode == Cumulative {
+		mismatch = r.verifyCumulative(rec)
+	}
+	if cap(rec.Payload) < int(n) {
+		rec.Payload = make([]byte, n)
+	}
+	rec.Payload = rec.Payload[:n]
+	if _, err := io.ReadFull(r.r, rec.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("zfssend: payload of %v record %v at offset %v: %w", rec.Type, r.index, r.offset, err)
+	}
+	if r.mode == Cumulative {
+		r.add(rec.Payload)
+	} else {
+		mismatch = r.verifyRecord(rec)
+	}
+
+	r.offset += RecordSize + int64(n)
+	r.index++
+	r.last = rec.Type
+	return rec, mismatch
+}
+
+// verifyCumulative adds the header of rec to the running checksum, and verifies the checksum of rec against it.
+func (r *Reader) verifyCumulative(rec *Record) error {
+	var mismatch error
+	r.add(rec.Header[:checksumOffset])
+	if expected := rec.Checksum(); rec.Type != Begin && expected != (fletcher4.Checksum{}) {
+		end := r.offset + checksumOffset
+		if expected != r.sum {
+			mismatch = &ChecksumError{Record: r.index, Type: rec.Type, Offset: r.offset, Expected: expected, Actual: r.sum,
+				DamageStart: r.verified, DamageEnd: end}
+			r.sum = expected
+		}
+		r.verified = end
+	}
+	r.add(rec.Header[checksumOffset:])
+	return mismatch
+}
+
+// Verify reads the send stream r to its end and verifies the checksums of all records. It returns the first
+// *ChecksumError naming the corrupt record and its offset, or an error if the stream is truncated before its
+// last END record.
+func Verify(r io.Reader, opts ...Option) error {
+	return NewReader(r, opts...).Verify()
+}
+
+// Verify reads the rest of the stream and verifies the checksums of its records, like the Verify function.
+func (r *Reader) Verify() error {
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			return r.ended()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ended returns an error unless the stream read to its end finished with an END record.
+func (r *Reader) ended() error {
+	if r.last != End {
+		return fmt.Errorf("zfssend: stream ends after %v records without END record: %w", r.index, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
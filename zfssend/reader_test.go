@@ -0,0 +1,393 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// testRecord is a record to send, with the fields the tests need
+type testRecord struct {
+	typ     RecordType
+	name    string
+	object  uint64
+	offset  uint64
+	length  uint64
+	payload []byte
+}
+
+// sendStream builds a send stream of records, checksummed like dump_record of OpenZFS does
+func sendStream(records []testRecord) []byte {
+	return sendStreamOrder(records, binary.LittleEndian)
+}
+
+// sendStreamOrder builds a send stream like sendStream, as sent by a host of the given byte order
+func sendStreamOrder(records []testRecord, order binary.ByteOrder) []byte {
+	var out bytes.Buffer
+	// Everything since the last BEGIN record
+	var since []byte
+	sum := func() fletcher4.Checksum {
+		if order == binary.BigEndian {
+			return fletcher4.ChecksumByteswap(since)
+		}
+		h := fletcher4.NewHashingWriter(io.Discard)
+		h.Write(since)
+		return h.Checksum()
+	}
+	for _, r := range records {
+		var hdr [RecordSize]byte
+		order.PutUint32(hdr[0:], uint32(r.typ))
+		// Only BEGIN has drr_payloadlen, the other records give the length of the payload in their own fields
+		switch r.typ {
+		case Begin:
+			order.PutUint32(hdr[4:], uint32(len(r.payload)))
+			order.PutUint64(hdr[8:], BackupMagic)
+			copy(hdr[56:], r.name)
+			since = nil
+		case Object:
+			order.PutUint64(hdr[8:], r.object)
+			order.PutUint32(hdr[28:], uint32(len(r.payload)))
+		case Write:
+			order.PutUint64(hdr[8:], r.object)
+			order.PutUint64(hdr[24:], r.offset)
+			order.PutUint64(hdr[32:], uint64(len(r.payload)))
+		case Spill:
+			order.PutUint64(hdr[8:], r.object)
+			order.PutUint64(hdr[16:], uint64(len(r.payload)))
+		default:
+			order.PutUint64(hdr[8:], r.object)
+			order.PutUint64(hdr[16:], r.offset)
+			order.PutUint64(hdr[24:], r.length)
+		}
+		since = append(since, hdr[:checksumOffset]...)
+		if r.typ != Begin {
+			for i, w := range sum() {
+				order.PutUint64(hdr[checksumOffset+8*i:], w)
+			}
+		}
+		since = append(since, hdr[checksumOffset:]...)
+		since = append(since, r.payload...)
+		out.Write(hdr[:])
+		out.Write(r.payload)
+	}
+	return out.Bytes()
+}
+
+func testStream() []testRecord {
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return []testRecord{
+		{typ: Begin, name: "pool/fs@snap"},
+		{typ: Object, object: 1, payload: []byte("bonusbuf")},
+		{typ: Write, object: 1, offset: 8192, length: 4096, payload: data},
+		{typ: Free, object: 2, offset: 0, length: 1 << 20},
+		{typ: End},
+	}
+}
+
+// zfsSendStream returns the records of zfs send -R -c of a snapshot with a file, its fields at the offsets of the
+// structs of the dmu_replay_record union in zfs_ioctl.h, and the payload length each record implies.
+func zfsSendStream() (records [][RecordSize]byte, payloads [][]byte) {
+	le := binary.LittleEndian
+	add := func(typ RecordType, payloadLen int, fields func(h []byte)) {
+		var hdr [RecordSize]byte
+		le.PutUint32(hdr[0:], uint32(typ))
+		fields(hdr[:])
+		payload := make([]byte, payloadLen)
+		for i := range payload {
+			payload[i] = byte(len(records) + i*13)
+		}
+		records = append(records, hdr)
+		payloads = append(payloads, payload)
+	}
+	const toguid = 0x5c5b1e0f4a6b2d3e
+
+	// The compound stream header of -R, the packed nvlist of the snapshots in drr_payloadlen
+	add(Begin, 1064, func(h []byte) {
+		le.PutUint32(h[4:], 1064)
+		le.PutUint64(h[8:], BackupMagic)
+		le.PutUint64(h[16:], 2) // DMU_COMPOUNDSTREAM
+		le.PutUint64(h[24:], 1791158400)
+		le.PutUint32(h[32:], 2) // DMU_OST_ZFS
+		le.PutUint32(h[36:], 4) // DRR_FLAG_CI_DATA
+		copy(h[56:], "tank/home@backup")
+	})
+	// Written as is by libzfs, without a checksum after it
+	add(End, 0, func(h []byte) {})
+	add(Begin, 0, func(h []byte) {
+		le.PutUint64(h[8:], BackupMagic)
+		// DMU_SUBSTREAM, with the embedded data, lz4, large blocks and compressed features
+		le.PutUint64(h[16:], (1<<16|1<<17|1<<19|1<<22)<<2|1)
+		le.PutUint64(h[24:], 1791158400)
+		le.PutUint32(h[32:], 2)
+		le.PutUint64(h[40:], toguid)
+		copy(h[56:], "tank/home@backup")
+	})
+	add(FreeObjects, 0, func(h []byte) {
+		le.PutUint64(h[8:], 0)
+		le.PutUint64(h[16:], 1)
+		le.PutUint64(h[24:], toguid)
+	})
+	// A file with a system attribute bonus buffer of 174 bytes, padded to 176 in the stream
+	add(Object, 176, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint32(h[16:], 19) // DMU_OT_PLAIN_FILE_CONTENTS
+		le.PutUint32(h[20:], 44) // DMU_OT_SA
+		le.PutUint32(h[24:], 131072)
+		le.PutUint32(h[28:], 174)
+		h[32] = 7  // ZIO_CHECKSUM_FLETCHER_4
+		h[33] = 15 // ZIO_COMPRESS_LZ4
+		h[34] = 1  // drr_dn_slots
+		le.PutUint64(h[40:], toguid)
+	})
+	// A compressed block of 128 KiB sent as its 6 KiB on disk
+	add(Write, 6144, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint32(h[16:], 19)
+		le.PutUint64(h[24:], 0)
+		le.PutUint64(h[32:], 131072)
+		le.PutUint64(h[40:], toguid)
+		h[48] = 7
+		h[50] = 15
+		le.PutUint64(h[96:], 6144)
+	})
+	// An uncompressed block
+	add(Write, 4096, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint32(h[16:], 19)
+		le.PutUint64(h[24:], 131072)
+		le.PutUint64(h[32:], 4096)
+		le.PutUint64(h[40:], toguid)
+		h[48] = 7
+	})
+	// A block already sent, referred to by its guid and offset
+	add(WriteByRef, 0, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint64(h[16:], 262144)
+		le.PutUint64(h[24:], 4096)
+		le.PutUint64(h[32:], 2)
+		le.PutUint64(h[40:], 131072)
+		le.PutUint64(h[48:], toguid)
+		le.PutUint64(h[56:], toguid)
+	})
+	// A small block of 45 bytes compressed, padded to 48
+	add(WriteEmbedded, 48, func(h []byte) {
+		le.PutUint64(h[8:], 3)
+		le.PutUint64(h[16:], 0)
+		le.PutUint64(h[24:], 512)
+		le.PutUint64(h[32:], toguid)
+		h[40] = 15
+		le.PutUint32(h[48:], 512)
+		le.PutUint32(h[52:], 45)
+	})
+	add(Spill, 512, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint64(h[16:], 512)
+		le.PutUint64(h[24:], toguid)
+	})
+	// The rest of the file, to DMU_OBJECT_END
+	add(Free, 0, func(h []byte) {
+		le.PutUint64(h[8:], 2)
+		le.PutUint64(h[16:], 393216)
+		le.PutUint64(h[24:], ^uint64(0))
+		le.PutUint64(h[32:], toguid)
+	})
+	add(End, 0, func(h []byte) {
+		le.PutUint64(h[40:], toguid)
+	})
+	// The end of the compound stream
+	add(End, 0, func(h []byte) {})
+	return records, payloads
+}
+
+// Test that the payload lengths of the records of a zfs send stream are taken from the fields of each record type
+func TestVerifyZFSSend(t *testing.T) {
+	records, payloads := zfsSendStream()
+	var stream []byte
+	// Everything since the last BEGIN record, checksummed like dump_record of OpenZFS does
+	var since []byte
+	for i := range records {
+		hdr := &records[i]
+		typ := RecordType(binary.LittleEndian.Uint32(hdr[:]))
+		// libzfs writes the END records of the compound stream as they are, the first with drr_end.drr_checksum
+		compound := i == 1 || i == len(records)-1
+		if typ == Begin {
+			since = nil
+		}
+		if typ == End && i != len(records)-1 {
+			fletcher4.Sum(since).AppendBinary(hdr[8:8])
+		}
+		since = append(since, hdr[:checksumOffset]...)
+		if typ != Begin && !compound {
+			fletcher4.Sum(since).AppendBinary(hdr[checksumOffset:checksumOffset])
+		}
+		since = append(since, hdr[checksumOffset:]...)
+		since = append(since, payloads[i]...)
+		stream = append(stream, hdr[:]...)
+		stream = append(stream, payloads[i]...)
+	}
+
+	if err := Verify(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(stream))
+	offset := int64(0)
+	for i, hdr := range records {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatalf("Record %v: %v", i, err)
+		}
+		typ := RecordType(binary.LittleEndian.Uint32(hdr[:]))
+		if rec.Type != typ || rec.Offset != offset || rec.PayloadLen() != uint64(len(payloads[i])) ||
+			!bytes.Equal(rec.Payload, payloads[i]) {
+			t.Errorf("Record %v is %v at offset %v with %v bytes payload, expected %v at %v with %v bytes",
+				i, rec.Type, rec.Offset, len(rec.Payload), typ, offset, len(payloads[i]))
+		}
+		offset += RecordSize + int64(len(payloads[i]))
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next after END returned %v", err)
+	}
+
+	// Inside the padding of the embedded block, reported by the SPILL record after it
+	corrupt := bytes.Clone(stream)
+	corrupt[len(stream)-4*RecordSize-512-2] ^= 1
+	var cerr *ChecksumError
+	if err := Verify(bytes.NewReader(corrupt)); !errors.As(err, &cerr) || cerr.Type != Spill {
+		t.Errorf("Verify of corrupt stream returned %v", err)
+	}
+}
+
+// Test that a valid stream is read record by record and verifies
+func TestVerify(t *testing.T) {
+	records := testStream()
+	stream := sendStream(records)
+	r := NewReader(bytes.NewReader(stream))
+	offset := int64(0)
+	for i, exp := range records {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatalf("Record %v: %v", i, err)
+		}
+		if rec.Type != exp.typ || rec.Offset != offset || !bytes.Equal(rec.Payload, exp.payload) {
+			t.Errorf("Record %v is %v at offset %v with %v bytes payload, expected %v at %v with %v bytes",
+				i, rec.Type, rec.Offset, len(rec.Payload), exp.typ, offset, len(exp.payload))
+		}
+		offset += RecordSize + int64(len(exp.payload))
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next after END returned %v", err)
+	}
+
+	// A compound stream restarts the checksum at each BEGIN
+	if err := Verify(bytes.NewReader(append(stream, stream...))); err != nil {
+		t.Errorf("Verify of compound stream returned %v", err)
+	}
+}
+
+// Test that a corrupt payload is reported by the checksum of the following record
+func TestVerifyCorrupt(t *testing.T) {
+	stream := sendStream(testStream())
+	// Inside the payload of the WRITE record, the third one
+	stream[3*RecordSize+8+100] ^= 1
+	err := Verify(bytes.NewReader(stream))
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Verify of corrupt stream returned %v", err)
+	}
+	if cerr.Record != 3 || cerr.Type != Free || cerr.Offset != 3*RecordSize+8+4096 {
+		t.Errorf("Corruption reported in %v record %v at offset %v", cerr.Type, cerr.Record, cerr.Offset)
+	}
+	// From the checksum of the WRITE record to the checksum of the FREE record
+	if start, end := int64(2*RecordSize+8+checksumOffset), cerr.Offset+checksumOffset; cerr.DamageStart != start || cerr.DamageEnd != end {
+		t.Errorf("Damage reported in bytes %v-%v, expected %v-%v", cerr.DamageStart, cerr.DamageEnd, start, end)
+	}
+	if !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Checksum error %v does not match fletcher4.ErrChecksumMismatch", err)
+	}
+}
+
+// Test that truncated, byte swapped and garbage streams are refused
+func TestVerifyInvalid(t *testing.T) {
+	stream := sendStream(testStream())
+	for _, n := range []int{0, 100, RecordSize + 3, len(stream) - RecordSize} {
+		if err := Verify(bytes.NewReader(stream[:n])); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Verify of stream truncated to %v bytes returned %v", n, err)
+		}
+	}
+
+	// A little endian stream claiming to be big endian
+	swapped := bytes.Clone(stream)
+	binary.BigEndian.PutUint64(swapped[8:], BackupMagic)
+	if err := Verify(bytes.NewReader(swapped)); err == nil {
+		t.Error("Verify of stream with byte swapped magic succeeded")
+	}
+
+	if err := Verify(bytes.NewReader(stream[RecordSize:])); err == nil {
+		t.Error("Verify of stream without BEGIN succeeded")
+	}
+
+	// A payload length that is not a whole number of words, in every mode and byte order
+	odd := []testRecord{{typ: Begin}, {typ: Write, object: 1, payload: []byte("12345")}, {typ: End}}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, mode := range []Mode{Cumulative, PerRecord} {
+			if err := Verify(bytes.NewReader(sendStreamOrder(odd, order)), WithMode(mode)); err == nil {
+				t.Errorf("Verify of %v payload of 5 bytes in %v mode succeeded", order, mode)
+			}
+		}
+	}
+}
+
+// Test that streams sent by a big endian host are verified and their records decoded
+func TestVerifyByteswapped(t *testing.T) {
+	stream := sendStreamOrder(testStream(), binary.BigEndian)
+	if err := Verify(bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	// A compound stream may mix byte orders
+	if err := Verify(bytes.NewReader(append(sendStream(testStream()), stream...))); err != nil {
+		t.Errorf("Verify of mixed compound stream returned %v", err)
+	}
+
+	r := NewReader(bytes.NewReader(stream))
+	for i, exp := range testStream() {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := Summarize(rec, err)
+		if !rec.Byteswapped || s.Type != exp.typ || s.PayloadLen != uint64(len(exp.payload)) || s.Object != exp.object {
+			t.Errorf("Byte swapped record %v decoded as %v", i, s)
+		}
+		if exp.typ != Begin && !s.Checked {
+			t.Errorf("Byte swapped record %v has no checksum", i)
+		}
+	}
+
+	corrupt := bytes.Clone(stream)
+	corrupt[3*RecordSize+8+100] ^= 1
+	var cerr *ChecksumError
+	if err := Verify(bytes.NewReader(corrupt)); !errors.As(err, &cerr) || cerr.Record != 3 {
+		t.Errorf("Verify of corrupt byte swapped stream returned %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zfssend parses the replication streams produced by zfs send, and verifies the fletcher4 checksums
+// embedded in them.
+//
+// A send stream is a sequence of dmu_replay_record structures of RecordSize bytes, each followed by a payload
+// of the length given in the record. The last 32 bytes of each record hold the fletcher4 checksum of everything
+// in the stream before them, i.e. all previous records and payloads and the rest of the record itself. The
+// checksum restarts at every Begin record, which holds no checksum as the space is part of the snapshot name, so
+// the streams of a compound stream, as sent with zfs send -R, are verified independently. A zero checksum
+// means the sender did not compute one. Formats checksumming each record on its own are verified WithMode
+// PerRecord.
+//
+// Streams sent by a host of the other byte order are recognized by the byte swapped magic of their Begin record,
+// and verified with the byte swapping kernel like zfs receive does.
+package zfssend // import go.solidsystem.no/fletcher4/zfssend
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// RecordSize is the size of a dmu_replay_record, excluding its payload.
+const RecordSize = 312
+
+// Offset of the checksum at the end of a record
+const checksumOffset = RecordSize - fletcher4.Size
+
+// BackupMagic is the drr_magic value of Begin records. Streams from hosts of the other byte order hold it byte
+// swapped.
+const BackupMagic = 0x2F5bacbac
+
+// RecordType is the drr_type of a record.
+type RecordType uint32
+
+// Record types, in the order of the drr_type enum
+const (
+	Begin RecordType = iota
+	Object
+	FreeObjects
+	Write
+	Free
+	End
+	WriteByRef
+	Spill
+	WriteEmbedded
+	ObjectRange
+	Redact
+)
+
+var recordTypeNames = [...]string{"BEGIN", "OBJECT", "FREEOBJECTS", "WRITE", "FREE", "END", "WRITE_BYREF", "SPILL",
+	"WRITE_EMBEDDED", "OBJECT_RANGE", "REDACT"}
+
+// String returns the name zstreamdump uses for the record type, e.g. "WRITE".
+func (t RecordType) String() string {
+	if int(t) < len(recordTypeNames) {
+		return recordTypeNames[t]
+	}
+	return fmt.Sprintf("RecordType(%d)", uint32(t))
+}
+
+// Record is one record of a send stream with its payload.
+type Record struct {
+	Type RecordType
+	// Index of the record in the stream, counting from zero
+	Index int
+	// Offset of the record from the start of the stream
+	Offset int64
+	// The record as sent, including the checksum at its end
+	Header [RecordSize]byte
+	// Only valid until the next record is read
+	Payload []byte
+	// Whether the record was sent by a host of the other byte order, i.e. its fields are big endian
+	Byteswapped bool
+}
+
+// order returns the byte order of the fields of the record.
+func (r *Record) order() binary.ByteOrder {
+	if r.Byteswapped {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// Checksum returns the checksum stored at the end of the record, zero if the sender did not compute one.
+// Begin records hold no checksum. The checksum of a byte swapped record is the one computed by the sender, see
+// fletcher4.ChecksumByteswap.
+func (r *Record) Checksum() fletcher4.Checksum {
+	var sum fletcher4.Checksum
+	for i := range sum {
+		sum[i] = r.order().Uint64(r.Header[checksumOffset+8*i:])
+	}
+	return sum
+}
+
+// PayloadLen returns the length of the payload following the record. Only Begin records hold it in
+// drr_payloadlen, the other records with a payload give it in their own fields, like DRR_WRITE_PAYLOAD_SIZE and
+// the other macros of zfs_ioctl.h compute it.
+func (r *Record) PayloadLen() uint64 {
+	u32 := func(off int) uint64 { return uint64(r.order().Uint32(r.Header[off:])) }
+	u64 := func(off int) uint64 { return r.order().Uint64(r.Header[off:]) }
+	switch r.Type {
+	case Begin:
+		// drr_payloadlen, the packed nvlist of a compound stream or a resumed send
+		return u32(4)
+	case Object:
+		// drr_raw_bonuslen, only set by raw sends, otherwise drr_bonuslen rounded up to 8 bytes
+		if n := u32(36); n != 0 {
+			return n
+		}
+		return (u32(28) + 7) &^ 7
+	case Write:
+		// drr_compressed_size if drr_compressiontype is set, otherwise drr_logical_size
+		if r.Header[50] != 0 {
+			return u64(96)
+		}
+		return u64(32)
+	case Spill:
+		// drr_compressed_size if set, only by raw sends, otherwise drr_length
+		if n := u64(40); n != 0 {
+			return n
+		}
+		return u64(16)
+	case WriteEmbedded:
+		// drr_psize rounded up to 8 bytes
+		return (u32(52) + 7) &^ 7
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// State is the position and running checksum of a Reader between two records. Saved regularly while verifying
+// a large stream, it lets verification resume after an interruption from where it got to instead of from the
+// start, much like a resumable zfs receive.
+type State struct {
+	// Offset of the next record from the start of the stream
+	Offset int64
+	// Index of the next record
+	Record int
+	// Checksum of the stream since its last BEGIN record
+	Checksum fletcher4.Checksum
+	// Whether a BEGIN record has been read
+	Begun bool
+	// Whether the stream since the last BEGIN record is byte swapped
+	Byteswapped bool
+	// Type of the last record read
+	Last RecordType
+	// Offset up to which the stream has been verified by a record checksum, where the damage reported by a
+	// ChecksumError starts
+	Verified int64
+}
+
+// Size of a serialized state: version, flags, last type, offset, record index, checksum and verified offset
+const stateSize = 1 + 1 + 4 + 8 + 8 + fletcher4.Size + 8
+
+// Flags of a serialized state
+const (
+	flagBegun       = 1 << 0
+	flagByteswapped = 1 << 1
+)
+
+const stateVersion = 1
+
+// State returns the state of the reader after the last record read.
+func (r *Reader) State() State {
+	return State{Offset: r.offset, Record: r.index, Checksum: r.sum, Begun: r.begun, Byteswapped: r.swapped, Last: r.last,
+		Verified: r.verified}
+}
+
+// Resume returns a Reader continuing a stream from state s, reading from r positioned at s.Offset, e.g. a file
+// seeked to it. Offsets and record indices go on from those of s. The options are not part of the state, give
+// the ones the state was saved with.
+func Resume(r io.Reader, s State, opts ...Option) *Reader {
+	rd := &Reader{r: bufio.NewReaderSize(r, 128<<10), sum: s.Checksum, offset: s.Offset, index: s.Record,
+		begun: s.Begun, swapped: s.Byteswapped, last: s.Last, verified: s.Verified}
+	for _, opt := range opts {
+		opt(rd)
+	}
+	return rd
+}
+
+// Continue returns a Reader verifying a stream from the middle, given the checksum of the stream from its last
+// BEGIN record up to offset, e.g. from a receive checkpoint, and r positioned at the record at offset. Offsets
+// go on from offset, record indices count from zero at it. Byte swapped streams are continued with Resume.
+func Continue(r io.Reader, offset int64, sum fletcher4.Checksum, opts ...Option) *Reader {
+	return Resume(r, State{Offset: offset, Checksum: sum, Begun: true, Verified: offset}, opts...)
+}
+
+// MarshalBinary returns the state serialized in a fixed size, little endian form.
+func (s State) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, stateSize)
+	var flags byte
+	if s.Begun {
+		flags |= flagBegun
+	}
+	if s.Byteswapped {
+		flags |= flagByteswapped
+	}
+	b = append(b, stateVersion, flags)
+	b = binary.LittleEndian.AppendUint32(b, uint32(s.Last))
+	b = binary.LittleEndian.AppendUint64(b, uint64(s.Offset))
+	b = binary.LittleEndian.AppendUint64(b, uint64(s.Record))
+	b, err := s.Checksum.AppendBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return binary.LittleEndian.AppendUint64(b, uint64(s.Verified)), nil
+}
+
+// UnmarshalBinary restores a state serialized by MarshalBinary.
+func (s *State) UnmarshalBinary(data []byte) error {
+	if len(data) != stateSize {
+		return fmt.Errorf("zfssend: serialized state must be %v bytes, got %v", stateSize, len(data))
+	}
+	if data[0] != stateVersion {
+		return fmt.Errorf("zfssend: unknown state version %v", data[0])
+	}
+	if data[1]&^(flagBegun|flagByteswapped) != 0 {
+		return errors.New("zfssend: invalid state flags")
+	}
+	s.Begun = data[1]&flagBegun != 0
+	s.Byteswapped = data[1]&flagByteswapped != 0
+	s.Last = RecordType(binary.LittleEndian.Uint32(data[2:]))
+	s.Offset = int64(binary.LittleEndian.Uint64(data[6:]))
+	s.Record = int(binary.LittleEndian.Uint64(data[14:]))
+	s.Verified = int64(binary.LittleEndian.Uint64(data[stateSize-8:]))
+	return s.Checksum.UnmarshalBinary(data[22 : stateSize-8])
+}
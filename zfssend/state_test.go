@@ -0,0 +1,118 @@
+// Copyright: Jostein Stuhaug
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfssend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.solidsystem.no/fletcher4"
+)
+
+// Test that verification resumed from a saved state goes on with the right checksum, offsets and indices
+func TestResume(t *testing.T) {
+	stream := sendStream(testStream())
+	r := NewReader(bytes.NewReader(stream))
+	for i := 0; i < 3; i++ {
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved, err := r.State().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s State
+	if err := s.UnmarshalBinary(saved); err != nil {
+		t.Fatal(err)
+	}
+	if s != r.State() {
+		t.Fatalf("State %+v restored as %+v", r.State(), s)
+	}
+	if err := Resume(bytes.NewReader(stream[s.Offset:]), s).Verify(); err != nil {
+		t.Errorf("Resumed verification returned %v", err)
+	}
+
+	// Corruption after the resume point is reported with offsets in the whole stream
+	corrupt := bytes.Clone(stream)
+	corrupt[s.Offset+RecordSize-40] ^= 1
+	err = Resume(bytes.NewReader(corrupt[s.Offset:]), s).Verify()
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) || cerr.Record != 3 || cerr.Offset != s.Offset {
+		t.Errorf("Resumed verification of corrupt stream returned %v", err)
+	} else if cerr.DamageStart != s.Verified || s.Verified != 2*RecordSize+8+checksumOffset {
+		t.Errorf("Damage of resumed verification starts at %v, verified up to %v", cerr.DamageStart, s.Verified)
+	}
+
+	if err := s.UnmarshalBinary(saved[1:]); err == nil {
+		t.Error("Restoring a short state succeeded")
+	}
+	unknown := bytes.Clone(saved)
+	unknown[0] = stateVersion + 1
+	if err := s.UnmarshalBinary(unknown); err == nil {
+		t.Error("Restoring a state of an unknown version succeeded")
+	}
+}
+
+// Test continuing verification from an externally known checksum and offset
+func TestContinue(t *testing.T) {
+	stream := sendStream(testStream())
+	offset := int64(2*RecordSize + 8)
+	sum := fletcher4.ChecksumBlocks(stream[:offset], int(offset))[0]
+	r := Continue(bytes.NewReader(stream[offset:]), offset, sum)
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Type != Write || rec.Offset != offset || rec.Index != 0 {
+		t.Errorf("Continued at %v record %v at offset %v", rec.Type, rec.Index, rec.Offset)
+	}
+	if err := r.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	sum[0]++
+	if err := Continue(bytes.NewReader(stream[offset:]), offset, sum).Verify(); !errors.Is(err, fletcher4.ErrChecksumMismatch) {
+		t.Errorf("Continuing with the wrong checksum returned %v", err)
+	}
+}
+
+// Test that verification of a byte swapped stream resumes byte swapped
+func TestResumeByteswapped(t *testing.T) {
+	stream := sendStreamOrder(testStream(), binary.BigEndian)
+	r := NewReader(bytes.NewReader(stream))
+	for i := 0; i < 2; i++ {
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved, err := r.State().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s State
+	if err := s.UnmarshalBinary(saved); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Byteswapped || s != r.State() {
+		t.Fatalf("State %+v restored as %+v", r.State(), s)
+	}
+	if err := Resume(bytes.NewReader(stream[s.Offset:]), s).Verify(); err != nil {
+		t.Errorf("Resumed verification returned %v", err)
+	}
+}